@@ -2,25 +2,60 @@ package api
 
 import (
 	"github.com/go-rest-api/internal/movie/delivery/http"
+	"github.com/go-rest-api/pkg/auth"
+	"github.com/go-rest-api/pkg/breaker"
+	"github.com/go-rest-api/pkg/consistency"
+	"github.com/go-rest-api/pkg/cors"
+	"github.com/go-rest-api/pkg/httpcache"
+	"github.com/go-rest-api/pkg/ratelimit"
+	"github.com/go-rest-api/pkg/readonly"
 	"github.com/go-rest-api/pkg/response"
+	"github.com/go-rest-api/pkg/tenant"
 	"github.com/gorilla/mux"
 	"github.com/urfave/negroni"
 	nethttp "net/http"
 
+	collectionHandler "github.com/go-rest-api/internal/collection/delivery/http"
 	healthCheckHandler "github.com/go-rest-api/internal/healthcheck/delivery/http"
 )
 
+// Options of the routes
+type Options struct {
+	// CachePolicies are the Cache-Control values per route name
+	CachePolicies httpcache.Policies
+	// EditorTokens are the bearer tokens allowed on the imports, the merges and the collection writes
+	EditorTokens *auth.Tokens
+	// AdminTokens are the bearer tokens allowed on the admin endpoints
+	AdminTokens *auth.Tokens
+	// BreakerStream publishes the circuit breaker metrics to the hystrix dashboards
+	BreakerStream *breaker.Stream
+	// Tenants resolves the tenant of the catalogue requests
+	Tenants *tenant.Resolver
+	// CORS of the cross-origin requests
+	CORS *cors.CORS
+	// RateLimit of the catalogue requests per client
+	RateLimit *ratelimit.Limiter
+	// Consistency carries the session of the client, so its reads see its writes
+	Consistency *consistency.Middleware
+	// ReadOnly rejects the catalogue writes in degraded mode
+	ReadOnly *readonly.Mode
+}
+
 // Route http request pattern
 type Route struct {
 	healthCheckHandler *healthCheckHandler.HealthCheckHandler
 	movieHandler       *http.MovieHandler
+	collectionHandler  *collectionHandler.CollectionHandler
+	options            Options
 }
 
-// NewRoute instances
-func NewRoute(healthCheckHandler *healthCheckHandler.HealthCheckHandler, movieHandler *http.MovieHandler) *Route {
+// NewRoute instances, the collection routes are not served without collectionHandler
+func NewRoute(healthCheckHandler *healthCheckHandler.HealthCheckHandler, movieHandler *http.MovieHandler, collectionHandler *collectionHandler.CollectionHandler, options Options) *Route {
 	return &Route{
 		healthCheckHandler: healthCheckHandler,
 		movieHandler:       movieHandler,
+		collectionHandler:  collectionHandler,
+		options:            options,
 	}
 }
 
@@ -38,12 +73,55 @@ func (r *Route) GetHandler() nethttp.Handler {
 	healthCheck.HandleFunc("/api", r.healthCheckHandler.API).Methods("GET")
 	healthCheck.HandleFunc("/infrastructure", r.healthCheckHandler.Infrastructure).Methods("GET")
 
+	admin := v1.PathPrefix("/admin").Subrouter()
+	admin.HandleFunc("/breakers", r.admin(breaker.SummaryHandler)).Methods("GET")
+	admin.HandleFunc("/breakers/stream", r.admin(r.options.BreakerStream.ServeHTTP)).Methods("GET")
+	admin.HandleFunc("/read-only", r.admin(r.options.ReadOnly.StatusHandler)).Methods("GET")
+	admin.HandleFunc("/read-only", r.admin(r.options.ReadOnly.ToggleHandler)).Methods("PUT")
+
 	movie := v1.PathPrefix("/movies").Subrouter()
-	movie.HandleFunc("", r.movieHandler.GetAllMovies).Methods("GET")
-	movie.HandleFunc("/{id:[0-9]+}", r.movieHandler.GetMovie).Methods("GET")
+	movie.Use(r.options.RateLimit.Middleware, r.options.Tenants.Middleware, r.options.Consistency.Handler, r.options.ReadOnly.Middleware)
+	movie.HandleFunc("", r.options.CachePolicies.Wrap("movies_list", r.movieHandler.GetAllMovies)).Methods("GET")
+	movie.HandleFunc("/stream", r.movieHandler.StreamMovies).Methods("GET")
+	movie.HandleFunc("/stats", r.options.CachePolicies.Wrap("movies_stats", r.movieHandler.GetMovieStats)).Methods("GET")
+	movie.HandleFunc("/{id:[0-9]+}", r.options.CachePolicies.Wrap("movies_get", r.movieHandler.GetMovie)).Methods("GET")
+	movie.HandleFunc("/lookup", r.movieHandler.LookupMovie).Methods("GET")
 	movie.HandleFunc("", r.movieHandler.SaveMovie).Methods("POST")
+	movie.HandleFunc("/import", r.editor(r.movieHandler.ImportMovies)).Methods("POST")
+	movie.HandleFunc("/{id:[0-9]+}/duplicates", r.movieHandler.FindDuplicates).Methods("GET")
+	movie.HandleFunc("/{id:[0-9]+}/merge", r.editor(r.movieHandler.MergeMovie)).Methods("POST")
+
+	if r.collectionHandler != nil {
+		r.collectionRoutes(v1, movie)
+	}
 
 	n := negroni.New()
+	n.Use(r.options.CORS)
 	n.UseHandler(router)
 	return n
 }
+
+// collectionRoutes registers the collections and the collections of a movie
+func (r *Route) collectionRoutes(v1 *mux.Router, movie *mux.Router) {
+	movie.HandleFunc("/{id:[0-9]+}/collections", r.collectionHandler.GetMovieCollections).Methods("GET")
+
+	collection := v1.PathPrefix("/collections").Subrouter()
+	collection.Use(r.options.RateLimit.Middleware, r.options.Tenants.Middleware, r.options.Consistency.Handler, r.options.ReadOnly.Middleware)
+	collection.HandleFunc("", r.collectionHandler.GetAllCollections).Methods("GET")
+	collection.HandleFunc("/{id:[0-9]+}", r.collectionHandler.GetCollection).Methods("GET")
+	collection.HandleFunc("", r.editor(r.collectionHandler.SaveCollection)).Methods("POST")
+	collection.HandleFunc("/{id:[0-9]+}", r.editor(r.collectionHandler.DeleteCollection)).Methods("DELETE")
+	collection.HandleFunc("/{id:[0-9]+}/items", r.editor(r.collectionHandler.SetCollectionItems)).Methods("PUT")
+	collection.HandleFunc("/{id:[0-9]+}/items", r.editor(r.collectionHandler.AddCollectionItem)).Methods("POST")
+	collection.HandleFunc("/{id:[0-9]+}/items/{movie_id:[0-9]+}", r.editor(r.collectionHandler.RemoveCollectionItem)).Methods("DELETE")
+}
+
+// editor restricts the handler to the catalogue editors
+func (r *Route) editor(next nethttp.HandlerFunc) nethttp.HandlerFunc {
+	return r.options.EditorTokens.Require(next)
+}
+
+// admin restricts the handler to the operators
+func (r *Route) admin(next nethttp.HandlerFunc) nethttp.HandlerFunc {
+	return r.options.AdminTokens.Require(next)
+}
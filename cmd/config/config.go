@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	wrapper "github.com/go-rest-api/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// NewCommand creates the config command, its subcommands load the config themselves
+func NewCommand() *cobra.Command {
+	cmdConfig := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect configurations",
+		Long:  "Print the effective configuration, validate and compare configuration files",
+		// The files are loaded by the subcommands, an invalid App.yaml must not prevent validating another file
+		PersistentPreRun: func(command *cobra.Command, args []string) {},
+	}
+
+	cmdPrint := &cobra.Command{
+		Use:   "print",
+		Short: "Print the effective configuration",
+		Long:  "Print every key of the configuration of --config with its value, secrets redacted, and its source: file, env or default",
+		Args:  cobra.NoArgs,
+		Run:   runPrint,
+	}
+	cmdPrint.Flags().Bool("skip-secrets", false, "do not resolve the secret references")
+
+	cmdValidate := &cobra.Command{
+		Use:   "validate <path>",
+		Short: "Validate a configuration file",
+		Long:  "Validate a configuration file, e.g. a deployment config in CI, and report every invalid key",
+		Args:  cobra.ExactArgs(1),
+		Run:   runValidate,
+	}
+	cmdValidate.Flags().Bool("env", false, "apply the APP_ environment overrides")
+	cmdValidate.Flags().Bool("resolve-secrets", false, "resolve the secret references")
+
+	cmdDiff := &cobra.Command{
+		Use:   "diff <a.yaml> <b.yaml>",
+		Short: "Compare two configuration files",
+		Long:  "Print the keys whose effective value differs between two configuration files, exits with 1 when they differ",
+		Args:  cobra.ExactArgs(2),
+		Run:   runDiff,
+	}
+
+	cmdConfig.AddCommand(cmdPrint, cmdValidate, cmdDiff)
+	return cmdConfig
+}
+
+func runPrint(command *cobra.Command, args []string) {
+	dir, _ := command.Flags().GetString("config")
+	skipSecrets, _ := command.Flags().GetBool("skip-secrets")
+
+	err := printConfig(command.OutOrStdout(), dir, skipSecrets)
+	if err != nil {
+		exit(err)
+	}
+}
+
+func runValidate(command *cobra.Command, args []string) {
+	env, _ := command.Flags().GetBool("env")
+	resolveSecrets, _ := command.Flags().GetBool("resolve-secrets")
+
+	err := validate(command.OutOrStdout(), args[0], env, resolveSecrets)
+	if err != nil {
+		exit(err)
+	}
+}
+
+func runDiff(command *cobra.Command, args []string) {
+	differ, err := diff(command.OutOrStdout(), args[0], args[1])
+	if err != nil {
+		exit(err)
+	}
+
+	if differ {
+		os.Exit(1)
+	}
+}
+
+// printConfig writes every key of the config of dir with its value and its source
+func printConfig(out io.Writer, dir string, skipSecrets bool) error {
+	cfg, err := wrapper.LoadDir(dir, wrapper.LoadOptions{SkipSecrets: skipSecrets})
+	if err != nil {
+		return err
+	}
+
+	values, err := cfg.Describe()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE\tREFERENCE")
+	for _, v := range values {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Key, format(v.Value), v.Source, v.Ref)
+	}
+
+	return w.Flush()
+}
+
+// validate loads the config file at path, env applies the environment overrides
+func validate(out io.Writer, path string, env bool, resolveSecrets bool) error {
+	_, err := wrapper.LoadFile(path, wrapper.LoadOptions{
+		IgnoreEnv:   !env,
+		SkipSecrets: !resolveSecrets,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	_, err = fmt.Fprintf(out, "%s: valid\n", path)
+	return err
+}
+
+// diff writes the keys whose value differs between the config files and reports whether there are some
+func diff(out io.Writer, pathA string, pathB string) (bool, error) {
+	options := wrapper.LoadOptions{IgnoreEnv: true, SkipSecrets: true}
+
+	a, err := wrapper.LoadFile(pathA, options)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", pathA, err)
+	}
+
+	b, err := wrapper.LoadFile(pathB, options)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", pathB, err)
+	}
+
+	keys := wrapper.ChangedKeys(a, b)
+	if len(keys) == 0 {
+		return false, nil
+	}
+
+	leavesA, leavesB := wrapper.Flatten(a), wrapper.Flatten(b)
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "KEY\t%s\t%s\n", pathA, pathB)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, formatMissing(leavesA, key), formatMissing(leavesB, key))
+	}
+
+	return true, w.Flush()
+}
+
+// format prints the secrets redacted and the empty strings quoted
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case wrapper.Secret:
+		if v == "" {
+			return `""`
+		}
+		return v.String()
+	case string:
+		if v == "" {
+			return `""`
+		}
+		return v
+	}
+
+	return fmt.Sprint(value)
+}
+
+func formatMissing(leaves map[string]interface{}, key string) string {
+	value, ok := leaves[key]
+	if !ok {
+		return "(unset)"
+	}
+
+	return format(value)
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	wrapper "github.com/go-rest-api/pkg/config"
+)
+
+// databaseConfig holds the database keys without default
+const databaseConfig = `
+database:
+  master:
+    host: 127.0.0.1
+    user: movies
+    name: movies
+  slave:
+    host: 127.0.0.1
+    user: movies
+    name: movies
+`
+
+// writeConfig writes the App.yaml of a test directory and returns its path
+func writeConfig(t *testing.T, dir string, content string) string {
+	path := filepath.Join(dir, "App.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// rows returns the fields of the output lines by their first field
+func rows(output string) map[string][]string {
+	rows := make(map[string][]string)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 {
+			rows[fields[0]] = fields[1:]
+		}
+	}
+
+	return rows
+}
+
+func TestPrintConfig(t *testing.T) {
+	os.Setenv("CONFIG_TEST_PRINT_PASS", "hunter2")
+	defer os.Unsetenv("CONFIG_TEST_PRINT_PASS")
+
+	dir := t.TempDir()
+	writeConfig(t, dir, databaseConfig+"    password: env://CONFIG_TEST_PRINT_PASS\napp:\n  port: 9000\n")
+
+	var out bytes.Buffer
+	if err := printConfig(&out, dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "hunter2") {
+		t.Fatalf("the output leaks the secret:\n%s", out.String())
+	}
+
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"KEY", []string{"VALUE", "SOURCE", "REFERENCE"}},
+		{"app.port", []string{"9000", wrapper.SourceFile}},
+		{"app.graceful_timeout", []string{"30", wrapper.SourceDefault}},
+		{"database.master.password", []string{`""`, wrapper.SourceDefault}},
+		{"database.slave.password", []string{wrapper.Secret("hunter2").String(), wrapper.SourceFile, "env://CONFIG_TEST_PRINT_PASS"}},
+	}
+
+	printed := rows(out.String())
+	for _, tt := range tests {
+		if got := printed[tt.key]; strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s row = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	os.Setenv("APP_APP_PORT", "0")
+	defer os.Unsetenv("APP_APP_PORT")
+
+	dir := t.TempDir()
+	valid := writeConfig(t, dir, databaseConfig)
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := ioutil.WriteFile(invalid, []byte(databaseConfig+"log:\n  level: loud\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		env  bool
+		want string
+		err  string
+	}{
+		{"valid file", valid, false, valid + ": valid\n", ""},
+		{"invalid environment", valid, true, "", "app.port must be between 1 and 65535"},
+		{"invalid file", invalid, false, "", `log.level "loud" is not a log level`},
+		{"missing file", filepath.Join(dir, "missing.yaml"), false, "", "missing.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := validate(&out, tt.path, tt.env, false)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := writeConfig(t, t.TempDir(), databaseConfig+"app:\n  port: 9000\n")
+	same := writeConfig(t, t.TempDir(), databaseConfig+"app:\n  port: 9000\n")
+	b := writeConfig(t, t.TempDir(), databaseConfig+"http:\n  cache_control:\n    movies_get: max-age=60\n")
+
+	var out bytes.Buffer
+	differ, err := diff(&out, a, same)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if differ || out.Len() > 0 {
+		t.Errorf("diff of the same configs = %v, %q, want nothing", differ, out.String())
+	}
+
+	out.Reset()
+	differ, err = diff(&out, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !differ {
+		t.Error("the configs do not differ")
+	}
+
+	printed := rows(out.String())
+	if len(printed) != 3 {
+		t.Errorf("output:\n%s\nwant the header and the 2 changed keys", out.String())
+	}
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"KEY", []string{a, b}},
+		{"app.port", []string{"9000", "8080"}},
+		{"http.cache_control.movies_get", []string{"(unset)", "max-age=60"}},
+	}
+	for _, tt := range tests {
+		if got := printed[tt.key]; strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s row = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{wrapper.Secret(""), `""`},
+		{wrapper.Secret("hunter2"), wrapper.Secret("hunter2").String()},
+		{"", `""`},
+		{"warn", "warn"},
+		{30, "30"},
+		{[]string{"a", "b"}, "[a b]"},
+	}
+
+	for _, tt := range tests {
+		if got := format(tt.value); got != tt.want {
+			t.Errorf("format(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
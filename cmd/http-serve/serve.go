@@ -7,16 +7,33 @@ import (
 	"github.com/go-rest-api/internal/movie/delivery/http"
 	"github.com/go-rest-api/internal/movie/repository"
 	"github.com/go-rest-api/internal/movie/service"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-rest-api/migrations"
+	"github.com/go-rest-api/pkg/auth"
+	"github.com/go-rest-api/pkg/breaker"
+	"github.com/go-rest-api/pkg/config"
+	"github.com/go-rest-api/pkg/consistency"
+	"github.com/go-rest-api/pkg/cors"
+	"github.com/go-rest-api/pkg/httpcache"
+	"github.com/go-rest-api/pkg/migration"
+	"github.com/go-rest-api/pkg/mysql"
+	"github.com/go-rest-api/pkg/ratelimit"
+	"github.com/go-rest-api/pkg/readonly"
+	"github.com/go-rest-api/pkg/sse"
+	"github.com/go-rest-api/pkg/tenant"
+	driver "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	logger "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	config "github.com/spf13/viper"
+	"net"
 	nethttp "net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 
+	collectionHandler "github.com/go-rest-api/internal/collection/delivery/http"
+	collectionRepository "github.com/go-rest-api/internal/collection/repository"
+	collectionService "github.com/go-rest-api/internal/collection/service"
 	healthCheckHandler "github.com/go-rest-api/internal/healthcheck/delivery/http"
 	healthCheckRepository "github.com/go-rest-api/internal/healthcheck/repository"
 	healthCheckService "github.com/go-rest-api/internal/healthcheck/service"
@@ -33,75 +50,271 @@ Port: %s
 
 // env constants
 const (
-	development = "development"
-	staging     = "staging"
-	production  = "production"
+	development = config.EnvDevelopment
+	staging     = config.EnvStaging
+	production  = config.EnvProduction
 )
 
-// DsnFormat stands for database source name format
-var DsnFormat = "%s:%s@tcp(%s:%d)/%s?parseTime=true&charset=%s&loc=%s"
+// Storages of the http-serve --storage flag
+const (
+	StorageDatabase = "database"
+	StorageMemory   = "memory"
+)
+
+// IsProduction reports whether app.env is the production environment
+func IsProduction() bool {
+	return config.Get().App.Env == production
+}
 
 // Server as the http server
 type Server struct {
+	// storage is database or memory, the memory storage opens no database
+	storage string
+
 	dbMaster *sqlx.DB
-	dbSlave  *sqlx.DB
+	replicas *mysql.ReplicaSet
+
+	masterConnector *mysql.Connector
 }
 
-func NewHTTPServer() *Server {
-	s := &Server{}
+// NewHTTPServer connects to the database of the config, unless the storage is memory
+func NewHTTPServer(storage string) *Server {
+	s := &Server{storage: storage}
+	if storage == StorageMemory {
+		logger.Warn("the movies are stored in memory, they are lost on restart")
+		return s
+	}
+	if storage != StorageDatabase {
+		panic(fmt.Errorf("unknown storage %q, expected %s or %s", storage, StorageDatabase, StorageMemory))
+	}
+
+	if config.Get().Database.Driver == config.DriverSQLite {
+		err := s.buildSQLiteClient()
+		if err != nil {
+			panic(err)
+		}
+
+		return s
+	}
+
 	dbMaster, err := s.buildMysqlClientMaster()
 	if err != nil {
 		panic(err)
 	}
 
-	dbSlave, err := s.buildMysqlClientSlave()
+	replicas, err := s.buildMysqlClientSlave()
 	if err != nil {
 		panic(err)
 	}
 
 	s.dbMaster = dbMaster
-	s.dbSlave = dbSlave
+	s.replicas = replicas
 
 	return s
 }
 
-func (s *Server) buildMysqlClientMaster() (*sqlx.DB, error) {
-	dataSource := fmt.Sprintf("%s:%s@(%s:%s)/%s", config.GetString("database.master.user"),
-		config.GetString("database.master.password"),
-		config.GetString("database.master.host"),
-		config.GetString("database.master.port"),
-		config.GetString("database.master.name"),
-	)
-	db, err := sqlx.Connect("mysql", dataSource)
+func (s *Server) buildMysqlClientMaster() (db *sqlx.DB, err error) {
+	db, s.masterConnector, err = NewMysqlClient("master", config.Get().Database.Master)
+	return db, err
+}
+
+// buildMysqlClientSlave connects to every read replica, named slave-0, slave-1, etc.
+func (s *Server) buildMysqlClientSlave() (*mysql.ReplicaSet, error) {
+	database := config.Get().Database
+
+	var replicas []*mysql.Replica
+	for i, server := range database.ReadReplicas() {
+		name := fmt.Sprintf("slave-%d", i)
+
+		db, connector, err := NewMysqlClient(name, server)
+		if err != nil {
+			for _, r := range replicas {
+				_ = r.DB.Close()
+			}
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		replicas = append(replicas, &mysql.Replica{Name: name, DB: db, Connector: connector})
+	}
+
+	return mysql.NewReplicaSet(replicaOptions(database), replicas...)
+}
+
+// buildSQLiteClient opens the database file serving as the master and as the only replica, slave-0
+func (s *Server) buildSQLiteClient() error {
+	database := config.Get().Database
+
+	db, err := OpenMaster(database)
 	if err != nil {
-		return db, err
+		return err
 	}
-	return db, nil
+
+	replicas, err := mysql.NewReplicaSet(replicaOptions(database), &mysql.Replica{Name: "slave-0", DB: db})
+	if err != nil {
+		_ = db.Close()
+		return err
+	}
+
+	s.dbMaster = db
+	s.replicas = replicas
+	return nil
 }
 
-func (s *Server) buildMysqlClientSlave() (*sqlx.DB, error) {
-	dataSource := fmt.Sprintf("%s:%s@(%s:%s)/%s", config.GetString("database.slave.user"),
-		config.GetString("database.slave.password"),
-		config.GetString("database.slave.host"),
-		config.GetString("database.slave.port"),
-		config.GetString("database.slave.name"),
-	)
-	db, err := sqlx.Connect("mysql", dataSource)
+// OpenMaster connects to the master of the configured driver, the MySQL master server or the SQLite file
+func OpenMaster(database config.Database) (*sqlx.DB, error) {
+	if database.Driver == config.DriverSQLite {
+		return mysql.OpenSQLite(database.SQLite.Path, time.Duration(database.SQLite.BusyTimeoutMs)*time.Millisecond)
+	}
+
+	db, _, err := NewMysqlClient("master", database.Master)
+	return db, err
+}
+
+// repositoryOptions of the BaseRepository operations, a SQLite file reads its writes at once
+func repositoryOptions(database config.Database) mysql.Options {
+	if database.Driver == config.DriverSQLite {
+		database.Consistency.Mode = config.ConsistencyEventual
+	}
+
+	return mysql.Options{
+		Consistency: mysql.Consistency{
+			Mode:        database.Consistency.Mode,
+			Window:      time.Duration(database.Consistency.Window) * time.Second,
+			WaitTimeout: time.Duration(database.Consistency.WaitTimeoutMs) * time.Millisecond,
+		},
+		Timeouts: mysql.Timeouts{
+			Read:        time.Duration(database.Timeouts.ReadMs) * time.Millisecond,
+			Write:       time.Duration(database.Timeouts.WriteMs) * time.Millisecond,
+			Transaction: time.Duration(database.Timeouts.TransactionMs) * time.Millisecond,
+		},
+		Fallback: mysql.Fallback{
+			Enabled:           database.Fallback.Enabled,
+			RequestsPerSecond: database.Fallback.RequestsPerSecond,
+			Burst:             database.Fallback.Burst,
+		},
+	}
+}
+
+// replicaOptions of the read replicas, a SQLite file has no replication lag
+func replicaOptions(database config.Database) mysql.ReplicaOptions {
+	replication := database.Replication
+	if database.Driver == config.DriverSQLite {
+		replication.MaxLag = 0
+	}
+
+	return mysql.ReplicaOptions{
+		Balancer:       replication.Balancer,
+		MaxLag:         time.Duration(replication.MaxLag) * time.Second,
+		LagSource:      replication.LagSource,
+		HeartbeatTable: replication.HeartbeatTable,
+	}
+}
+
+// NewMysqlClient connects to a database server of the config, the connector reconnects with new credentials.
+// The name identifies the TLS config of the server.
+func NewMysqlClient(name string, server config.DatabaseServer) (*sqlx.DB, *mysql.Connector, error) {
+	dsn, err := dataSourceName(name, server)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, connector, err := mysql.Open(dsn)
 	if err != nil {
-		return db, err
+		return nil, nil, err
 	}
-	return db, nil
+
+	err = configurePool(db, connector, name, server)
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, err
+	}
+
+	return db, connector, nil
+}
+
+// dataSourceName builds the DSN of the server and registers its TLS config under name
+func dataSourceName(name string, server config.DatabaseServer) (string, error) {
+	loc, err := time.LoadLocation(server.Location)
+	if err != nil {
+		return "", err
+	}
+
+	dsn := driver.NewConfig()
+	dsn.User = server.User
+	dsn.Passwd = server.Password.Value()
+	dsn.Net = "tcp"
+	dsn.Addr = net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+	dsn.DBName = server.Name
+	dsn.Collation = server.Collation
+	dsn.Loc = loc
+	dsn.ParseTime = true
+	dsn.Timeout = time.Duration(server.DialTimeout) * time.Second
+	dsn.ReadTimeout = time.Duration(server.ReadTimeout) * time.Second
+	dsn.WriteTimeout = time.Duration(server.WriteTimeout) * time.Second
+
+	if server.TLS.Enabled {
+		err = mysql.RegisterTLS(name, mysql.TLSOptions{
+			CAFile:             server.TLS.CAFile,
+			CertFile:           server.TLS.CertFile,
+			KeyFile:            server.TLS.KeyFile,
+			ServerName:         server.TLS.ServerName,
+			InsecureSkipVerify: server.TLS.SkipVerify,
+		})
+		if err != nil {
+			return "", fmt.Errorf("database %s tls: %w", name, err)
+		}
+		dsn.TLSConfig = name
+	}
+
+	return dsn.FormatDSN(), nil
+}
+
+// configurePool applies the pool settings and the credentials of the server, a changed DSN reconnects the pool
+func configurePool(db *sqlx.DB, connector *mysql.Connector, name string, server config.DatabaseServer) error {
+	dsn, err := dataSourceName(name, server)
+	if err != nil {
+		return err
+	}
+
+	db.SetMaxOpenConns(server.MaxOpenConns)
+	connector.SetMaxIdleConns(server.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(server.ConnMaxLifetime) * time.Second)
+	db.SetConnMaxIdleTime(time.Duration(server.ConnMaxIdleTime) * time.Second)
+
+	return connector.Reconnect(dsn)
 }
 
 // Serve listen and serve server
 func (s *Server) Serve(cmd *cobra.Command, args []string) {
-	// HealthCheck
-	healthCheckRepo, err := healthCheckRepository.NewHealthCheckRepository(s.dbMaster, s.dbSlave)
-	if err != nil {
-		panic(err)
+	cfg := config.Get()
+
+	// Read-only mode, the writes are refused while the master is down
+	readOnly := readonly.New(time.Duration(cfg.ReadOnly.RetryAfter) * time.Second)
+	if cfg.ReadOnly.Enabled {
+		readOnly.SetAdmin(true, "enabled in the configuration")
 	}
+	config.Subscribe(func(cfg *config.Config) error {
+		readOnly.SetRetryAfter(time.Duration(cfg.ReadOnly.RetryAfter) * time.Second)
+		return nil
+	}, "read_only.retry_after")
+	// Only a change of the switch overrides the mode set on the admin endpoint
+	config.Subscribe(func(cfg *config.Config) error {
+		readOnly.SetAdmin(cfg.ReadOnly.Enabled, "enabled in the configuration")
+		return nil
+	}, "read_only.enabled")
 
-	healthCheckService, err := healthCheckService.NewHealthCheckService(healthCheckRepo)
+	var (
+		repos        repositories
+		stopDatabase func()
+	)
+	if s.storage == StorageMemory {
+		repos, stopDatabase = s.buildMemoryRepositories()
+	} else {
+		repos, stopDatabase = s.buildDatabaseRepositories(cfg, readOnly)
+	}
+
+	// HealthCheck
+	healthCheckService, err := healthCheckService.NewHealthCheckService(repos.healthCheck, readOnly)
 	if err != nil {
 		panic(err)
 	}
@@ -111,31 +324,116 @@ func (s *Server) Serve(cmd *cobra.Command, args []string) {
 		panic(err)
 	}
 
-	movieRepo, err := repository.NewMovieRepository(s.dbMaster, s.dbSlave)
+	movieEvents := sse.NewBroker(sse.Options{
+		LogSize:      cfg.SSE.LogSize,
+		ClientBuffer: cfg.SSE.ClientBuffer,
+		Heartbeat:    time.Duration(cfg.SSE.HeartbeatInterval) * time.Second,
+		Scope: func(r *nethttp.Request) string {
+			tenantID, _ := tenant.FromContext(r.Context())
+			return tenantID
+		},
+	})
+
+	movieService, err := service.NewMovieService(repos.movie, movieEvents, service.Options{
+		StatsCacheTTL: time.Duration(cfg.Movie.StatsCacheTTL) * time.Second,
+	})
 	if err != nil {
 		panic(err)
 	}
 
-	movieService, err := service.NewMovieService(movieRepo)
+	movieDelegate, err := http.NewMovieHandler(movieService, movieEvents)
 	if err != nil {
 		panic(err)
 	}
 
-	movieDelegate, err := http.NewMovieHandler(movieService)
-	if err != nil {
-		panic(err)
+	// The collections are only stored in the database
+	var collectionDelegate *collectionHandler.CollectionHandler
+	if repos.collection != nil {
+		collectionService, err := collectionService.NewCollectionService(repos.collection)
+		if err != nil {
+			panic(err)
+		}
+
+		collectionDelegate, err = collectionHandler.NewCollectionHandler(collectionService)
+		if err != nil {
+			panic(err)
+		}
 	}
 
-	httpHandler := api.NewRoute(healthCheckDelegate, movieDelegate).GetHandler()
+	corsPolicy := cors.New(cfg.HTTP.CORS.AllowedOrigins)
+	config.Subscribe(func(cfg *config.Config) error {
+		corsPolicy.SetOrigins(cfg.HTTP.CORS.AllowedOrigins)
+		return nil
+	}, "http.cors")
+
+	rateLimit := ratelimit.New(cfg.HTTP.RateLimit.RequestsPerSecond, cfg.HTTP.RateLimit.Burst)
+	config.Subscribe(func(cfg *config.Config) error {
+		rateLimit.SetLimit(cfg.HTTP.RateLimit.RequestsPerSecond, cfg.HTTP.RateLimit.Burst)
+		return nil
+	}, "http.rate_limit")
+
+	consistencyTokens := consistency.NewMiddleware(consistency.Options{
+		Header: cfg.Database.Consistency.Header,
+		Cookie: cfg.Database.Consistency.Cookie,
+		Window: time.Duration(cfg.Database.Consistency.Window) * time.Second,
+		Secret: cfg.Database.Consistency.TokenSecret.Value(),
+	})
+	config.Subscribe(func(cfg *config.Config) error {
+		consistencyTokens.SetWindow(time.Duration(cfg.Database.Consistency.Window) * time.Second)
+		consistencyTokens.SetSecret(cfg.Database.Consistency.TokenSecret.Value())
+		return nil
+	}, "database.consistency.window", "database.consistency.token_secret")
+
+	editorTokens := auth.NewTokens(config.Values(cfg.Auth.EditorTokens))
+	adminTokens := auth.NewTokens(config.Values(cfg.Auth.AdminTokens))
+	// A token rotated in its secret reference is applied like an edit of the file
+	config.Subscribe(func(cfg *config.Config) error {
+		editorTokens.Set(config.Values(cfg.Auth.EditorTokens))
+		adminTokens.Set(config.Values(cfg.Auth.AdminTokens))
+		return nil
+	}, "auth")
+
+	breakerStream := breaker.NewStream()
+	tenants := newTenantResolver(cfg.Tenancy)
+	// A rotated secret rejects the tokens signed with the previous one
+	config.Subscribe(func(cfg *config.Config) error {
+		tenants.SetTokenSecret(cfg.Tenancy.TokenSecret.Value())
+		return nil
+	}, "tenancy.token_secret")
+
+	httpHandler := api.NewRoute(healthCheckDelegate, movieDelegate, collectionDelegate, api.Options{
+		// The catalogue routes are scoped to the tenant, a shared cache keeps a copy per tenant. The reads
+		// following a write skip the shared caches, which would serve them the copy from before the write.
+		CachePolicies: httpcache.Policies{
+			Routes:  cfg.HTTP.CacheControl,
+			Vary:    append(tenants.Vary(), consistencyTokens.Vary()...),
+			Private: consistency.FollowsWrite,
+		},
+		EditorTokens:  editorTokens,
+		AdminTokens:   adminTokens,
+		BreakerStream: breakerStream,
+		ReadOnly:      readOnly,
+		Tenants:       tenants,
+		CORS:          corsPolicy,
+		RateLimit:     rateLimit,
+		Consistency:   consistencyTokens,
+	}).GetHandler()
 	server := &nethttp.Server{
-		Addr:    fmt.Sprintf(":%d", config.GetInt("app.port")),
+		Addr:    fmt.Sprintf(":%d", cfg.App.Port),
 		Handler: httpHandler,
 	}
+	// The event streams never become idle, close them so Shutdown can drain the connections
+	server.RegisterOnShutdown(movieEvents.Close)
+	server.RegisterOnShutdown(breakerStream.Close)
+
+	stopSecrets := config.WatchSecrets()
+	server.RegisterOnShutdown(stopSecrets)
+	server.RegisterOnShutdown(stopDatabase)
 
 	printBannerInfo(server.Addr)
 
 	go func() {
-		if err := server.ListenAndServe(); err != nil {
+		if err := server.ListenAndServe(); err != nil && err != nethttp.ErrServerClosed {
 			logger.Fatal(err)
 		}
 	}()
@@ -145,7 +443,7 @@ func (s *Server) Serve(cmd *cobra.Command, args []string) {
 	signal.Notify(quit, os.Interrupt)
 	<-quit
 
-	gracefulTimeout := time.Duration(config.GetInt("app.graceful_timeout")) * time.Second
+	gracefulTimeout := time.Duration(cfg.App.GracefulTimeout) * time.Second
 	if gracefulTimeout == 0 {
 		gracefulTimeout = 30 * time.Second
 	}
@@ -159,6 +457,131 @@ func (s *Server) Serve(cmd *cobra.Command, args []string) {
 	os.Exit(0)
 }
 
+// repositories of the handlers
+type repositories struct {
+	// healthCheck is nil without database
+	healthCheck healthCheckRepository.IHealthCheckRepository
+	movie       repository.MovieRepositoryFactory
+	// collection is nil without database
+	collection collectionRepository.CollectionRepositoryFactory
+}
+
+// buildDatabaseRepositories builds the repositories of the database, the returned function stops its health checks
+func (s *Server) buildDatabaseRepositories(cfg *config.Config, readOnly *readonly.Mode) (repositories, func()) {
+	// The pools follow the config changes and the secret rotations
+	applyMaster := func(cfg *config.Config) error {
+		return configurePool(s.dbMaster, s.masterConnector, "master", cfg.Database.Master)
+	}
+	applySlave := func(cfg *config.Config) error {
+		servers := cfg.Database.ReadReplicas()
+		replicas := s.replicas.Replicas()
+		if len(servers) != len(replicas) {
+			logger.Warnf("the read replicas change from %d to %d on restart", len(replicas), len(servers))
+		}
+
+		for i, r := range replicas {
+			if i >= len(servers) {
+				break
+			}
+
+			err := configurePool(r.DB, r.Connector, r.Name, servers[i])
+			if err != nil {
+				return fmt.Errorf("%s: %w", r.Name, err)
+			}
+		}
+
+		s.replicas.SetOptions(replicaOptions(cfg.Database))
+		return nil
+	}
+	// The SQLite file has no server pools, the driver changes on restart
+	if cfg.Database.Driver == config.DriverMySQL {
+		if err := applyMaster(cfg); err != nil {
+			panic(err)
+		}
+		if err := applySlave(cfg); err != nil {
+			panic(err)
+		}
+		config.Subscribe(applyMaster, "database.master")
+		config.Subscribe(applySlave, "database.slave", "database.replicas", "database.replication")
+	}
+
+	// The repositories follow the config changes of their settings
+	settings := mysql.NewSettings(repositoryOptions(cfg.Database))
+	config.Subscribe(func(cfg *config.Config) error {
+		settings.Set(repositoryOptions(cfg.Database))
+		return nil
+	}, "database.consistency", "database.query_timeouts", "database.fallback")
+
+	// The replicas failing a ping or lagging behind are ejected until a later check
+	s.replicas.Check(context.Background())
+	stopReplicas := s.replicas.Watch(time.Duration(cfg.Database.Replication.CheckInterval) * time.Second)
+
+	if cfg.App.AutoMigrate {
+		if err := s.checkSchema(); err != nil {
+			logger.Fatal(err, ", run the migrate up command before serving")
+		}
+	}
+
+	stopReadOnly := func() {}
+	if cfg.ReadOnly.Auto {
+		stopReadOnly = readOnly.Watch(time.Duration(cfg.ReadOnly.CheckInterval)*time.Second, cfg.ReadOnly.FailureThreshold, s.dbMaster.PingContext)
+	}
+
+	healthCheckRepo, err := healthCheckRepository.NewHealthCheckRepository(s.dbMaster, s.replicas)
+	if err != nil {
+		panic(err)
+	}
+
+	movieRepo, err := repository.NewMovieRepository(s.dbMaster, s.replicas, settings)
+	if err != nil {
+		panic(err)
+	}
+
+	collectionRepo, err := collectionRepository.NewCollectionRepository(s.dbMaster, s.replicas, settings)
+	if err != nil {
+		panic(err)
+	}
+
+	return repositories{healthCheck: healthCheckRepo, movie: movieRepo, collection: collectionRepo}, func() {
+		stopReplicas()
+		stopReadOnly()
+	}
+}
+
+// buildMemoryRepositories builds the in-memory movie repository, lost on restart
+func (s *Server) buildMemoryRepositories() (repositories, func()) {
+	movieRepo, err := repository.NewMemoryMovieRepository()
+	if err != nil {
+		panic(err)
+	}
+
+	return repositories{movie: movieRepo}, func() {}
+}
+
+// checkSchema refuses to serve a database which misses some migrations
+func (s *Server) checkSchema() error {
+	migrator, err := migration.NewMigrator(s.dbMaster.DB, s.dbMaster.DriverName(), migrations.FS)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	return migrator.Check()
+}
+
+// newTenantResolver builds the tenant resolution, single brand deployments fall back to the default tenant
+func newTenantResolver(tenancy config.Tenancy) *tenant.Resolver {
+	return tenant.NewResolver(tenant.Options{
+		Resolvers:   tenancy.Resolvers,
+		Header:      tenancy.Header,
+		BaseDomain:  tenancy.BaseDomain,
+		TokenSecret: tenancy.TokenSecret.Value(),
+		TokenClaim:  tenancy.TokenClaim,
+		Tenants:     tenancy.Tenants,
+		Default:     tenancy.Default,
+	})
+}
+
 func printBannerInfo(port string) {
 	fmt.Printf(bannerInfo, serviceName, port)
 }
@@ -0,0 +1,142 @@
+package http_serve
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-rest-api/pkg/config"
+	driver "github.com/go-sql-driver/mysql"
+)
+
+func TestDataSourceName(t *testing.T) {
+	server := config.Default().Database.Master
+	server.Host = "db.internal"
+	server.User = "movies"
+	server.Password = "p@ss:w/rd?"
+	server.Name = "movies"
+
+	tests := []struct {
+		name   string
+		change func(server *config.DatabaseServer)
+		check  func(t *testing.T, dsn *driver.Config)
+	}{
+		{
+			name:   "defaults",
+			change: func(server *config.DatabaseServer) {},
+			check: func(t *testing.T, dsn *driver.Config) {
+				if dsn.User != "movies" || dsn.Passwd != "p@ss:w/rd?" || dsn.DBName != "movies" {
+					t.Errorf("credentials %q, %q and name %q, want the server ones", dsn.User, dsn.Passwd, dsn.DBName)
+				}
+				if dsn.Net != "tcp" || dsn.Addr != "db.internal:3306" {
+					t.Errorf("address %s(%s), want tcp(db.internal:3306)", dsn.Net, dsn.Addr)
+				}
+				if dsn.Collation != "utf8mb4_general_ci" || dsn.Loc != time.UTC || !dsn.ParseTime {
+					t.Errorf("collation %q, location %v and parseTime %v, want the defaults", dsn.Collation, dsn.Loc, dsn.ParseTime)
+				}
+				if dsn.Timeout != 5*time.Second || dsn.ReadTimeout != 30*time.Second || dsn.WriteTimeout != 30*time.Second {
+					t.Errorf("timeouts %v, %v and %v, want 5s, 30s and 30s", dsn.Timeout, dsn.ReadTimeout, dsn.WriteTimeout)
+				}
+				if dsn.TLSConfig != "" {
+					t.Errorf("tls = %q, want none", dsn.TLSConfig)
+				}
+			},
+		},
+		{
+			name: "timeouts disabled",
+			change: func(server *config.DatabaseServer) {
+				server.DialTimeout, server.ReadTimeout, server.WriteTimeout = 0, 0, 0
+			},
+			check: func(t *testing.T, dsn *driver.Config) {
+				if dsn.Timeout != 0 || dsn.ReadTimeout != 0 || dsn.WriteTimeout != 0 {
+					t.Errorf("timeouts %v, %v and %v, want none", dsn.Timeout, dsn.ReadTimeout, dsn.WriteTimeout)
+				}
+			},
+		},
+		{
+			name: "IPv6 host and local time",
+			change: func(server *config.DatabaseServer) {
+				server.Host = "::1"
+				server.Port = 3307
+				server.Location = "Local"
+			},
+			check: func(t *testing.T, dsn *driver.Config) {
+				if dsn.Addr != "[::1]:3307" {
+					t.Errorf("address = %s, want [::1]:3307", dsn.Addr)
+				}
+				if dsn.Loc != time.Local {
+					t.Errorf("location = %v, want Local", dsn.Loc)
+				}
+			},
+		},
+		{
+			name: "TLS registered under the server name",
+			change: func(server *config.DatabaseServer) {
+				server.TLS.Enabled = true
+				server.TLS.ServerName = "db.internal"
+			},
+			check: func(t *testing.T, dsn *driver.Config) {
+				if dsn.TLSConfig != "test-tls" {
+					t.Errorf("tls = %q, want the registered test-tls", dsn.TLSConfig)
+				}
+			},
+		},
+		{
+			name: "TLS settings ignored while disabled",
+			change: func(server *config.DatabaseServer) {
+				server.TLS.CAFile = filepath.Join(t.TempDir(), "missing.pem")
+			},
+			check: func(t *testing.T, dsn *driver.Config) {
+				if dsn.TLSConfig != "" {
+					t.Errorf("tls = %q, want none", dsn.TLSConfig)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := server
+			tt.change(&server)
+
+			formatted, err := dataSourceName("test-tls", server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dsn, err := driver.ParseDSN(formatted)
+			if err != nil {
+				t.Fatalf("ParseDSN(%s): %v", formatted, err)
+			}
+			tt.check(t, dsn)
+		})
+	}
+}
+
+func TestDataSourceNameErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(server *config.DatabaseServer)
+	}{
+		{"unknown time zone", func(server *config.DatabaseServer) { server.Location = "Mars/Olympus" }},
+		{"missing CA file", func(server *config.DatabaseServer) {
+			server.TLS.Enabled = true
+			server.TLS.CAFile = filepath.Join(t.TempDir(), "missing.pem")
+		}},
+		{"certificate without key", func(server *config.DatabaseServer) {
+			server.TLS.Enabled = true
+			server.TLS.CertFile = "client.pem"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := config.Default().Database.Master
+			server.Host = "db.internal"
+			tt.change(&server)
+
+			if dsn, err := dataSourceName("test-tls-errors", server); err == nil {
+				t.Errorf("dataSourceName = %s, want an error", dsn)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"github.com/go-rest-api/migrations"
+	"github.com/go-rest-api/pkg/config"
+	"github.com/go-rest-api/pkg/migration"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	serve "github.com/go-rest-api/cmd/http-serve"
+)
+
+// NewCommand creates the migrate command, its subcommands run the embedded migrations on the master DB
+func NewCommand() *cobra.Command {
+	cmdMigrate := &cobra.Command{
+		Use:   "migrate",
+		Short: "Database migrations",
+		Long:  "Run the embedded schema migrations against the master database",
+	}
+
+	cmdCreate := &cobra.Command{
+		Use:   "create <name> [sql|go]",
+		Short: "Create a new migration",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(command *cobra.Command, args []string) {
+			migrationType := "sql"
+			if len(args) > 1 {
+				migrationType = args[1]
+			}
+
+			dir, err := command.Flags().GetString("dir")
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			err = migration.Create(dir, args[0], migrationType)
+			if err != nil {
+				logger.Fatal(err)
+			}
+		},
+	}
+	cmdCreate.Flags().String("dir", "migrations", "the migrations source directory")
+
+	cmdMigrate.AddCommand(
+		newRunCommand("up", "Apply every pending migration", (*migration.Migrator).Up),
+		newRunCommand("down", "Roll back the last migration", (*migration.Migrator).Down),
+		newRunCommand("status", "Print the status of every migration", (*migration.Migrator).Status),
+		newRunCommand("redo", "Roll back and apply again the last migration", (*migration.Migrator).Redo),
+		newRunCommand("version", "Print the current schema version", (*migration.Migrator).Version),
+		cmdCreate,
+	)
+
+	return cmdMigrate
+}
+
+// newRunCommand creates a subcommand running fn on the master DB
+func newRunCommand(use string, short string, fn func(m *migration.Migrator) error) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.NoArgs,
+		Run: func(command *cobra.Command, args []string) {
+			db, err := serve.OpenMaster(config.Get().Database)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer db.Close()
+
+			migrator, err := migration.NewMigrator(db.DB, db.DriverName(), migrations.FS)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer migrator.Close()
+
+			err = fn(migrator)
+			if err != nil {
+				logger.Fatal(err)
+			}
+		},
+	}
+}
@@ -1,12 +1,16 @@
 package cmd
 
 import (
+	configCmd "github.com/go-rest-api/cmd/config"
 	serve "github.com/go-rest-api/cmd/http-serve"
+	"github.com/go-rest-api/cmd/migrate"
+	"github.com/go-rest-api/cmd/seed"
+	"github.com/go-rest-api/pkg/breaker"
 	wrapper "github.com/go-rest-api/pkg/config"
 	"github.com/go-rest-api/pkg/logger"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	config "github.com/spf13/viper"
+	"time"
 )
 
 var (
@@ -15,7 +19,10 @@ var (
 
 func init() {
 	rootCmd.PersistentFlags().StringP("config", "c", "../configurations", "the config path location")
-	cobra.OnInitialize(onInitialize)
+	// The commands loading their own config, e.g. config validate, override it
+	rootCmd.PersistentPreRun = func(command *cobra.Command, args []string) {
+		onInitialize()
+	}
 }
 
 func onInitialize() {
@@ -26,14 +33,38 @@ func onInitialize() {
 	}
 	wrapper.SetConfig(cfg)
 
-	logLevel := config.GetString("log.level")
-	if logLevel == "" {
-		logLevel = "debug"
-	}
-	_ = logger.SetLevel(logLevel)
+	_ = logger.SetLevel(wrapper.Get().Log.Level)
+	wrapper.Subscribe(func(cfg *wrapper.Config) error {
+		return logger.SetLevel(cfg.Log.Level)
+	}, "log.level")
 
 	// Set circuit breaker
+	setBreakers(wrapper.Get())
+	wrapper.Subscribe(func(cfg *wrapper.Config) error {
+		setBreakers(cfg)
+		return nil
+	}, "circuit_breaker")
+}
+
+// setBreakers configures the circuit breakers of the database operations
+func setBreakers(cfg *wrapper.Config) {
+	commands := make(map[string]breaker.Config, len(cfg.Breakers.Commands))
+	for name, b := range cfg.Breakers.Commands {
+		commands[name] = breakerConfig(b)
+	}
+
+	breaker.Configure(breakerConfig(cfg.Breakers.Default), commands)
+	breaker.SetEnabled(cfg.Breakers.Enabled)
+}
 
+func breakerConfig(b wrapper.Breaker) breaker.Config {
+	return breaker.Config{
+		Timeout:       time.Duration(b.TimeoutMs) * time.Millisecond,
+		MaxConcurrent: b.MaxConcurrent,
+		RequestVolume: b.RequestVolume,
+		ErrorPercent:  b.ErrorPercent,
+		SleepWindow:   time.Duration(b.SleepWindowMs) * time.Millisecond,
+	}
 }
 
 // Execute serve
@@ -44,12 +75,14 @@ func Execute() {
 			Short: "Listening HTTP server",
 			Long:  "Service Listening HTTP server",
 			Run: func(command *cobra.Command, args []string) {
-				httpServer := serve.NewHTTPServer()
+				storage, _ := command.Flags().GetString("storage")
+				httpServer := serve.NewHTTPServer(storage)
 				httpServer.Serve(command, args)
 			},
 		}
 	)
+	cmdServeHTTP.Flags().String("storage", serve.StorageDatabase, "where the movies are stored, database or memory (no database, no collections, lost on restart)")
 
-	rootCmd.AddCommand(cmdServeHTTP)
+	rootCmd.AddCommand(cmdServeHTTP, migrate.NewCommand(), seed.NewCommand(), configCmd.NewCommand())
 	_ = rootCmd.Execute()
 }
@@ -0,0 +1,93 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/go-rest-api/fixtures"
+	"github.com/go-rest-api/internal/fixture"
+	"github.com/go-rest-api/pkg/config"
+	"github.com/go-rest-api/pkg/tenant"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	serve "github.com/go-rest-api/cmd/http-serve"
+)
+
+// NewCommand creates the seed command, it loads a named fixture set into the master DB
+func NewCommand() *cobra.Command {
+	cmdSeed := &cobra.Command{
+		Use:   "seed <set>",
+		Short: "Load a fixture set",
+		Long:  "Load the movies and related fixtures of a named set, e.g. development, demo or load-test, into the master database",
+		Args:  cobra.ExactArgs(1),
+		Run:   run,
+	}
+	cmdSeed.Flags().String("dir", "", "load the sets from this directory instead of the embedded ones")
+	cmdSeed.Flags().String("tenant", tenant.DefaultTenant, "the tenant owning the seeded rows")
+	cmdSeed.Flags().Bool("truncate", false, "delete the movies and related rows of the tenant first, refused in production")
+
+	return cmdSeed
+}
+
+func run(command *cobra.Command, args []string) {
+	dir, _ := command.Flags().GetString("dir")
+	tenantID, _ := command.Flags().GetString("tenant")
+	truncate, _ := command.Flags().GetBool("truncate")
+
+	if truncate && serve.IsProduction() {
+		logger.Fatal("seed --truncate is not allowed in the production environment")
+	}
+
+	var sets fs.FS = fixtures.FS
+	if dir != "" {
+		sets = os.DirFS(dir)
+	}
+
+	set, err := fixture.Load(sets, args[0])
+	if errors.Is(err, fixture.ErrSetNotFound) {
+		names, _ := fixture.Sets(sets)
+		logger.Fatalf("%v, available sets: %s", err, strings.Join(names, ", "))
+	}
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	db, err := serve.OpenMaster(config.Get().Database)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+
+	seeder, err := fixture.NewSeeder(db)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	ctx := tenant.WithTenant(context.Background(), tenantID)
+
+	if truncate {
+		err = seeder.Truncate(ctx)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Warnf("truncated the movies of tenant %s", tenantID)
+	}
+
+	result, err := seeder.Seed(ctx, set)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	logger.WithFields(logger.Fields{
+		"set":                 set.Name,
+		"tenant":              tenantID,
+		"movies_created":      result.MoviesCreated,
+		"movies_updated":      result.MoviesUpdated,
+		"collections_created": result.CollectionsCreated,
+		"collections_updated": result.CollectionsUpdated,
+	}).Info("fixtures seeded")
+}
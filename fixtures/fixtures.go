@@ -0,0 +1,9 @@
+// Package fixtures embeds the named fixture sets loaded by the seed command, one directory per set
+package fixtures
+
+import "embed"
+
+// FS holds the yaml and json files of the development, demo and load-test sets
+//
+//go:embed development demo load-test
+var FS embed.FS
@@ -1,12 +1,14 @@
 module github.com/go-rest-api
 
-go 1.13
+go 1.16
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.0
 	github.com/afex/hystrix-go v0.0.0-20180502004556-fa1af6a1f4f5
 	github.com/asaskevich/govalidator v0.0.0-20190424111038-f61b66f89f4a
 	github.com/fsnotify/fsnotify v1.4.7
 	github.com/go-sql-driver/mysql v1.5.0
+	github.com/golang/mock v1.4.4
 	github.com/gorilla/mux v1.8.0
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/opentracing/opentracing-go v1.1.0
@@ -17,4 +19,5 @@ require (
 	github.com/spf13/viper v1.4.0
 	github.com/urfave/negroni v1.0.0
 	gopkg.in/yaml.v2 v2.2.2
+	modernc.org/sqlite v1.14.8
 )
@@ -0,0 +1,224 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/go-rest-api/internal/collection/entity"
+	"github.com/go-rest-api/internal/collection/service"
+	"github.com/go-rest-api/pkg/response"
+	"github.com/gorilla/mux"
+	"github.com/opentracing/opentracing-go"
+	nethttp "net/http"
+	"strconv"
+)
+
+type CollectionHandler struct {
+	service service.CollectionServiceFactory
+}
+
+func NewCollectionHandler(service service.CollectionServiceFactory) (*CollectionHandler, error) {
+	return &CollectionHandler{
+		service: service,
+	}, nil
+}
+
+func (c *CollectionHandler) GetAllCollections(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	collections, err := c.service.GetAllCollections(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.WriteAPIOKWithData(w, collections)
+}
+
+func (c *CollectionHandler) GetCollection(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	collectionId, err := pathID(r, "id")
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	collection, err := c.service.GetCollection(ctx, collectionId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.WriteAPIOKWithData(w, collection)
+}
+
+// GetMovieCollections lists the collections of a movie
+func (c *CollectionHandler) GetMovieCollections(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	movieId, err := pathID(r, "id")
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	collections, err := c.service.GetMovieCollections(ctx, movieId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.WriteAPIOKWithData(w, collections)
+}
+
+func (c *CollectionHandler) SaveCollection(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	var payload entity.CollectionReq
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	isValid, err := govalidator.ValidateStruct(payload)
+	if !isValid {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	collection, err := c.service.SaveCollection(ctx, payload)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.WriteApplicationJSON(w, nethttp.StatusCreated, collection)
+}
+
+func (c *CollectionHandler) DeleteCollection(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	collectionId, err := pathID(r, "id")
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	err = c.service.DeleteCollection(ctx, collectionId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.WriteAPINoContent(w)
+}
+
+// AddCollectionItem appends a movie to the collection
+func (c *CollectionHandler) AddCollectionItem(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	collectionId, err := pathID(r, "id")
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	var payload entity.CollectionItemReq
+	err = json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	isValid, err := govalidator.ValidateStruct(payload)
+	if !isValid {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	err = c.service.AddCollectionItem(ctx, collectionId, payload.MovieID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.WriteAPINoContent(w)
+}
+
+func (c *CollectionHandler) RemoveCollectionItem(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	collectionId, err := pathID(r, "id")
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	movieId, err := pathID(r, "movie_id")
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	err = c.service.RemoveCollectionItem(ctx, collectionId, movieId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.WriteAPINoContent(w)
+}
+
+// SetCollectionItems replaces the ordered membership of the collection in one transaction
+func (c *CollectionHandler) SetCollectionItems(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	collectionId, err := pathID(r, "id")
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	var payload entity.CollectionItemsReq
+	err = json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	err = c.service.SetCollectionItems(ctx, collectionId, payload.MovieIDs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.WriteAPINoContent(w)
+}
+
+func pathID(r *nethttp.Request, name string) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)[name], 10, 64)
+}
+
+// writeError maps the service errors to the API responses
+func writeError(w nethttp.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrCollectionNotFound), errors.Is(err, service.ErrItemNotFound):
+		response.WriteAPIError(w, response.APIErrNotFound, err)
+	case errors.Is(err, service.ErrMovieNotFound), errors.Is(err, service.ErrDuplicateItems):
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+	case errors.Is(err, service.ErrItemExists):
+		response.WriteAPIError(w, response.APIErrConflict, err)
+	default:
+		response.WriteAPIServerError(w, err)
+	}
+}
@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+type CollectionRepo struct {
+	ID          int64     `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name" valid:"required"`
+	Description string    `db:"description" json:"description"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// CollectionItemRepo is a movie of a collection with its position
+type CollectionItemRepo struct {
+	CollectionID int64  `db:"collection_id"`
+	MovieID      int64  `db:"movie_id"`
+	Position     int    `db:"position"`
+	Name         string `db:"name"`
+	Duration     int    `db:"duration"`
+	Genre        string `db:"genre"`
+}
@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+type CollectionReq struct {
+	Name        string `json:"name" valid:"required"`
+	Description string `json:"description"`
+}
+
+// CollectionItemsReq is the full ordered membership of a collection
+type CollectionItemsReq struct {
+	MovieIDs []int64 `json:"movie_ids"`
+}
+
+// CollectionItemReq appends a movie to a collection
+type CollectionItemReq struct {
+	MovieID int64 `json:"movie_id" valid:"required"`
+}
+
+type CollectionResp struct {
+	ID          int64                `json:"id"`
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Items       []CollectionItemResp `json:"items,omitempty"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+type CollectionItemResp struct {
+	Position int    `json:"position"`
+	MovieID  int64  `json:"movie_id"`
+	Name     string `json:"name"`
+	Duration int    `json:"duration"`
+	Genre    string `json:"genre"`
+}
@@ -0,0 +1,303 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-rest-api/internal/collection/entity"
+	"github.com/go-rest-api/pkg/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/opentracing/opentracing-go"
+	"time"
+)
+
+// Repository errors
+var (
+	ErrCollectionNotFound = errors.New("collection not found")
+	ErrMovieNotFound      = errors.New("movie not found")
+	ErrItemExists         = errors.New("movie is already in the collection")
+	ErrItemNotFound       = errors.New("movie is not in the collection")
+)
+
+type CollectionRepositoryFactory interface {
+	GetAllCollections(ctx context.Context) ([]entity.CollectionRepo, error)
+	GetCollection(ctx context.Context, collectionId int64) (entity.CollectionRepo, error)
+	GetCollectionItems(ctx context.Context, collectionId int64) ([]entity.CollectionItemRepo, error)
+	GetMovieCollections(ctx context.Context, movieId int64) ([]entity.CollectionRepo, error)
+	SaveCollection(ctx context.Context, collectionRepo entity.CollectionRepo) (entity.CollectionRepo, error)
+	DeleteCollection(ctx context.Context, collectionId int64) error
+	AddCollectionItem(ctx context.Context, collectionId int64, movieId int64) error
+	RemoveCollectionItem(ctx context.Context, collectionId int64, movieId int64) error
+	SetCollectionItems(ctx context.Context, collectionId int64, movieIds []int64) error
+}
+
+type CollectionRepository struct {
+	mysql mysql.BaseRepository
+}
+
+func NewCollectionRepository(masterDB *sqlx.DB, replicas *mysql.ReplicaSet, settings *mysql.Settings) (*CollectionRepository, error) {
+	if masterDB == nil {
+		return nil, errors.New("the master DB connection is nil")
+	}
+
+	if replicas == nil {
+		return nil, errors.New("the replica set is nil")
+	}
+
+	c := &CollectionRepository{}
+	c.mysql.MasterDB = masterDB
+	c.mysql.Replicas = replicas
+	c.mysql.Settings = settings
+	c.mysql.TenantScoped = true
+	return c, nil
+}
+
+func (c *CollectionRepository) GetAllCollections(ctx context.Context) ([]entity.CollectionRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	q := fmt.Sprintf("select id, name, description, created_at, updated_at from collections where tenant_id = {tenant} order by name")
+
+	var collections []entity.CollectionRepo
+
+	err := c.mysql.FetchRows(ctx, q, &collections)
+	if err != nil {
+		return collections, err
+	}
+
+	return collections, nil
+}
+
+func (c *CollectionRepository) GetCollection(ctx context.Context, collectionId int64) (entity.CollectionRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	q := fmt.Sprintf("select id, name, description, created_at, updated_at from collections where tenant_id = {tenant} and id = ?")
+
+	var collection entity.CollectionRepo
+
+	err := c.mysql.FetchRow(ctx, q, &collection, collectionId)
+	if err == sql.ErrNoRows {
+		return collection, ErrCollectionNotFound
+	}
+	if err != nil {
+		return collection, err
+	}
+
+	return collection, nil
+}
+
+// GetCollectionItems fetches the movies of the collection in their curated order
+func (c *CollectionRepository) GetCollectionItems(ctx context.Context, collectionId int64) ([]entity.CollectionItemRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	q := fmt.Sprintf("select ci.collection_id, ci.movie_id, ci.position, m.name, m.duration, m.genre " +
+		"from collection_items ci join collections c on c.id = ci.collection_id join movies m on m.id = ci.movie_id " +
+		"where c.tenant_id = {tenant} and m.tenant_id = {tenant} and ci.collection_id = ? order by ci.position")
+
+	var items []entity.CollectionItemRepo
+
+	err := c.mysql.FetchRows(ctx, q, &items, collectionId)
+	if err != nil {
+		return items, err
+	}
+
+	return items, nil
+}
+
+// GetMovieCollections fetches the collections the movie belongs to
+func (c *CollectionRepository) GetMovieCollections(ctx context.Context, movieId int64) ([]entity.CollectionRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	q := fmt.Sprintf("select c.id, c.name, c.description, c.created_at, c.updated_at " +
+		"from collections c join collection_items ci on ci.collection_id = c.id " +
+		"where c.tenant_id = {tenant} and ci.movie_id = ? order by c.name")
+
+	var collections []entity.CollectionRepo
+
+	err := c.mysql.FetchRows(ctx, q, &collections, movieId)
+	if err != nil {
+		return collections, err
+	}
+
+	return collections, nil
+}
+
+func (c *CollectionRepository) SaveCollection(ctx context.Context, collectionRepo entity.CollectionRepo) (entity.CollectionRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	collectionRepo.CreatedAt = now
+	collectionRepo.UpdatedAt = now
+
+	q := fmt.Sprintf("insert into collections (tenant_id, name, description, created_at, updated_at) values ({tenant}, :name, :description, :created_at, :updated_at)")
+
+	res, err := c.mysql.Exec(ctx, q, collectionRepo)
+	if err != nil {
+		return collectionRepo, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return collectionRepo, err
+	}
+	collectionRepo.ID = id
+
+	return collectionRepo, nil
+}
+
+// DeleteCollection deletes the collection, its items are removed by the foreign key cascade
+func (c *CollectionRepository) DeleteCollection(ctx context.Context, collectionId int64) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	q := fmt.Sprintf("delete from collections where tenant_id = {tenant} and id = :id")
+
+	res, err := c.mysql.Exec(ctx, q, map[string]interface{}{"id": collectionId})
+	if err != nil {
+		return err
+	}
+
+	return affected(res, ErrCollectionNotFound)
+}
+
+// AddCollectionItem appends the movie at the end of the collection, both have to belong to the tenant.
+// The collection row is locked so concurrent appends do not share a position.
+func (c *CollectionRepository) AddCollectionItem(ctx context.Context, collectionId int64, movieId int64) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	return c.mysql.Transaction(ctx, func(tx *mysql.Tx) error {
+		var locked []int64
+		err := tx.FetchRows(ctx, "select id from collections where tenant_id = {tenant} and id = ?"+tx.Dialect().ForUpdate(), &locked, collectionId)
+		if err != nil {
+			return err
+		}
+		if len(locked) == 0 {
+			return ErrCollectionNotFound
+		}
+
+		var last int
+		err = tx.FetchRow(ctx, "select coalesce(max(position), 0) from collection_items where collection_id = ? "+
+			"and collection_id in (select id from collections where tenant_id = {tenant})", &last, collectionId)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.Exec(ctx, "insert into collection_items (collection_id, movie_id, position) "+
+			"select ?, id, ? from movies where tenant_id = {tenant} and id = ?", collectionId, last+1, movieId)
+		if err != nil {
+			return mapItemError(err)
+		}
+
+		err = affected(res, ErrMovieNotFound)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "update collections set updated_at = ? where tenant_id = {tenant} and id = ?", time.Now().UTC().Truncate(time.Second), collectionId)
+		return err
+	})
+}
+
+func (c *CollectionRepository) RemoveCollectionItem(ctx context.Context, collectionId int64, movieId int64) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	q := fmt.Sprintf("delete from collection_items where collection_id = :collection_id and movie_id = :movie_id " +
+		"and collection_id in (select id from collections where tenant_id = {tenant})")
+
+	res, err := c.mysql.Exec(ctx, q, map[string]interface{}{
+		"collection_id": collectionId,
+		"movie_id":      movieId,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = affected(res, ErrItemNotFound)
+	if err != nil {
+		return err
+	}
+
+	return c.touch(ctx, collectionId)
+}
+
+// SetCollectionItems replaces the membership of the collection with the movies in the given order.
+// The collection row is locked so concurrent reorders are serialized.
+func (c *CollectionRepository) SetCollectionItems(ctx context.Context, collectionId int64, movieIds []int64) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	return c.mysql.Transaction(ctx, func(tx *mysql.Tx) error {
+		var locked []int64
+		err := tx.FetchRows(ctx, "select id from collections where tenant_id = {tenant} and id = ?"+tx.Dialect().ForUpdate(), &locked, collectionId)
+		if err != nil {
+			return err
+		}
+		if len(locked) == 0 {
+			return ErrCollectionNotFound
+		}
+
+		_, err = tx.Exec(ctx, "delete from collection_items where collection_id = ? "+
+			"and collection_id in (select id from collections where tenant_id = {tenant})", collectionId)
+		if err != nil {
+			return err
+		}
+
+		for i, movieId := range movieIds {
+			res, err := tx.Exec(ctx, "insert into collection_items (collection_id, movie_id, position) "+
+				"select ?, id, ? from movies where tenant_id = {tenant} and id = ?", collectionId, i+1, movieId)
+			if err != nil {
+				return mapItemError(err)
+			}
+
+			err = affected(res, ErrMovieNotFound)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(ctx, "update collections set updated_at = ? where tenant_id = {tenant} and id = ?", time.Now().UTC().Truncate(time.Second), collectionId)
+		return err
+	})
+}
+
+// touch bumps the updated_at of the collection
+func (c *CollectionRepository) touch(ctx context.Context, collectionId int64) error {
+	q := fmt.Sprintf("update collections set updated_at = :updated_at where tenant_id = {tenant} and id = :id")
+
+	_, err := c.mysql.Exec(ctx, q, map[string]interface{}{
+		"id":         collectionId,
+		"updated_at": time.Now().UTC().Truncate(time.Second),
+	})
+
+	return err
+}
+
+// mapItemError translates the constraint violations of collection_items
+func mapItemError(err error) error {
+	switch {
+	case mysql.IsDuplicate(err):
+		return ErrItemExists
+	case mysql.IsForeignKeyViolation(err):
+		return ErrMovieNotFound
+	}
+
+	return err
+}
+
+func affected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+
+	return nil
+}
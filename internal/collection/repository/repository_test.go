@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-rest-api/pkg/mysql"
+	"github.com/go-rest-api/pkg/tenant"
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+func newMockedRepository(t *testing.T) (*CollectionRepository, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlxDB := sqlx.NewDb(db, "mysql")
+	replicas, err := mysql.NewReplicaSet(mysql.ReplicaOptions{}, &mysql.Replica{Name: "slave", DB: sqlxDB})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewCollectionRepository(sqlxDB, replicas, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c, mock
+}
+
+func TestAddCollectionItemAppendsUnderTheCollectionLock(t *testing.T) {
+	c, mock := newMockedRepository(t)
+	defer c.mysql.MasterDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("select id from collections where tenant_id = ? and id = ? for update")).
+		WithArgs("brand-a", 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery(regexp.QuoteMeta("select coalesce(max(position), 0) from collection_items where collection_id = ? and collection_id in (select id from collections where tenant_id = ?)")).
+		WithArgs(7, "brand-a").
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(3))
+	mock.ExpectExec(regexp.QuoteMeta("insert into collection_items (collection_id, movie_id, position) select ?, id, ? from movies where tenant_id = ? and id = ?")).
+		WithArgs(7, 4, "brand-a", 42).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("update collections set updated_at = ? where tenant_id = ? and id = ?")).
+		WithArgs(sqlmock.AnyArg(), "brand-a", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx := tenant.WithTenant(context.Background(), "brand-a")
+	if err := c.AddCollectionItem(ctx, 7, 42); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAddCollectionItemErrors(t *testing.T) {
+	lock := regexp.QuoteMeta("select id from collections where tenant_id = ? and id = ? for update")
+	last := regexp.QuoteMeta("select coalesce(max(position), 0) from collection_items where collection_id = ?")
+	insert := regexp.QuoteMeta("insert into collection_items")
+
+	tests := []struct {
+		name   string
+		expect func(mock sqlmock.Sqlmock)
+		want   error
+	}{
+		{
+			name: "collection of another tenant",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lock).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			want: ErrCollectionNotFound,
+		},
+		{
+			name: "movie of another tenant",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lock).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+				mock.ExpectQuery(last).WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(0))
+				mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			want: ErrMovieNotFound,
+		},
+		{
+			name: "movie already in the collection",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lock).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+				mock.ExpectQuery(last).WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(1))
+				mock.ExpectExec(insert).WillReturnError(&driver.MySQLError{Number: 1062, Message: "Duplicate entry"})
+			},
+			want: ErrItemExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, mock := newMockedRepository(t)
+			defer c.mysql.MasterDB.Close()
+
+			mock.ExpectBegin()
+			tt.expect(mock)
+			mock.ExpectRollback()
+
+			ctx := tenant.WithTenant(context.Background(), "brand-a")
+			if err := c.AddCollectionItem(ctx, 7, 42); err != tt.want {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
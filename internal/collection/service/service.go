@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/go-rest-api/internal/collection/entity"
+	"github.com/go-rest-api/internal/collection/repository"
+	"github.com/opentracing/opentracing-go"
+)
+
+// Service errors
+var (
+	ErrCollectionNotFound = repository.ErrCollectionNotFound
+	ErrMovieNotFound      = repository.ErrMovieNotFound
+	ErrItemExists         = repository.ErrItemExists
+	ErrItemNotFound       = repository.ErrItemNotFound
+	// ErrDuplicateItems returned when a movie appears twice in the new order
+	ErrDuplicateItems = errors.New("a movie can only appear once in a collection")
+)
+
+type CollectionServiceFactory interface {
+	GetAllCollections(ctx context.Context) ([]entity.CollectionResp, error)
+	GetCollection(ctx context.Context, collectionId int64) (entity.CollectionResp, error)
+	GetMovieCollections(ctx context.Context, movieId int64) ([]entity.CollectionResp, error)
+	SaveCollection(ctx context.Context, collectionReq entity.CollectionReq) (entity.CollectionResp, error)
+	DeleteCollection(ctx context.Context, collectionId int64) error
+	AddCollectionItem(ctx context.Context, collectionId int64, movieId int64) error
+	RemoveCollectionItem(ctx context.Context, collectionId int64, movieId int64) error
+	SetCollectionItems(ctx context.Context, collectionId int64, movieIds []int64) error
+}
+
+type CollectionService struct {
+	repo repository.CollectionRepositoryFactory
+}
+
+func NewCollectionService(repo repository.CollectionRepositoryFactory) (*CollectionService, error) {
+	return &CollectionService{
+		repo: repo,
+	}, nil
+}
+
+func (c *CollectionService) GetAllCollections(ctx context.Context) ([]entity.CollectionResp, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	collectionResps := make([]entity.CollectionResp, 0)
+	collectionRepos, err := c.repo.GetAllCollections(ctx)
+	if err != nil {
+		return collectionResps, err
+	}
+
+	for _, collectionRepo := range collectionRepos {
+		collectionResps = append(collectionResps, toCollectionResp(collectionRepo))
+	}
+
+	return collectionResps, nil
+}
+
+// GetCollection returns the collection with its movies in the curated order
+func (c *CollectionService) GetCollection(ctx context.Context, collectionId int64) (entity.CollectionResp, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	var collectionResp entity.CollectionResp
+	collectionRepo, err := c.repo.GetCollection(ctx, collectionId)
+	if err != nil {
+		return collectionResp, err
+	}
+
+	itemRepos, err := c.repo.GetCollectionItems(ctx, collectionId)
+	if err != nil {
+		return collectionResp, err
+	}
+
+	collectionResp = toCollectionResp(collectionRepo)
+	collectionResp.Items = make([]entity.CollectionItemResp, 0, len(itemRepos))
+	for _, itemRepo := range itemRepos {
+		collectionResp.Items = append(collectionResp.Items, entity.CollectionItemResp{
+			Position: itemRepo.Position,
+			MovieID:  itemRepo.MovieID,
+			Name:     itemRepo.Name,
+			Duration: itemRepo.Duration,
+			Genre:    itemRepo.Genre,
+		})
+	}
+
+	return collectionResp, nil
+}
+
+func (c *CollectionService) GetMovieCollections(ctx context.Context, movieId int64) ([]entity.CollectionResp, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	collectionResps := make([]entity.CollectionResp, 0)
+	collectionRepos, err := c.repo.GetMovieCollections(ctx, movieId)
+	if err != nil {
+		return collectionResps, err
+	}
+
+	for _, collectionRepo := range collectionRepos {
+		collectionResps = append(collectionResps, toCollectionResp(collectionRepo))
+	}
+
+	return collectionResps, nil
+}
+
+func (c *CollectionService) SaveCollection(ctx context.Context, collectionReq entity.CollectionReq) (entity.CollectionResp, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	collectionRepo, err := c.repo.SaveCollection(ctx, entity.CollectionRepo{
+		Name:        collectionReq.Name,
+		Description: collectionReq.Description,
+	})
+	if err != nil {
+		return entity.CollectionResp{}, err
+	}
+
+	return toCollectionResp(collectionRepo), nil
+}
+
+func (c *CollectionService) DeleteCollection(ctx context.Context, collectionId int64) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	return c.repo.DeleteCollection(ctx, collectionId)
+}
+
+func (c *CollectionService) AddCollectionItem(ctx context.Context, collectionId int64, movieId int64) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	_, err := c.repo.GetCollection(ctx, collectionId)
+	if err != nil {
+		return err
+	}
+
+	return c.repo.AddCollectionItem(ctx, collectionId, movieId)
+}
+
+func (c *CollectionService) RemoveCollectionItem(ctx context.Context, collectionId int64, movieId int64) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	return c.repo.RemoveCollectionItem(ctx, collectionId, movieId)
+}
+
+// SetCollectionItems replaces the membership of the collection atomically, the position follows the given order
+func (c *CollectionService) SetCollectionItems(ctx context.Context, collectionId int64, movieIds []int64) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	seen := make(map[int64]bool, len(movieIds))
+	for _, movieId := range movieIds {
+		if seen[movieId] {
+			return ErrDuplicateItems
+		}
+		seen[movieId] = true
+	}
+
+	return c.repo.SetCollectionItems(ctx, collectionId, movieIds)
+}
+
+func toCollectionResp(collectionRepo entity.CollectionRepo) entity.CollectionResp {
+	return entity.CollectionResp{
+		ID:          collectionRepo.ID,
+		Name:        collectionRepo.Name,
+		Description: collectionRepo.Description,
+		CreatedAt:   collectionRepo.CreatedAt,
+		UpdatedAt:   collectionRepo.UpdatedAt,
+	}
+}
@@ -0,0 +1,165 @@
+package fixture
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Fixture errors
+var (
+	ErrSetNotFound = errors.New("fixture set not found")
+	ErrInvalidSet  = errors.New("invalid fixture set")
+)
+
+// Set is the content of every fixture file of a named set, e.g. development
+type Set struct {
+	Name        string       `yaml:"-" json:"-"`
+	Movies      []Movie      `yaml:"movies" json:"movies"`
+	Collections []Collection `yaml:"collections" json:"collections"`
+	Generate    Generate     `yaml:"generate" json:"generate"`
+}
+
+// Movie fixture, matched on one of its external IDs or on its name when seeded again
+type Movie struct {
+	// Key references the movie from the other fixtures, the name by default
+	Key         string            `yaml:"key" json:"key"`
+	Name        string            `yaml:"name" json:"name"`
+	Duration    int               `yaml:"duration" json:"duration"`
+	Genre       string            `yaml:"genre" json:"genre"`
+	ExternalIDs map[string]string `yaml:"external_ids" json:"external_ids"`
+}
+
+// Collection fixture, matched on its name when seeded again
+type Collection struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	// Movies are the keys of the movie fixtures in the curated order
+	Movies []string `yaml:"movies" json:"movies"`
+}
+
+// Generate asks for synthetic rows on top of the listed fixtures, e.g. for load tests
+type Generate struct {
+	Movies int `yaml:"movies" json:"movies"`
+}
+
+// Sets returns the names of the fixture sets, one per directory
+func Sets(fixtures fs.FS) ([]string, error) {
+	entries, err := fs.ReadDir(fixtures, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// Load reads the yaml and json files of the named set in name order and validates them
+func Load(fixtures fs.FS, name string) (*Set, error) {
+	entries, err := fs.ReadDir(fixtures, name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSetNotFound, name)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	set := &Set{Name: name}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		file := path.Join(name, entry.Name())
+		data, err := fs.ReadFile(fixtures, file)
+		if err != nil {
+			return nil, err
+		}
+
+		var part Set
+		switch strings.ToLower(path.Ext(file)) {
+		case ".yaml", ".yml":
+			err = yaml.UnmarshalStrict(data, &part)
+		case ".json":
+			err = json.Unmarshal(data, &part)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidSet, file, err)
+		}
+
+		set.Movies = append(set.Movies, part.Movies...)
+		set.Collections = append(set.Collections, part.Collections...)
+		set.Generate.Movies += part.Generate.Movies
+	}
+
+	err = set.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+// validate checks the required fields and the movie references, the keys default to the names
+func (s *Set) validate() error {
+	keys := make(map[string]bool)
+	for i := range s.Movies {
+		movie := &s.Movies[i]
+		if movie.Name == "" {
+			return fmt.Errorf("%w: movie %d has no name", ErrInvalidSet, i)
+		}
+		if movie.Duration < 1 || movie.Duration > 1000 {
+			return fmt.Errorf("%w: movie %q duration must be between 1 and 1000", ErrInvalidSet, movie.Name)
+		}
+		if movie.Key == "" {
+			movie.Key = movie.Name
+		}
+		if keys[movie.Key] {
+			return fmt.Errorf("%w: duplicate movie key %q", ErrInvalidSet, movie.Key)
+		}
+		keys[movie.Key] = true
+	}
+
+	names := make(map[string]bool)
+	for i, collection := range s.Collections {
+		if collection.Name == "" {
+			return fmt.Errorf("%w: collection %d has no name", ErrInvalidSet, i)
+		}
+		if names[collection.Name] {
+			return fmt.Errorf("%w: duplicate collection %q", ErrInvalidSet, collection.Name)
+		}
+		names[collection.Name] = true
+
+		members := make(map[string]bool)
+		for _, key := range collection.Movies {
+			if !keys[key] {
+				return fmt.Errorf("%w: collection %q references unknown movie %q", ErrInvalidSet, collection.Name, key)
+			}
+			if members[key] {
+				return fmt.Errorf("%w: collection %q lists movie %q twice", ErrInvalidSet, collection.Name, key)
+			}
+			members[key] = true
+		}
+	}
+
+	if s.Generate.Movies < 0 {
+		return fmt.Errorf("%w: generate.movies must be positive", ErrInvalidSet)
+	}
+
+	return nil
+}
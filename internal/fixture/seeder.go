@@ -0,0 +1,247 @@
+package fixture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-rest-api/pkg/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// generatedGenres are cycled through by the generated movies
+var generatedGenres = []string{"Action", "Comedy", "Drama", "Horror", "Sci-Fi", "Documentary"}
+
+// truncateQueries delete the rows of the tenant, children first
+var truncateQueries = []string{
+	"delete from collection_items where collection_id in (select id from collections where tenant_id = {tenant})",
+	"delete from collections where tenant_id = {tenant}",
+	"delete from movie_credits where tenant_id = {tenant}",
+	"delete from movie_external_ids where tenant_id = {tenant}",
+	"delete from movie_redirects where tenant_id = {tenant}",
+	"delete from movies where tenant_id = {tenant}",
+}
+
+// Result counts the seeded rows
+type Result struct {
+	MoviesCreated      int
+	MoviesUpdated      int
+	CollectionsCreated int
+	CollectionsUpdated int
+}
+
+// Seeder writes the fixture sets for the tenant of the context
+type Seeder struct {
+	mysql mysql.BaseRepository
+}
+
+// NewSeeder creates new Seeder
+func NewSeeder(masterDB *sqlx.DB) (*Seeder, error) {
+	if masterDB == nil {
+		return nil, errors.New("the master DB connection is nil")
+	}
+
+	s := &Seeder{}
+	s.mysql.MasterDB = masterDB
+	s.mysql.SlaveDB = masterDB
+	s.mysql.TenantScoped = true
+	return s, nil
+}
+
+// Truncate deletes every movie and related row of the tenant
+func (s *Seeder) Truncate(ctx context.Context) error {
+	return s.mysql.Transaction(ctx, func(tx *mysql.Tx) error {
+		for _, q := range truncateQueries {
+			_, err := tx.Exec(ctx, q)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Seed upserts the fixtures of the set in a single transaction, so seeding it again only updates the rows
+func (s *Seeder) Seed(ctx context.Context, set *Set) (Result, error) {
+	var result Result
+
+	err := s.mysql.Transaction(ctx, func(tx *mysql.Tx) error {
+		result = Result{}
+		now := time.Now().UTC().Truncate(time.Second)
+
+		movieIds := make(map[string]int64, len(set.Movies))
+		for _, movie := range append(set.Movies, generateMovies(set.Generate.Movies)...) {
+			id, created, err := s.seedMovie(ctx, tx, movie, now)
+			if err != nil {
+				return fmt.Errorf("movie %q: %w", movie.Name, err)
+			}
+			movieIds[movie.Key] = id
+
+			if created {
+				result.MoviesCreated++
+			} else {
+				result.MoviesUpdated++
+			}
+		}
+
+		for _, collection := range set.Collections {
+			created, err := s.seedCollection(ctx, tx, collection, movieIds, now)
+			if err != nil {
+				return fmt.Errorf("collection %q: %w", collection.Name, err)
+			}
+
+			if created {
+				result.CollectionsCreated++
+			} else {
+				result.CollectionsUpdated++
+			}
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// seedMovie updates the movie known by one of its external IDs or by its name, or inserts it
+func (s *Seeder) seedMovie(ctx context.Context, tx *mysql.Tx, movie Movie, now time.Time) (int64, bool, error) {
+	providers := make([]string, 0, len(movie.ExternalIDs))
+	for provider := range movie.ExternalIDs {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	var existing []int64
+	if len(providers) > 0 {
+		var (
+			conditions []string
+			args       []interface{}
+		)
+		for _, provider := range providers {
+			conditions = append(conditions, "(provider = ? and external_id = ?)")
+			args = append(args, provider, movie.ExternalIDs[provider])
+		}
+
+		q := fmt.Sprintf("select distinct movie_id from movie_external_ids where tenant_id = {tenant} and (%s)", strings.Join(conditions, " or "))
+		err := tx.FetchRows(ctx, q, &existing, args...)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	if len(existing) == 0 {
+		err := tx.FetchRows(ctx, "select id from movies where tenant_id = {tenant} and name = ? order by id limit 1", &existing, movie.Name)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	if len(existing) > 1 {
+		return 0, false, errors.New("the external IDs belong to several movies")
+	}
+
+	var (
+		id      int64
+		created = len(existing) == 0
+	)
+	if created {
+		res, err := tx.Exec(ctx, "insert into movies (tenant_id, name, genre, duration, created_at, updated_at) values ({tenant}, ?, ?, ?, ?, ?)",
+			movie.Name, movie.Genre, movie.Duration, now, now)
+		if err != nil {
+			return 0, false, err
+		}
+
+		id, err = res.LastInsertId()
+		if err != nil {
+			return 0, false, err
+		}
+	} else {
+		id = existing[0]
+
+		_, err := tx.Exec(ctx, "update movies set name = ?, genre = ?, duration = ?, updated_at = ? where tenant_id = {tenant} and id = ?",
+			movie.Name, movie.Genre, movie.Duration, now, id)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	for _, provider := range providers {
+		_, err := tx.Exec(ctx, "insert into movie_external_ids (tenant_id, movie_id, provider, external_id) values ({tenant}, ?, ?, ?)"+
+			tx.Dialect().Upsert([]string{"movie_id", "provider"}, []string{"external_id"}), id, provider, movie.ExternalIDs[provider])
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	return id, created, nil
+}
+
+// seedCollection upserts the collection by name and replaces its items
+func (s *Seeder) seedCollection(ctx context.Context, tx *mysql.Tx, collection Collection, movieIds map[string]int64, now time.Time) (bool, error) {
+	var existing []int64
+	err := tx.FetchRows(ctx, "select id from collections where tenant_id = {tenant} and name = ? order by id limit 1", &existing, collection.Name)
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		id      int64
+		created = len(existing) == 0
+	)
+	if created {
+		res, err := tx.Exec(ctx, "insert into collections (tenant_id, name, description, created_at, updated_at) values ({tenant}, ?, ?, ?, ?)",
+			collection.Name, collection.Description, now, now)
+		if err != nil {
+			return false, err
+		}
+
+		id, err = res.LastInsertId()
+		if err != nil {
+			return false, err
+		}
+	} else {
+		id = existing[0]
+
+		_, err := tx.Exec(ctx, "update collections set description = ?, updated_at = ? where tenant_id = {tenant} and id = ?",
+			collection.Description, now, id)
+		if err != nil {
+			return false, err
+		}
+
+		_, err = tx.Exec(ctx, "delete from collection_items where collection_id = ? "+
+			"and collection_id in (select id from collections where tenant_id = {tenant})", id)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	for i, key := range collection.Movies {
+		_, err := tx.Exec(ctx, "insert into collection_items (collection_id, movie_id, position) "+
+			"select ?, id, ? from movies where tenant_id = {tenant} and id = ?", id, i+1, movieIds[key])
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return created, nil
+}
+
+// generateMovies builds n deterministic synthetic movies, seeding them again updates the same rows
+func generateMovies(n int) []Movie {
+	movies := make([]Movie, 0, n)
+	for i := 1; i <= n; i++ {
+		name := fmt.Sprintf("Generated Movie %06d", i)
+		movies = append(movies, Movie{
+			Key:      name,
+			Name:     name,
+			Duration: 60 + (i*37)%120,
+			Genre:    generatedGenres[i%len(generatedGenres)],
+		})
+	}
+
+	return movies
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"github.com/go-rest-api/pkg/breaker"
+	"github.com/go-rest-api/pkg/mysql"
 	"github.com/jmoiron/sqlx"
 
 	"github.com/opentracing/opentracing-go"
@@ -11,43 +13,50 @@ import (
 // healthcheck operation constant
 const (
 	// Operation name
-	HealthCheckSlaveDBOperation  = "Repository.HealthCheck.SlaveDB"
-	HealthCheckMasterDBOperation = "Repository.HealthCheck.MasterDB"
+	HealthCheckSlaveDBOperation         = "Repository.HealthCheck.SlaveDB"
+	HealthCheckMasterDBOperation        = "Repository.HealthCheck.MasterDB"
+	HealthCheckCircuitBreakersOperation = "Repository.HealthCheck.CircuitBreakers"
 )
 
+// masterBreaker of the healthcheck, a failing database answers the health checks without waiting on it
+const masterBreaker = "health-master"
+
 type IHealthCheckRepository interface {
 	HealthCheckMasterDB(ctx context.Context) (isOk bool, err error)
-	HealthCheckSlaveDB(ctx context.Context) (isOk bool, err error)
+	HealthCheckReplicas(ctx context.Context) []mysql.ReplicaStatus
+	HealthCheckCircuitBreakers(ctx context.Context) []breaker.State
 }
 
 // HealthCheckRepository type
 type HealthCheckRepository struct {
 	MasterDB *sqlx.DB
-	SlaveDB  *sqlx.DB
+	Replicas *mysql.ReplicaSet
 }
 
 // NewHealthCheckRepository creates new HealthCheckRepository.
-func NewHealthCheckRepository(masterDB *sqlx.DB, slaveDB *sqlx.DB) (*HealthCheckRepository, error) {
+func NewHealthCheckRepository(masterDB *sqlx.DB, replicas *mysql.ReplicaSet) (*HealthCheckRepository, error) {
 	if masterDB == nil {
 		return nil, errors.New("the master DB connection is nil")
 	}
 
-	if slaveDB == nil {
-		return nil, errors.New("the slave DB connection is nil")
+	if replicas == nil {
+		return nil, errors.New("the replica set is nil")
 	}
 
 	r := &HealthCheckRepository{}
 	r.MasterDB = masterDB
-	r.SlaveDB = slaveDB
+	r.Replicas = replicas
 	return r, nil
 }
 
 // HealthCheckMasterDB used for MasterDB healthcheck
 func (r *HealthCheckRepository) HealthCheckMasterDB(ctx context.Context) (isOk bool, err error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, HealthCheckMasterDBOperation)
+	span, ctx := opentracing.StartSpanFromContext(ctx, HealthCheckMasterDBOperation)
 	defer span.Finish()
 
-	err = r.MasterDB.Ping()
+	err = breaker.Do(ctx, masterBreaker, func(ctx context.Context) error {
+		return r.MasterDB.PingContext(ctx)
+	})
 	if err != nil {
 		return false, err
 	}
@@ -55,15 +64,19 @@ func (r *HealthCheckRepository) HealthCheckMasterDB(ctx context.Context) (isOk b
 	return true, nil
 }
 
-// HealthCheckSlaveDB used for SlaveDB healthcheck
-func (r *HealthCheckRepository) HealthCheckSlaveDB(ctx context.Context) (isOk bool, err error) {
+// HealthCheckReplicas used for the read replicas healthcheck, it reports the last check of the replicas:
+// the routing only follows the checks of the ReplicaSet Watch, never the deadline of a probe
+func (r *HealthCheckRepository) HealthCheckReplicas(ctx context.Context) []mysql.ReplicaStatus {
 	span, _ := opentracing.StartSpanFromContext(ctx, HealthCheckSlaveDBOperation)
 	defer span.Finish()
 
-	err = r.SlaveDB.Ping()
-	if err != nil {
-		return false, err
-	}
+	return r.Replicas.Statuses()
+}
 
-	return true, nil
+// HealthCheckCircuitBreakers used for the state of the circuit breakers
+func (r *HealthCheckRepository) HealthCheckCircuitBreakers(ctx context.Context) []breaker.State {
+	span, _ := opentracing.StartSpanFromContext(ctx, HealthCheckCircuitBreakersOperation)
+	defer span.Finish()
+
+	return breaker.States()
 }
@@ -2,6 +2,10 @@ package mocks
 
 import (
 	"context"
+	"errors"
+
+	"github.com/go-rest-api/pkg/breaker"
+	"github.com/go-rest-api/pkg/mysql"
 )
 
 type HealthCheckRepositoryHealthyMock struct {
@@ -10,8 +14,11 @@ type HealthCheckRepositoryHealthyMock struct {
 func (s *HealthCheckRepositoryHealthyMock) HealthCheckMasterDB(ctx context.Context) (isOk bool, err error) {
 	return true, nil
 }
-func (s *HealthCheckRepositoryHealthyMock) HealthCheckSlaveDB(ctx context.Context) (isOk bool, err error) {
-	return true, nil
+func (s *HealthCheckRepositoryHealthyMock) HealthCheckReplicas(ctx context.Context) []mysql.ReplicaStatus {
+	return []mysql.ReplicaStatus{{Name: "slave-0", Healthy: true}, {Name: "slave-1", Healthy: true}}
+}
+func (s *HealthCheckRepositoryHealthyMock) HealthCheckCircuitBreakers(ctx context.Context) []breaker.State {
+	return []breaker.State{{Name: "mysql-master"}, {Name: "mysql-slave-0"}}
 }
 
 type HealthCheckRepositoryUnhealthyMock struct {
@@ -20,8 +27,11 @@ type HealthCheckRepositoryUnhealthyMock struct {
 func (s *HealthCheckRepositoryUnhealthyMock) HealthCheckMasterDB(ctx context.Context) (isOk bool, err error) {
 	return false, nil
 }
-func (s *HealthCheckRepositoryUnhealthyMock) HealthCheckSlaveDB(ctx context.Context) (isOk bool, err error) {
-	return false, nil
+func (s *HealthCheckRepositoryUnhealthyMock) HealthCheckReplicas(ctx context.Context) []mysql.ReplicaStatus {
+	return []mysql.ReplicaStatus{{Name: "slave-0", Err: errors.New("unhealthy")}}
+}
+func (s *HealthCheckRepositoryUnhealthyMock) HealthCheckCircuitBreakers(ctx context.Context) []breaker.State {
+	return []breaker.State{{Name: "mysql-master", Open: true}}
 }
 
 type HealthCheckRepositoryPanicMock struct {
@@ -30,6 +40,9 @@ type HealthCheckRepositoryPanicMock struct {
 func (s *HealthCheckRepositoryPanicMock) HealthCheckMasterDB(ctx context.Context) (isOk bool, err error) {
 	panic(true)
 }
-func (s *HealthCheckRepositoryPanicMock) HealthCheckSlaveDB(ctx context.Context) (isOk bool, err error) {
+func (s *HealthCheckRepositoryPanicMock) HealthCheckReplicas(ctx context.Context) []mysql.ReplicaStatus {
+	panic(true)
+}
+func (s *HealthCheckRepositoryPanicMock) HealthCheckCircuitBreakers(ctx context.Context) []breaker.State {
 	panic(true)
 }
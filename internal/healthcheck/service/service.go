@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/go-rest-api/internal/healthcheck/repository"
+	"github.com/go-rest-api/pkg/readonly"
 	"sync"
 
 	"github.com/opentracing/opentracing-go"
@@ -45,23 +46,36 @@ type healthItem struct {
 	Remarks        string `json:"remarks"`
 }
 
+// Mode Constants
+const (
+	// Modes of the writes
+	ReadWriteMode = "read_write"
+	ReadOnlyMode  = "read_only"
+)
+
 // InfrastructureHealthCheckResponse type
 type InfrastructureHealthCheckResponse struct {
 	Items  []healthItem `json:"items"`
 	Result string       `json:"result"`
-	IsOk   bool         `json:"-"`
-	Mutex  *sync.Mutex  `json:"-"`
+	// Mode is read_write, or read_only while the writes are refused
+	Mode     string          `json:"mode"`
+	ReadOnly readonly.Status `json:"read_only"`
+	IsOk     bool            `json:"-"`
+	Mutex    *sync.Mutex     `json:"-"`
 }
 
 // HealthCheckService type
 type HealthCheckService struct {
 	repo repository.IHealthCheckRepository
+	mode *readonly.Mode
 }
 
-// NewHealthCheckService used for initiate HealthCheckService
-func NewHealthCheckService(repo repository.IHealthCheckRepository) (*HealthCheckService, error) {
+// NewHealthCheckService used for initiate HealthCheckService, a nil mode is always read_write.
+// A nil repo has no database to check, e.g. with the in-memory storage.
+func NewHealthCheckService(repo repository.IHealthCheckRepository, mode *readonly.Mode) (*HealthCheckService, error) {
 	return &HealthCheckService{
 		repo: repo,
+		mode: mode,
 	}, nil
 }
 
@@ -82,10 +96,19 @@ func (s *HealthCheckService) Infrastructure(ctx context.Context) InfrastructureH
 	)
 
 	healthResult.Mutex = &sync.Mutex{}
-	s.getMasterDBStatus(ctx, &healthResult, &wg)
-	s.getSlaveDBStatus(ctx, &healthResult, &wg)
+	if s.repo != nil {
+		s.getMasterDBStatus(ctx, &healthResult, &wg)
+		s.getSlaveDBStatus(ctx, &healthResult, &wg)
+		s.getCircuitBreakerStatus(ctx, &healthResult, &wg)
+	}
 	wg.Wait()
 	healthResult.examineHealth()
+
+	healthResult.Mode = ReadWriteMode
+	healthResult.ReadOnly = s.mode.Status()
+	if healthResult.ReadOnly.ReadOnly {
+		healthResult.Mode = ReadOnlyMode
+	}
 	return healthResult
 }
 
@@ -119,7 +142,7 @@ func (s *HealthCheckService) getMasterDBStatus(ctx context.Context, healthRespon
 	}()
 }
 
-// getSlaveDBStatus get slave database status
+// getSlaveDBStatus get the status of every read replica
 func (s *HealthCheckService) getSlaveDBStatus(ctx context.Context, healthResponse *InfrastructureHealthCheckResponse, wg *sync.WaitGroup) {
 	wg.Add(1)
 	go func() {
@@ -131,20 +154,60 @@ func (s *HealthCheckService) getSlaveDBStatus(ctx context.Context, healthRespons
 			}
 		}()
 
-		item := healthItem{
-			Name:           "Slave Database SQL",
-			DependencyType: hardDependencyType,
-			IsHealthy:      true,
-			Remarks:        "",
+		statuses := s.repo.HealthCheckReplicas(ctx)
+
+		// The reads survive the loss of some replicas, not of all of them
+		dependencyType := hardDependencyType
+		for _, status := range statuses {
+			if status.Healthy {
+				dependencyType = softDependencyType
+			}
 		}
 
-		isOk, err := s.repo.HealthCheckSlaveDB(ctx)
-		if !isOk {
-			item.IsHealthy = false
-			item.Remarks = fmt.Sprint(err)
+		for _, status := range statuses {
+			item := healthItem{
+				Name:           fmt.Sprintf("Slave Database SQL (%s)", status.Name),
+				DependencyType: dependencyType,
+				IsHealthy:      status.Healthy,
+				Remarks:        "",
+			}
+			if !status.Healthy {
+				item.Remarks = fmt.Sprint(status.Err)
+			} else if status.Lag > 0 {
+				item.Remarks = fmt.Sprintf("replication lag %s", status.Lag)
+			}
+
+			healthResponse.addItem(item)
 		}
+		wg.Done()
+	}()
+}
 
-		healthResponse.addItem(item)
+// getCircuitBreakerStatus get the state of every circuit breaker, an open one fails its calls fast
+func (s *HealthCheckService) getCircuitBreakerStatus(ctx context.Context, healthResponse *InfrastructureHealthCheckResponse, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer func() {
+			err := recover()
+			if err != nil {
+				logger.Error(fmt.Errorf("panic: %v", err))
+				wg.Done()
+			}
+		}()
+
+		for _, state := range s.repo.HealthCheckCircuitBreakers(ctx) {
+			item := healthItem{
+				Name:           fmt.Sprintf("Circuit Breaker (%s)", state.Name),
+				DependencyType: softDependencyType,
+				IsHealthy:      !state.Open,
+				Remarks:        "closed",
+			}
+			if state.Open {
+				item.Remarks = "open"
+			}
+
+			healthResponse.addItem(item)
+		}
 		wg.Done()
 	}()
 }
@@ -2,23 +2,30 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/asaskevich/govalidator"
 	"github.com/go-rest-api/internal/movie/entity"
 	"github.com/go-rest-api/internal/movie/service"
+	"github.com/go-rest-api/pkg/httpcache"
 	"github.com/go-rest-api/pkg/response"
 	"github.com/gorilla/mux"
 	"github.com/opentracing/opentracing-go"
 	nethttp "net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type MovieHandler struct {
 	service service.MovieServiceFactory
+	stream  nethttp.Handler
 }
 
-func NewMovieHandler(service service.MovieServiceFactory) (*MovieHandler, error) {
+func NewMovieHandler(service service.MovieServiceFactory, stream nethttp.Handler) (*MovieHandler, error) {
 	return &MovieHandler{
 		service: service,
+		stream:  stream,
 	}, nil
 }
 
@@ -26,13 +33,33 @@ func (m *MovieHandler) GetAllMovies(w nethttp.ResponseWriter, r *nethttp.Request
 	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
 	defer span.Finish()
 
-	movies, err := m.service.GetAllMovies(ctx)
+	query, err := parseMovieQuery(r)
 	if err != nil {
-		response.WriteAPIErrorMessage(w, response.APIInternalError)
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	movies, err := m.service.GetAllMovies(ctx, query)
+	if errors.Is(err, service.ErrInvalidQuery) {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
 		return
 	}
+	if err != nil {
+		response.WriteAPIServerError(w, err)
+		return
+	}
+
+	// The embedded resources change without the updated_at of the movies, only the ETag validates them
+	var lastModified time.Time
+	if len(query.Include) == 0 {
+		for _, movie := range movies {
+			if movie.UpdatedAt.After(lastModified) {
+				lastModified = movie.UpdatedAt
+			}
+		}
+	}
 
-	response.WriteAPIOKWithData(w, movies)
+	httpcache.WriteJSON(w, r, lastModified, movies)
 }
 
 func (m *MovieHandler) GetMovie(w nethttp.ResponseWriter, r *nethttp.Request) {
@@ -46,13 +73,41 @@ func (m *MovieHandler) GetMovie(w nethttp.ResponseWriter, r *nethttp.Request) {
 		return
 	}
 
-	movie, err := m.service.GetMovie(ctx, movieId)
+	query, err := parseMovieQuery(r)
 	if err != nil {
-		response.WriteAPIErrorMessage(w, response.APIInternalError)
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
 		return
 	}
 
-	response.WriteAPIOKWithData(w, movie)
+	movie, err := m.service.GetMovie(ctx, movieId, query)
+	var moved *service.MovedError
+	if errors.As(err, &moved) {
+		location := fmt.Sprintf("/v1/movies/%d", moved.MovieID)
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+		nethttp.Redirect(w, r, location, nethttp.StatusMovedPermanently)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidQuery) {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+	if errors.Is(err, service.ErrMovieNotFound) {
+		response.WriteAPIError(w, response.APIErrNotFound, err)
+		return
+	}
+	if err != nil {
+		response.WriteAPIServerError(w, err)
+		return
+	}
+
+	lastModified := movie.UpdatedAt
+	if len(query.Include) > 0 {
+		lastModified = time.Time{}
+	}
+
+	httpcache.WriteJSON(w, r, lastModified, movie)
 }
 
 func (m *MovieHandler) SaveMovie(w nethttp.ResponseWriter, r *nethttp.Request) {
@@ -74,9 +129,217 @@ func (m *MovieHandler) SaveMovie(w nethttp.ResponseWriter, r *nethttp.Request) {
 
 	movie, err := m.service.SaveMovie(ctx, payload)
 	if err != nil {
-		response.WriteAPIErrorMessage(w, response.APIInternalError)
+		response.WriteAPIServerError(w, err)
 		return
 	}
 
 	response.WriteAPIOKWithData(w, movie)
 }
+
+// StreamMovies pushes the catalogue changes as Server-Sent Events
+func (m *MovieHandler) StreamMovies(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, _ := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	m.stream.ServeHTTP(w, r)
+}
+
+// FindDuplicates lists the likely duplicates of the movie, ?min_score= overrides the default threshold
+func (m *MovieHandler) FindDuplicates(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	params := mux.Vars(r)
+	movieId, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	minScore := service.DefaultDuplicateScore
+	if v := r.URL.Query().Get("min_score"); v != "" {
+		minScore, err = strconv.ParseFloat(v, 64)
+		if err != nil || minScore < 0 || minScore > 1 {
+			response.WriteAPIError(w, response.APIErrorBadRequest, "min_score must be between 0 and 1")
+			return
+		}
+	}
+
+	duplicates, err := m.service.FindDuplicates(ctx, movieId, minScore)
+	if errors.Is(err, service.ErrMovieNotFound) {
+		response.WriteAPIError(w, response.APIErrNotFound, err)
+		return
+	}
+	if err != nil {
+		response.WriteAPIServerError(w, err)
+		return
+	}
+
+	response.WriteAPIOKWithData(w, duplicates)
+}
+
+// MergeMovie merges the movie of the payload into the path movie
+func (m *MovieHandler) MergeMovie(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	params := mux.Vars(r)
+	movieId, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	var payload entity.MergeReq
+	err = json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	isValid, err := govalidator.ValidateStruct(payload)
+	if !isValid {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	movie, err := m.service.MergeMovies(ctx, movieId, payload.SourceID)
+	if errors.Is(err, service.ErrMergeSelf) {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+	if errors.Is(err, service.ErrMovieNotFound) {
+		response.WriteAPIError(w, response.APIErrNotFound, err)
+		return
+	}
+	if err != nil {
+		response.WriteAPIServerError(w, err)
+		return
+	}
+
+	response.WriteAPIOKWithData(w, movie)
+}
+
+// GetMovieStats returns the catalogue statistics
+func (m *MovieHandler) GetMovieStats(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	values := r.URL.Query()
+	query := entity.MovieStatsQuery{
+		MovieStatsFilter: entity.MovieStatsFilter{
+			Genre: values.Get("genre"),
+		},
+		GroupBy: values.Get("group_by"),
+	}
+
+	// The parameters are checked in order, the first invalid one is reported
+	for _, param := range []struct {
+		name   string
+		target *int
+	}{
+		{"min_duration", &query.MinDuration},
+		{"max_duration", &query.MaxDuration},
+		{"bucket_size", &query.BucketSize},
+	} {
+		if v := values.Get(param.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				response.WriteAPIError(w, response.APIErrorBadRequest, fmt.Sprintf("invalid %s", param.name))
+				return
+			}
+			*param.target = n
+		}
+	}
+
+	stats, err := m.service.GetMovieStats(ctx, query)
+	if errors.Is(err, service.ErrInvalidQuery) {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+	if err != nil {
+		response.WriteAPIServerError(w, err)
+		return
+	}
+
+	response.WriteAPIOKWithData(w, stats)
+}
+
+// LookupMovie finds a movie by its third-party identifier, ?provider=imdb&id=tt0133093
+func (m *MovieHandler) LookupMovie(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	values := r.URL.Query()
+	movie, err := m.service.LookupMovie(ctx, values.Get("provider"), values.Get("id"))
+	if errors.Is(err, service.ErrInvalidExternalID) {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+	if errors.Is(err, service.ErrMovieNotFound) {
+		response.WriteAPIError(w, response.APIErrNotFound, err)
+		return
+	}
+	if err != nil {
+		response.WriteAPIServerError(w, err)
+		return
+	}
+
+	response.WriteAPIOKWithData(w, movie)
+}
+
+// ImportMovies upserts a batch of movies by their external IDs
+func (m *MovieHandler) ImportMovies(w nethttp.ResponseWriter, r *nethttp.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "")
+	defer span.Finish()
+
+	var payload entity.MovieImportReq
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	results, err := m.service.ImportMovies(ctx, payload.Movies)
+	if errors.Is(err, service.ErrImportTooLarge) {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+	if err != nil {
+		response.WriteAPIServerError(w, err)
+		return
+	}
+
+	response.WriteAPIOKWithData(w, results)
+}
+
+// parseMovieQuery reads the ?fields=, ?include= and ?ids= parameters
+func parseMovieQuery(r *nethttp.Request) (entity.MovieQuery, error) {
+	var query entity.MovieQuery
+
+	values := r.URL.Query()
+	query.Fields = splitList(values.Get("fields"))
+	query.Include = splitList(values.Get("include"))
+
+	for _, v := range splitList(values.Get("ids")) {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return query, fmt.Errorf("invalid id %q", v)
+		}
+		query.IDs = append(query.IDs, id)
+	}
+
+	return query, nil
+}
+
+// splitList splits a comma separated query parameter
+func splitList(v string) []string {
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
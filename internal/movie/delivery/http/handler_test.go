@@ -0,0 +1,103 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-rest-api/internal/movie/entity"
+	"github.com/go-rest-api/internal/movie/service"
+	"github.com/go-rest-api/pkg/response"
+	"github.com/gorilla/mux"
+)
+
+// fakeService serves fixed movies, the other methods are not implemented
+type fakeService struct {
+	service.MovieServiceFactory
+	movies []entity.MovieDoc
+}
+
+func (f *fakeService) GetAllMovies(ctx context.Context, query entity.MovieQuery) ([]entity.MovieDoc, error) {
+	return f.movies, nil
+}
+
+func (f *fakeService) GetMovie(ctx context.Context, movieId int64, query entity.MovieQuery) (entity.MovieDoc, error) {
+	return f.movies[0], nil
+}
+
+func newTestHandler(t *testing.T, svc service.MovieServiceFactory) *MovieHandler {
+	m, err := NewMovieHandler(svc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func TestLastModifiedLeftOutWithIncludes(t *testing.T) {
+	updatedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	m := newTestHandler(t, &fakeService{movies: []entity.MovieDoc{
+		{Fields: map[string]interface{}{"id": 1}, UpdatedAt: updatedAt},
+		{Fields: map[string]interface{}{"id": 2}, UpdatedAt: updatedAt.Add(-time.Hour)},
+	}})
+
+	tests := []struct {
+		name    string
+		handler nethttp.HandlerFunc
+		target  string
+		want    string
+		status  int
+	}{
+		{"list", m.GetAllMovies, "/v1/movies", "Thu, 01 Oct 2026 12:00:00 GMT", nethttp.StatusNotModified},
+		{"list with credits", m.GetAllMovies, "/v1/movies?include=credits", "", nethttp.StatusOK},
+		{"movie", m.GetMovie, "/v1/movies/1", "Thu, 01 Oct 2026 12:00:00 GMT", nethttp.StatusNotModified},
+		{"movie with credits", m.GetMovie, "/v1/movies/1?include=credits", "", nethttp.StatusOK},
+	}
+
+	for _, tt := range tests {
+		r := mux.SetURLVars(httptest.NewRequest(nethttp.MethodGet, tt.target, nil), map[string]string{"id": "1"})
+		// A client revalidating on the date only gets the embedded resources again
+		r.Header.Set("If-Modified-Since", updatedAt.Format(nethttp.TimeFormat))
+		w := httptest.NewRecorder()
+		tt.handler(w, r)
+
+		if lm := w.Header().Get("Last-Modified"); lm != tt.want {
+			t.Errorf("%s: Last-Modified = %q, want %q", tt.name, lm, tt.want)
+		}
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+}
+
+func TestGetMovieStatsReportsTheFirstInvalidParameter(t *testing.T) {
+	m := newTestHandler(t, &fakeService{})
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/v1/movies/stats?min_duration=short&max_duration=long&bucket_size=big", "invalid min_duration"},
+		{"/v1/movies/stats?min_duration=60&max_duration=long&bucket_size=big", "invalid max_duration"},
+		{"/v1/movies/stats?bucket_size=big", "invalid bucket_size"},
+	}
+
+	for _, tt := range tests {
+		// The report does not depend on the run
+		for i := 0; i < 10; i++ {
+			w := httptest.NewRecorder()
+			m.GetMovieStats(w, httptest.NewRequest(nethttp.MethodGet, tt.target, nil))
+
+			var body response.APIResponse
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != nethttp.StatusBadRequest || body.Message != tt.want {
+				t.Fatalf("%s: status %d and message %v, want 400 %q", tt.target, w.Code, body.Message, tt.want)
+			}
+		}
+	}
+}
@@ -1,8 +1,49 @@
 package entity
 
+import "time"
+
 type MovieRepo struct {
-	ID       int64  `db:"id" json:"id"`
-	Name     string `db:"name" json:"name" valid:"required"`
-	Duration int    `db:"duration" json:"duration" valid:"required,range(1|1000)"`
-	Genre    string `db:"genre" json:"genre" valid:"required"`
+	ID        int64     `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name" valid:"required"`
+	Duration  int       `db:"duration" json:"duration" valid:"required,range(1|1000)"`
+	Genre     string    `db:"genre" json:"genre" valid:"required"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// MovieStatsFilter restricts the movies aggregated by the stats queries
+type MovieStatsFilter struct {
+	Genre       string
+	MinDuration int
+	MaxDuration int
+}
+
+// DurationStatsRepo is the duration aggregate of a group of movies
+type DurationStatsRepo struct {
+	Group string  `db:"group_key"`
+	Count int64   `db:"count"`
+	Min   int     `db:"min"`
+	Max   int     `db:"max"`
+	Avg   float64 `db:"avg"`
+}
+
+// HistogramBucketRepo is the number of movies whose duration starts at the bucket
+type HistogramBucketRepo struct {
+	Bucket int   `db:"bucket"`
+	Count  int64 `db:"count"`
+}
+
+// ExternalIDRepo is the identifier of a movie in a third-party catalogue
+type ExternalIDRepo struct {
+	MovieID    int64  `db:"movie_id"`
+	Provider   string `db:"provider"`
+	ExternalID string `db:"external_id"`
+}
+
+// CreditRepo is a person credited on a movie, in billing order
+type CreditRepo struct {
+	MovieID  int64  `db:"movie_id"`
+	Position int    `db:"position"`
+	Role     string `db:"role"`
+	Name     string `db:"name"`
 }
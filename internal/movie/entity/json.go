@@ -1,5 +1,10 @@
 package entity
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type MovieReq struct {
 	Name     string `json:"name"`
 	Duration int    `json:"duration"`
@@ -7,8 +12,120 @@ type MovieReq struct {
 }
 
 type MovieResp struct {
-	ID       int64  `json:"id"`
-	Name     string `json:"name"`
-	Duration int    `json:"duration"`
-	Genre    string `json:"genre"`
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Duration  int       `json:"duration"`
+	Genre     string    `json:"genre"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MovieFields are the fields a client can select with ?fields=
+var MovieFields = []string{"id", "name", "duration", "genre", "created_at", "updated_at"}
+
+// MovieQuery is the projection requested by the client
+type MovieQuery struct {
+	IDs     []int64
+	Fields  []string
+	Include []string
+}
+
+// MovieDoc is a movie projected to the requested fields with its embedded resources
+type MovieDoc struct {
+	Fields map[string]interface{}
+	// UpdatedAt is always loaded to build the Last-Modified header, left out with embedded resources
+	UpdatedAt time.Time
+}
+
+// MarshalJSON encodes only the projected fields
+func (d MovieDoc) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Fields)
+}
+
+// MergeReq is the payload of the merge endpoint, the source movie is merged into the path movie
+type MergeReq struct {
+	SourceID int64 `json:"source_id" valid:"required"`
+}
+
+// MovieDuplicate is a candidate duplicate with its similarity scores
+type MovieDuplicate struct {
+	Movie         MovieResp `json:"movie"`
+	Score         float64   `json:"score"`
+	TitleScore    float64   `json:"title_score"`
+	DurationScore float64   `json:"duration_score"`
+	GenreScore    float64   `json:"genre_score"`
+}
+
+// MovieStatsQuery is the filters and grouping of the stats endpoint
+type MovieStatsQuery struct {
+	MovieStatsFilter
+	// GroupBy is empty or "genre"
+	GroupBy string
+	// BucketSize is the width in minutes of the duration histogram buckets
+	BucketSize int
+}
+
+// DurationStats is the duration aggregate of a group of movies
+type DurationStats struct {
+	Count int64   `json:"count"`
+	Min   int     `json:"min"`
+	Max   int     `json:"max"`
+	Avg   float64 `json:"avg"`
+}
+
+// GenreCount is the number of movies of a genre
+type GenreCount struct {
+	Genre string `json:"genre"`
+	Count int64  `json:"count"`
+}
+
+// HistogramBucket is the number of movies whose duration is in [From, To)
+type HistogramBucket struct {
+	From  int   `json:"from"`
+	To    int   `json:"to"`
+	Count int64 `json:"count"`
+}
+
+// MovieStats is the catalogue statistics
+type MovieStats struct {
+	Duration  DurationStats            `json:"duration"`
+	Genres    []GenreCount             `json:"genres"`
+	Histogram []HistogramBucket        `json:"histogram"`
+	Groups    map[string]DurationStats `json:"groups,omitempty"`
+}
+
+// Movie import statuses
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportFailed  = "failed"
+)
+
+// MovieImportReq is a batch of movies from a third-party catalogue
+type MovieImportReq struct {
+	Movies []MovieImportItem `json:"movies"`
+}
+
+// MovieImportItem is an imported movie with its identifiers per provider, e.g. {"imdb": "tt0133093"},
+// and its credits in billing order. The credits of the movie are kept when omitted and replaced when given.
+type MovieImportItem struct {
+	Name        string            `json:"name" valid:"required"`
+	Duration    int               `json:"duration" valid:"required,range(1|1000)"`
+	Genre       string            `json:"genre" valid:"required"`
+	ExternalIDs map[string]string `json:"external_ids"`
+	Credits     []MovieCredit     `json:"credits"`
+}
+
+// MovieCredit is a person credited on a movie, e.g. {"name": "Ridley Scott", "role": "director"}
+type MovieCredit struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// MovieImportResult is the outcome of one imported movie
+type MovieImportResult struct {
+	Index  int    `json:"index"`
+	ID     int64  `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
 }
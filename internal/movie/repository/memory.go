@@ -0,0 +1,532 @@
+package repository
+
+import (
+	"context"
+	"github.com/go-rest-api/internal/movie/entity"
+	"github.com/go-rest-api/pkg/mysql"
+	"github.com/go-rest-api/pkg/tenant"
+	"github.com/opentracing/opentracing-go"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryTenant holds the rows of a tenant
+type memoryTenant struct {
+	movies    map[int64]entity.MovieRepo
+	redirects map[int64]int64
+	// externalIds are the IDs per provider of every movie
+	externalIds map[int64]map[string]string
+	// credits are the credits of every movie, in billing order
+	credits map[int64][]entity.CreditRepo
+}
+
+// MemoryMovieRepository keeps the movies in memory with the semantics of the MySQL repository:
+// the IDs increase across the tenants and are never reused, the missing movies of the tenant
+// return ErrMovieNotFound and the lists are ordered by ID. It is lost on restart.
+type MemoryMovieRepository struct {
+	mu      sync.RWMutex
+	lastId  int64
+	tenants map[string]*memoryTenant
+}
+
+// NewMemoryMovieRepository creates new empty MemoryMovieRepository
+func NewMemoryMovieRepository() (*MemoryMovieRepository, error) {
+	return &MemoryMovieRepository{tenants: make(map[string]*memoryTenant)}, nil
+}
+
+// tenant returns the rows of the context tenant, created on write
+func (m *MemoryMovieRepository) tenant(ctx context.Context, write bool) (*memoryTenant, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, mysql.ErrTenantRequired
+	}
+
+	t, ok := m.tenants[tenantID]
+	if !ok {
+		t = &memoryTenant{
+			movies:      make(map[int64]entity.MovieRepo),
+			redirects:   make(map[int64]int64),
+			externalIds: make(map[int64]map[string]string),
+			credits:     make(map[int64][]entity.CreditRepo),
+		}
+		if write {
+			m.tenants[tenantID] = t
+		}
+	}
+
+	return t, nil
+}
+
+func (m *MemoryMovieRepository) GetAllMovies(ctx context.Context, columns []string) ([]entity.MovieRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	return m.filterMovies(ctx, columns, func(entity.MovieRepo) bool { return true })
+}
+
+func (m *MemoryMovieRepository) GetMovie(ctx context.Context, movieId int64, columns []string) (entity.MovieRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	var movie entity.MovieRepo
+	if _, err := buildSelectColumns(columns); err != nil {
+		return movie, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, err := m.tenant(ctx, false)
+	if err != nil {
+		return movie, err
+	}
+
+	found, ok := t.movies[movieId]
+	if !ok {
+		return movie, ErrMovieNotFound
+	}
+
+	return projectColumns(found, columns), nil
+}
+
+// GetMoviesByIDs fetches the movies with the given IDs, ordered by ID
+func (m *MemoryMovieRepository) GetMoviesByIDs(ctx context.Context, movieIds []int64, columns []string) ([]entity.MovieRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	if len(movieIds) == 0 {
+		return nil, nil
+	}
+
+	ids := make(map[int64]bool, len(movieIds))
+	for _, id := range movieIds {
+		ids[id] = true
+	}
+
+	return m.filterMovies(ctx, columns, func(movie entity.MovieRepo) bool { return ids[movie.ID] })
+}
+
+func (m *MemoryMovieRepository) SaveMovie(ctx context.Context, movieRepo entity.MovieRepo) (entity.MovieRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.tenant(ctx, true)
+	if err != nil {
+		return movieRepo, err
+	}
+
+	return m.insert(t, movieRepo), nil
+}
+
+// GetDuplicateCandidates fetches the other movies whose duration is within the window of the given movie
+func (m *MemoryMovieRepository) GetDuplicateCandidates(ctx context.Context, movieRepo entity.MovieRepo, durationWindow int) ([]entity.MovieRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	return m.filterMovies(ctx, nil, func(movie entity.MovieRepo) bool {
+		return movie.ID != movieRepo.ID &&
+			movie.Duration >= movieRepo.Duration-durationWindow && movie.Duration <= movieRepo.Duration+durationWindow
+	})
+}
+
+// GetMovieRedirect returns the movie that survived the merge of the given movie
+func (m *MemoryMovieRepository) GetMovieRedirect(ctx context.Context, movieId int64) (int64, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, err := m.tenant(ctx, false)
+	if err != nil {
+		return 0, err
+	}
+
+	toId, ok := t.redirects[movieId]
+	if !ok {
+		return 0, ErrMovieNotFound
+	}
+
+	return toId, nil
+}
+
+// MergeMovies moves the external IDs and the credits of the source movie to the target, leaves a redirect
+// from the source ID and deletes the source
+func (m *MemoryMovieRepository) MergeMovies(ctx context.Context, targetId int64, sourceId int64) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.tenant(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	target, ok := t.movies[targetId]
+	if _, found := t.movies[sourceId]; !ok || !found || targetId == sourceId {
+		return ErrMovieNotFound
+	}
+
+	// The IDs of a provider the target already has stay behind and are removed
+	for provider, externalId := range t.externalIds[sourceId] {
+		if _, ok := t.externalIds[targetId][provider]; !ok {
+			setExternalID(t, targetId, provider, externalId)
+		}
+	}
+	delete(t.externalIds, sourceId)
+
+	// The people the target already credits in the same role stay behind and are removed
+	for _, credit := range t.credits[sourceId] {
+		if !hasCredit(t.credits[targetId], credit) {
+			credit.MovieID = targetId
+			t.credits[targetId] = append(t.credits[targetId], credit)
+		}
+	}
+	sortCredits(t.credits[targetId])
+	delete(t.credits, sourceId)
+
+	for from, to := range t.redirects {
+		if to == sourceId {
+			t.redirects[from] = targetId
+		}
+	}
+	t.redirects[sourceId] = targetId
+
+	delete(t.movies, sourceId)
+
+	target.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	t.movies[targetId] = target
+	return nil
+}
+
+// GetGenreDurationStats aggregates the duration of the movies per genre column value, ordered by genre
+func (m *MemoryMovieRepository) GetGenreDurationStats(ctx context.Context, filter entity.MovieStatsFilter) ([]entity.DurationStatsRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	movies, err := m.filterMovies(ctx, nil, statsFilter(filter))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		stats  []entity.DurationStatsRepo
+		groups = make(map[string]int)
+	)
+	for _, movie := range movies {
+		i, ok := groups[movie.Genre]
+		if !ok {
+			i = len(stats)
+			groups[movie.Genre] = i
+			stats = append(stats, entity.DurationStatsRepo{Group: movie.Genre, Min: movie.Duration, Max: movie.Duration})
+		}
+
+		s := &stats[i]
+		s.Avg = (s.Avg*float64(s.Count) + float64(movie.Duration)) / float64(s.Count+1)
+		s.Count++
+		if movie.Duration < s.Min {
+			s.Min = movie.Duration
+		}
+		if movie.Duration > s.Max {
+			s.Max = movie.Duration
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Group < stats[j].Group })
+	return stats, nil
+}
+
+// GetDurationHistogram counts the movies per duration bucket, ordered by bucket
+func (m *MemoryMovieRepository) GetDurationHistogram(ctx context.Context, filter entity.MovieStatsFilter, bucketSize int) ([]entity.HistogramBucketRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	movies, err := m.filterMovies(ctx, nil, statsFilter(filter))
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int]int64)
+	for _, movie := range movies {
+		counts[movie.Duration/bucketSize*bucketSize]++
+	}
+
+	var buckets []entity.HistogramBucketRepo
+	for bucket, count := range counts {
+		buckets = append(buckets, entity.HistogramBucketRepo{Bucket: bucket, Count: count})
+	}
+
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Bucket < buckets[j].Bucket })
+	return buckets, nil
+}
+
+// GetMovieByExternalID fetches the movie identified by the provider ID
+func (m *MemoryMovieRepository) GetMovieByExternalID(ctx context.Context, provider string, externalId string) (entity.MovieRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var movie entity.MovieRepo
+	t, err := m.tenant(ctx, false)
+	if err != nil {
+		return movie, err
+	}
+
+	movieId, ok := findExternalID(t, provider, externalId)
+	if !ok {
+		return movie, ErrMovieNotFound
+	}
+
+	return t.movies[movieId], nil
+}
+
+// GetExternalIDs fetches the external IDs of the movies, ordered by movie and provider
+func (m *MemoryMovieRepository) GetExternalIDs(ctx context.Context, movieIds []int64) ([]entity.ExternalIDRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, err := m.tenant(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var externalIds []entity.ExternalIDRepo
+	for _, movieId := range movieIds {
+		for provider, externalId := range t.externalIds[movieId] {
+			externalIds = append(externalIds, entity.ExternalIDRepo{MovieID: movieId, Provider: provider, ExternalID: externalId})
+		}
+	}
+
+	sort.Slice(externalIds, func(i, j int) bool {
+		if externalIds[i].MovieID != externalIds[j].MovieID {
+			return externalIds[i].MovieID < externalIds[j].MovieID
+		}
+		return externalIds[i].Provider < externalIds[j].Provider
+	})
+	return externalIds, nil
+}
+
+// GetCredits fetches the credits of the movies, ordered by movie and billing
+func (m *MemoryMovieRepository) GetCredits(ctx context.Context, movieIds []int64) ([]entity.CreditRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, err := m.tenant(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := append([]int64(nil), movieIds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var credits []entity.CreditRepo
+	for _, movieId := range sorted {
+		credits = append(credits, t.credits[movieId]...)
+	}
+
+	return credits, nil
+}
+
+// UpsertMovie updates the movie already known by one of the external IDs or inserts a new one,
+// then stores the external IDs. The credits of the movie are replaced unless nil.
+// The boolean is true when the movie has been created.
+func (m *MemoryMovieRepository) UpsertMovie(ctx context.Context, movieRepo entity.MovieRepo, externalIds []entity.ExternalIDRepo, credits []entity.CreditRepo) (entity.MovieRepo, bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.tenant(ctx, true)
+	if err != nil {
+		return movieRepo, false, err
+	}
+
+	existing := make(map[int64]bool)
+	for _, externalId := range externalIds {
+		if movieId, ok := findExternalID(t, externalId.Provider, externalId.ExternalID); ok {
+			existing[movieId] = true
+		}
+	}
+
+	if len(existing) > 1 {
+		return movieRepo, false, ErrExternalIDConflict
+	}
+
+	created := len(existing) == 0
+	if created {
+		movieRepo = m.insert(t, movieRepo)
+	} else {
+		for movieId := range existing {
+			movieRepo.ID = movieId
+		}
+		movieRepo.CreatedAt = t.movies[movieRepo.ID].CreatedAt
+		movieRepo.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+		t.movies[movieRepo.ID] = movieRepo
+	}
+
+	// A movie has one ID per provider, a re-import replaces it
+	for _, externalId := range externalIds {
+		setExternalID(t, movieRepo.ID, externalId.Provider, externalId.ExternalID)
+	}
+
+	if credits != nil {
+		replaced := make([]entity.CreditRepo, 0, len(credits))
+		for _, credit := range credits {
+			credit.MovieID = movieRepo.ID
+			replaced = append(replaced, credit)
+		}
+		sortCredits(replaced)
+		t.credits[movieRepo.ID] = replaced
+	}
+
+	return movieRepo, created, nil
+}
+
+// insert stores the movie under the next ID, the write lock is held
+func (m *MemoryMovieRepository) insert(t *memoryTenant, movieRepo entity.MovieRepo) entity.MovieRepo {
+	now := time.Now().UTC().Truncate(time.Second)
+	m.lastId++
+	movieRepo.ID = m.lastId
+	movieRepo.CreatedAt = now
+	movieRepo.UpdatedAt = now
+
+	t.movies[movieRepo.ID] = movieRepo
+	return movieRepo
+}
+
+// filterMovies returns the columns of the tenant movies matching the filter, ordered by ID
+func (m *MemoryMovieRepository) filterMovies(ctx context.Context, columns []string, match func(entity.MovieRepo) bool) ([]entity.MovieRepo, error) {
+	if _, err := buildSelectColumns(columns); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, err := m.tenant(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var movies []entity.MovieRepo
+	for _, movie := range t.movies {
+		if match(movie) {
+			movies = append(movies, projectColumns(movie, columns))
+		}
+	}
+
+	sort.Slice(movies, func(i, j int) bool { return movies[i].ID < movies[j].ID })
+	return movies, nil
+}
+
+// findExternalID returns the movie of the provider ID
+func findExternalID(t *memoryTenant, provider string, externalId string) (int64, bool) {
+	for movieId, ids := range t.externalIds {
+		if ids[provider] == externalId {
+			return movieId, true
+		}
+	}
+
+	return 0, false
+}
+
+// setExternalID replaces the provider ID of the movie
+func setExternalID(t *memoryTenant, movieId int64, provider string, externalId string) {
+	if t.externalIds[movieId] == nil {
+		t.externalIds[movieId] = make(map[string]string)
+	}
+	t.externalIds[movieId][provider] = externalId
+}
+
+// hasCredit reports whether the person is already credited in the role
+func hasCredit(credits []entity.CreditRepo, credit entity.CreditRepo) bool {
+	for _, c := range credits {
+		if c.Role == credit.Role && c.Name == credit.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sortCredits orders the credits of a movie like the MySQL query, by billing then role and name
+func sortCredits(credits []entity.CreditRepo) {
+	sort.Slice(credits, func(i, j int) bool {
+		if credits[i].Position != credits[j].Position {
+			return credits[i].Position < credits[j].Position
+		}
+		if credits[i].Role != credits[j].Role {
+			return credits[i].Role < credits[j].Role
+		}
+		return credits[i].Name < credits[j].Name
+	})
+}
+
+// statsFilter matches the movies of the stats filter, the genre is one of the comma separated values
+// compared case-insensitively like the MySQL collation
+func statsFilter(filter entity.MovieStatsFilter) func(entity.MovieRepo) bool {
+	return func(movie entity.MovieRepo) bool {
+		if filter.MinDuration > 0 && movie.Duration < filter.MinDuration {
+			return false
+		}
+
+		if filter.MaxDuration > 0 && movie.Duration > filter.MaxDuration {
+			return false
+		}
+
+		if filter.Genre == "" {
+			return true
+		}
+
+		for _, genre := range strings.Split(strings.ReplaceAll(movie.Genre, ", ", ","), ",") {
+			if strings.EqualFold(genre, filter.Genre) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// projectColumns keeps the selected columns of the movie, all columns when empty
+func projectColumns(movie entity.MovieRepo, columns []string) entity.MovieRepo {
+	if len(columns) == 0 {
+		return movie
+	}
+
+	var projected entity.MovieRepo
+	for _, c := range columns {
+		switch c {
+		case "id":
+			projected.ID = movie.ID
+		case "name":
+			projected.Name = movie.Name
+		case "duration":
+			projected.Duration = movie.Duration
+		case "genre":
+			projected.Genre = movie.Genre
+		case "created_at":
+			projected.CreatedAt = movie.CreatedAt
+		case "updated_at":
+			projected.UpdatedAt = movie.UpdatedAt
+		}
+	}
+
+	return projected
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/go-rest-api/internal/movie/entity"
+	"github.com/go-rest-api/pkg/mysql"
+	"github.com/go-rest-api/pkg/tenant"
+)
+
+func TestMemoryMovieRepositoryScopesTenants(t *testing.T) {
+	m, _ := NewMemoryMovieRepository()
+	brandA := tenant.WithTenant(context.Background(), "brand-a")
+	brandB := tenant.WithTenant(context.Background(), "brand-b")
+
+	var ids []int64
+	for _, ctx := range []context.Context{brandA, brandB, brandA} {
+		movie, err := m.SaveMovie(ctx, entity.MovieRepo{Name: "Alien", Genre: "Horror", Duration: 117})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, movie.ID)
+	}
+	// The IDs increase across the tenants like an auto increment
+	if !reflect.DeepEqual(ids, []int64{1, 2, 3}) {
+		t.Errorf("SaveMovie IDs = %v, want [1 2 3]", ids)
+	}
+
+	movies, err := m.GetAllMovies(brandA, []string{"id", "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(movies, []entity.MovieRepo{{ID: 1, Name: "Alien"}, {ID: 3, Name: "Alien"}}) {
+		t.Errorf("GetAllMovies = %v, want movies 1 and 3 with the selected columns", movies)
+	}
+
+	if _, err = m.GetMovie(brandA, 2, nil); err != ErrMovieNotFound {
+		t.Errorf("GetMovie of another tenant err = %v, want %v", err, ErrMovieNotFound)
+	}
+
+	if _, err = m.GetAllMovies(brandA, []string{"password"}); err == nil {
+		t.Error("GetAllMovies of an unknown column succeeded")
+	}
+
+	if _, err = m.GetAllMovies(context.Background(), nil); err != mysql.ErrTenantRequired {
+		t.Errorf("GetAllMovies err = %v, want %v", err, mysql.ErrTenantRequired)
+	}
+}
+
+func TestMemoryMovieRepositoryMergesMovies(t *testing.T) {
+	m, _ := NewMemoryMovieRepository()
+	ctx := tenant.WithTenant(context.Background(), "brand-a")
+
+	target, _, err := m.UpsertMovie(ctx, entity.MovieRepo{Name: "The Matrix", Duration: 136, Genre: "Sci-Fi"},
+		[]entity.ExternalIDRepo{{Provider: "imdb", ExternalID: "tt0133093"}},
+		[]entity.CreditRepo{{Position: 0, Role: "director", Name: "Lana Wachowski"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, _, err := m.UpsertMovie(ctx, entity.MovieRepo{Name: "Matrix", Duration: 136, Genre: "Sci-Fi"},
+		[]entity.ExternalIDRepo{{Provider: "imdb", ExternalID: "tt0133094"}, {Provider: "tmdb", ExternalID: "603"}},
+		[]entity.CreditRepo{{Position: 0, Role: "director", Name: "Lana Wachowski"}, {Position: 1, Role: "cast", Name: "Keanu Reeves"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = m.UpsertMovie(ctx, entity.MovieRepo{Name: "Matrix"},
+		[]entity.ExternalIDRepo{{Provider: "imdb", ExternalID: "tt0133093"}, {Provider: "tmdb", ExternalID: "603"}}, nil)
+	if err != ErrExternalIDConflict {
+		t.Errorf("UpsertMovie err = %v, want %v", err, ErrExternalIDConflict)
+	}
+
+	if err = m.MergeMovies(ctx, target.ID, 99); err != ErrMovieNotFound {
+		t.Errorf("MergeMovies of a missing movie err = %v, want %v", err, ErrMovieNotFound)
+	}
+
+	if err = m.MergeMovies(ctx, target.ID, source.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.GetMovie(ctx, source.ID, nil); err != ErrMovieNotFound {
+		t.Errorf("GetMovie of the merged movie err = %v, want %v", err, ErrMovieNotFound)
+	}
+
+	toId, err := m.GetMovieRedirect(ctx, source.ID)
+	if err != nil || toId != target.ID {
+		t.Errorf("GetMovieRedirect = %d, %v, want %d", toId, err, target.ID)
+	}
+
+	// The imdb ID of the target stays, the tmdb ID of the source moves to it
+	externalIds, err := m.GetExternalIDs(ctx, []int64{target.ID, source.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []entity.ExternalIDRepo{
+		{MovieID: target.ID, Provider: "imdb", ExternalID: "tt0133093"},
+		{MovieID: target.ID, Provider: "tmdb", ExternalID: "603"},
+	}
+	if !reflect.DeepEqual(externalIds, want) {
+		t.Errorf("GetExternalIDs = %v, want %v", externalIds, want)
+	}
+
+	// The director credited on both stays once, the cast of the source moves to the target
+	credits, err := m.GetCredits(ctx, []int64{target.ID, source.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCredits := []entity.CreditRepo{
+		{MovieID: target.ID, Position: 0, Role: "director", Name: "Lana Wachowski"},
+		{MovieID: target.ID, Position: 1, Role: "cast", Name: "Keanu Reeves"},
+	}
+	if !reflect.DeepEqual(credits, wantCredits) {
+		t.Errorf("GetCredits = %v, want %v", credits, wantCredits)
+	}
+
+	movie, created, err := m.UpsertMovie(ctx, entity.MovieRepo{Name: "The Matrix", Duration: 138, Genre: "Sci-Fi"},
+		[]entity.ExternalIDRepo{{Provider: "tmdb", ExternalID: "603"}}, nil)
+	if err != nil || created || movie.ID != target.ID || movie.CreatedAt != target.CreatedAt {
+		t.Errorf("UpsertMovie = %v, %t, %v, want an update of movie %d", movie, created, err, target.ID)
+	}
+
+	// A re-import without credits keeps them, an empty list removes them
+	if credits, _ = m.GetCredits(ctx, []int64{target.ID}); len(credits) != 2 {
+		t.Errorf("GetCredits after a re-import without credits = %v, want 2 credits", credits)
+	}
+	_, _, err = m.UpsertMovie(ctx, entity.MovieRepo{Name: "The Matrix", Duration: 138, Genre: "Sci-Fi"},
+		[]entity.ExternalIDRepo{{Provider: "tmdb", ExternalID: "603"}}, []entity.CreditRepo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credits, _ = m.GetCredits(ctx, []int64{target.ID}); len(credits) != 0 {
+		t.Errorf("GetCredits after a re-import with no credits = %v, want none", credits)
+	}
+}
+
+func TestMemoryMovieRepositoryConcurrentWrites(t *testing.T) {
+	m, _ := NewMemoryMovieRepository()
+	ctx := tenant.WithTenant(context.Background(), "brand-a")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = m.SaveMovie(ctx, entity.MovieRepo{Name: "Alien", Genre: "Horror", Duration: 117})
+			_, _ = m.GetAllMovies(ctx, nil)
+		}()
+	}
+	wg.Wait()
+
+	movies, err := m.GetAllMovies(ctx, []string{"id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, movie := range movies {
+		if movie.ID != int64(i+1) {
+			t.Fatalf("movie %d has ID %d, want the IDs 1 to 50 in order", i, movie.ID)
+		}
+	}
+	if len(movies) != 50 {
+		t.Errorf("GetAllMovies returned %d movies, want 50", len(movies))
+	}
+}
@@ -2,48 +2,93 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/go-rest-api/internal/movie/entity"
 	"github.com/go-rest-api/pkg/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/opentracing/opentracing-go"
+	"strings"
+	"time"
 )
 
 type MovieRepositoryFactory interface {
-	GetAllMovies(ctx context.Context) ([]entity.MovieRepo, error)
-	GetMovie(ctx context.Context, movieId int64) (entity.MovieRepo, error)
+	GetAllMovies(ctx context.Context, columns []string) ([]entity.MovieRepo, error)
+	GetMovie(ctx context.Context, movieId int64, columns []string) (entity.MovieRepo, error)
+	GetMoviesByIDs(ctx context.Context, movieIds []int64, columns []string) ([]entity.MovieRepo, error)
 	SaveMovie(ctx context.Context, movieRepo entity.MovieRepo) (entity.MovieRepo, error)
+	GetDuplicateCandidates(ctx context.Context, movieRepo entity.MovieRepo, durationWindow int) ([]entity.MovieRepo, error)
+	GetMovieRedirect(ctx context.Context, movieId int64) (int64, error)
+	MergeMovies(ctx context.Context, targetId int64, sourceId int64) error
+	GetGenreDurationStats(ctx context.Context, filter entity.MovieStatsFilter) ([]entity.DurationStatsRepo, error)
+	GetDurationHistogram(ctx context.Context, filter entity.MovieStatsFilter, bucketSize int) ([]entity.HistogramBucketRepo, error)
+	GetMovieByExternalID(ctx context.Context, provider string, externalId string) (entity.MovieRepo, error)
+	GetExternalIDs(ctx context.Context, movieIds []int64) ([]entity.ExternalIDRepo, error)
+	GetCredits(ctx context.Context, movieIds []int64) ([]entity.CreditRepo, error)
+	UpsertMovie(ctx context.Context, movieRepo entity.MovieRepo, externalIds []entity.ExternalIDRepo, credits []entity.CreditRepo) (entity.MovieRepo, bool, error)
+}
+
+// Repository errors
+var (
+	// ErrMovieNotFound returned when the movie does not exist
+	ErrMovieNotFound = errors.New("movie not found")
+	// ErrExternalIDConflict returned when the external IDs of an upsert belong to different movies
+	ErrExternalIDConflict = errors.New("external ids belong to different movies")
+)
+
+// movieChildTables are the tables referencing a movie through movie_id, they are re-pointed on merge
+var movieChildTables = []string{
+	"collection_items",
+	"movie_credits",
+	"movie_external_ids",
+}
+
+// movieColumns are the columns of the movies table that can be selected
+var movieColumns = map[string]bool{
+	"id":         true,
+	"name":       true,
+	"duration":   true,
+	"genre":      true,
+	"created_at": true,
+	"updated_at": true,
 }
 
 type MovieRepository struct {
 	mysql mysql.BaseRepository
 }
 
-func NewMovieRepository(masterDB *sqlx.DB, slaveDB *sqlx.DB) (*MovieRepository, error) {
+func NewMovieRepository(masterDB *sqlx.DB, replicas *mysql.ReplicaSet, settings *mysql.Settings) (*MovieRepository, error) {
 	if masterDB == nil {
 		return nil, errors.New("the master DB connection is nil")
 	}
 
-	if slaveDB == nil {
-		return nil, errors.New("the slave DB connection is nil")
+	if replicas == nil {
+		return nil, errors.New("the replica set is nil")
 	}
 
 	m := &MovieRepository{}
 	m.mysql.MasterDB = masterDB
-	m.mysql.SlaveDB = slaveDB
+	m.mysql.Replicas = replicas
+	m.mysql.Settings = settings
+	m.mysql.TenantScoped = true
 	return m, nil
 }
 
-func (m *MovieRepository) GetAllMovies(ctx context.Context) ([]entity.MovieRepo, error) {
+func (m *MovieRepository) GetAllMovies(ctx context.Context, columns []string) ([]entity.MovieRepo, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "")
 	defer span.Finish()
 
-	q := fmt.Sprintf("select id, name, duration, genre from movies")
+	selectColumns, err := buildSelectColumns(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	q := fmt.Sprintf("select %s from movies where tenant_id = {tenant}", selectColumns)
 
 	var movies []entity.MovieRepo
 
-	err := m.mysql.FetchRows(ctx, q, &movies)
+	err = m.mysql.FetchRows(ctx, q, &movies)
 	if err != nil {
 		return movies, err
 	}
@@ -51,15 +96,23 @@ func (m *MovieRepository) GetAllMovies(ctx context.Context) ([]entity.MovieRepo,
 	return movies, nil
 }
 
-func (m *MovieRepository) GetMovie(ctx context.Context, movieId int64) (entity.MovieRepo, error) {
+func (m *MovieRepository) GetMovie(ctx context.Context, movieId int64, columns []string) (entity.MovieRepo, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "")
 	defer span.Finish()
 
-	q := fmt.Sprintf("select id, name, duration, genre from movies where id = ?")
-
 	var movie entity.MovieRepo
 
-	err := m.mysql.FetchRow(ctx, q, &movie, movieId)
+	selectColumns, err := buildSelectColumns(columns)
+	if err != nil {
+		return movie, err
+	}
+
+	q := fmt.Sprintf("select %s from movies where tenant_id = {tenant} and id = ?", selectColumns)
+
+	err = m.mysql.FetchRow(ctx, q, &movie, movieId)
+	if err == sql.ErrNoRows {
+		return movie, ErrMovieNotFound
+	}
 	if err != nil {
 		return movie, err
 	}
@@ -68,11 +121,43 @@ func (m *MovieRepository) GetMovie(ctx context.Context, movieId int64) (entity.M
 
 }
 
+// GetMoviesByIDs fetches the movies with the given IDs, the result is not ordered
+func (m *MovieRepository) GetMoviesByIDs(ctx context.Context, movieIds []int64, columns []string) ([]entity.MovieRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	var movies []entity.MovieRepo
+	if len(movieIds) == 0 {
+		return movies, nil
+	}
+
+	selectColumns, err := buildSelectColumns(columns)
+	if err != nil {
+		return movies, err
+	}
+
+	q, args, err := sqlx.In(fmt.Sprintf("select %s from movies where tenant_id = {tenant} and id in (?)", selectColumns), movieIds)
+	if err != nil {
+		return movies, err
+	}
+
+	err = m.mysql.FetchRows(ctx, q, &movies, args...)
+	if err != nil {
+		return movies, err
+	}
+
+	return movies, nil
+}
+
 func (m *MovieRepository) SaveMovie(ctx context.Context, movieRepo entity.MovieRepo) (entity.MovieRepo, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "")
 	defer span.Finish()
 
-	q := fmt.Sprintf("insert into movies (name, genre, duration) values (:name, :genre, :duration)")
+	now := time.Now().UTC().Truncate(time.Second)
+	movieRepo.CreatedAt = now
+	movieRepo.UpdatedAt = now
+
+	q := fmt.Sprintf("insert into movies (tenant_id, name, genre, duration, created_at, updated_at) values ({tenant}, :name, :genre, :duration, :created_at, :updated_at)")
 
 	res, err := m.mysql.Exec(ctx, q, movieRepo)
 	if err != nil {
@@ -86,3 +171,335 @@ func (m *MovieRepository) SaveMovie(ctx context.Context, movieRepo entity.MovieR
 
 	return movieRepo, nil
 }
+
+// GetDuplicateCandidates fetches the other movies whose duration is within the window of the given movie
+func (m *MovieRepository) GetDuplicateCandidates(ctx context.Context, movieRepo entity.MovieRepo, durationWindow int) ([]entity.MovieRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	q := fmt.Sprintf("select id, name, duration, genre, created_at, updated_at from movies where tenant_id = {tenant} and id <> ? and duration between ? and ?")
+
+	var movies []entity.MovieRepo
+
+	err := m.mysql.FetchRows(ctx, q, &movies, movieRepo.ID, movieRepo.Duration-durationWindow, movieRepo.Duration+durationWindow)
+	if err != nil {
+		return movies, err
+	}
+
+	return movies, nil
+}
+
+// GetMovieRedirect returns the movie that survived the merge of the given movie
+func (m *MovieRepository) GetMovieRedirect(ctx context.Context, movieId int64) (int64, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	q := fmt.Sprintf("select to_id from movie_redirects where tenant_id = {tenant} and from_id = ?")
+
+	var toId int64
+
+	err := m.mysql.FetchRow(ctx, q, &toId, movieId)
+	if err == sql.ErrNoRows {
+		return 0, ErrMovieNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return toId, nil
+}
+
+// MergeMovies moves the child records of the source movie to the target, leaves a redirect
+// from the source ID and deletes the source, all in one transaction on the master DB
+func (m *MovieRepository) MergeMovies(ctx context.Context, targetId int64, sourceId int64) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	return m.mysql.Transaction(ctx, func(tx *mysql.Tx) error {
+		var found []int64
+		err := tx.FetchRows(ctx, "select id from movies where tenant_id = {tenant} and id in (?, ?)"+tx.Dialect().ForUpdate(), &found, targetId, sourceId)
+		if err != nil {
+			return err
+		}
+		if len(found) != 2 {
+			return ErrMovieNotFound
+		}
+
+		tenantMovies := "movie_id in (select id from movies where tenant_id = {tenant})"
+		for _, table := range movieChildTables {
+			// Rows colliding with a unique key of the target stay behind and are removed
+			_, err = tx.Exec(ctx, fmt.Sprintf("%s set movie_id = ? where movie_id = ? and %s", tx.Dialect().UpdateIgnore(table), tenantMovies), targetId, sourceId)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(ctx, fmt.Sprintf("delete from %s where movie_id = ? and %s", table, tenantMovies), sourceId)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(ctx, "update movie_redirects set to_id = ? where tenant_id = {tenant} and to_id = ?", targetId, sourceId)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "insert into movie_redirects (tenant_id, from_id, to_id) values ({tenant}, ?, ?)", sourceId, targetId)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "delete from movies where tenant_id = {tenant} and id = ?", sourceId)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "update movies set updated_at = ? where tenant_id = {tenant} and id = ?", time.Now().UTC().Truncate(time.Second), targetId)
+		return err
+	})
+}
+
+// GetGenreDurationStats aggregates the duration of the movies per genre column value on the slave DB
+func (m *MovieRepository) GetGenreDurationStats(ctx context.Context, filter entity.MovieStatsFilter) ([]entity.DurationStatsRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	where, args := buildStatsFilter(m.mysql.Dialect(), filter)
+	q := fmt.Sprintf("select genre as group_key, count(*) as count, min(duration) as min, max(duration) as max, avg(duration) as avg "+
+		"from movies%s group by genre", where)
+
+	var stats []entity.DurationStatsRepo
+
+	err := m.mysql.FetchRows(ctx, q, &stats, args...)
+	if err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// GetDurationHistogram counts the movies per duration bucket on the slave DB
+func (m *MovieRepository) GetDurationHistogram(ctx context.Context, filter entity.MovieStatsFilter, bucketSize int) ([]entity.HistogramBucketRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	where, args := buildStatsFilter(m.mysql.Dialect(), filter)
+	q := fmt.Sprintf("select %s * ? as bucket, count(*) as count from movies%s group by bucket order by bucket", m.mysql.Dialect().IntDiv("duration", "?"), where)
+
+	var buckets []entity.HistogramBucketRepo
+
+	err := m.mysql.FetchRows(ctx, q, &buckets, append([]interface{}{bucketSize, bucketSize}, args...)...)
+	if err != nil {
+		return buckets, err
+	}
+
+	return buckets, nil
+}
+
+// GetMovieByExternalID fetches the movie identified by the provider ID
+func (m *MovieRepository) GetMovieByExternalID(ctx context.Context, provider string, externalId string) (entity.MovieRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	q := fmt.Sprintf("select m.id, m.name, m.duration, m.genre, m.created_at, m.updated_at " +
+		"from movies m join movie_external_ids e on e.movie_id = m.id where m.tenant_id = {tenant} and e.provider = ? and e.external_id = ?")
+
+	var movie entity.MovieRepo
+
+	err := m.mysql.FetchRow(ctx, q, &movie, provider, externalId)
+	if err == sql.ErrNoRows {
+		return movie, ErrMovieNotFound
+	}
+	if err != nil {
+		return movie, err
+	}
+
+	return movie, nil
+}
+
+// GetExternalIDs fetches the external IDs of the movies
+func (m *MovieRepository) GetExternalIDs(ctx context.Context, movieIds []int64) ([]entity.ExternalIDRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	var externalIds []entity.ExternalIDRepo
+	if len(movieIds) == 0 {
+		return externalIds, nil
+	}
+
+	q, args, err := sqlx.In("select movie_id, provider, external_id from movie_external_ids where tenant_id = {tenant} and movie_id in (?)", movieIds)
+	if err != nil {
+		return externalIds, err
+	}
+
+	err = m.mysql.FetchRows(ctx, q, &externalIds, args...)
+	if err != nil {
+		return externalIds, err
+	}
+
+	return externalIds, nil
+}
+
+// GetCredits fetches the credits of the movies, ordered by movie and billing
+func (m *MovieRepository) GetCredits(ctx context.Context, movieIds []int64) ([]entity.CreditRepo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	var credits []entity.CreditRepo
+	if len(movieIds) == 0 {
+		return credits, nil
+	}
+
+	q, args, err := sqlx.In("select movie_id, position, role, name from movie_credits where tenant_id = {tenant} and movie_id in (?) "+
+		"order by movie_id, position, role, name", movieIds)
+	if err != nil {
+		return credits, err
+	}
+
+	err = m.mysql.FetchRows(ctx, q, &credits, args...)
+	if err != nil {
+		return credits, err
+	}
+
+	return credits, nil
+}
+
+// UpsertMovie updates the movie already known by one of the external IDs or inserts a new one,
+// then stores the external IDs. The credits of the movie are replaced unless nil.
+// The boolean is true when the movie has been created.
+func (m *MovieRepository) UpsertMovie(ctx context.Context, movieRepo entity.MovieRepo, externalIds []entity.ExternalIDRepo, credits []entity.CreditRepo) (entity.MovieRepo, bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	var created bool
+	err := m.mysql.Transaction(ctx, func(tx *mysql.Tx) error {
+		var existing []int64
+		if len(externalIds) > 0 {
+			var (
+				conditions []string
+				args       []interface{}
+			)
+			for _, externalId := range externalIds {
+				conditions = append(conditions, "(provider = ? and external_id = ?)")
+				args = append(args, externalId.Provider, externalId.ExternalID)
+			}
+
+			q := fmt.Sprintf("select distinct movie_id from movie_external_ids where tenant_id = {tenant} and (%s)%s", strings.Join(conditions, " or "), tx.Dialect().ForUpdate())
+			err := tx.FetchRows(ctx, q, &existing, args...)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(existing) > 1 {
+			return ErrExternalIDConflict
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		created = len(existing) == 0
+		if created {
+			movieRepo.CreatedAt = now
+			movieRepo.UpdatedAt = now
+
+			res, err := tx.NamedExec(ctx, "insert into movies (tenant_id, name, genre, duration, created_at, updated_at) "+
+				"values ({tenant}, :name, :genre, :duration, :created_at, :updated_at)", movieRepo)
+			if err != nil {
+				return err
+			}
+
+			movieRepo.ID, err = res.LastInsertId()
+			if err != nil {
+				return err
+			}
+		} else {
+			movieRepo.ID = existing[0]
+			movieRepo.UpdatedAt = now
+
+			_, err := tx.NamedExec(ctx, "update movies set name = :name, genre = :genre, duration = :duration, "+
+				"updated_at = :updated_at where tenant_id = {tenant} and id = :id", movieRepo)
+			if err != nil {
+				return err
+			}
+
+			err = tx.FetchRow(ctx, "select created_at from movies where tenant_id = {tenant} and id = ?", &movieRepo.CreatedAt, movieRepo.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, externalId := range externalIds {
+			// A movie has one ID per provider, a re-import replaces it
+			_, err := tx.Exec(ctx, "insert into movie_external_ids (tenant_id, movie_id, provider, external_id) values ({tenant}, ?, ?, ?)"+
+				tx.Dialect().Upsert([]string{"movie_id", "provider"}, []string{"external_id"}), movieRepo.ID, externalId.Provider, externalId.ExternalID)
+			if err != nil {
+				return err
+			}
+		}
+
+		if credits == nil {
+			return nil
+		}
+
+		_, err := tx.Exec(ctx, "delete from movie_credits where tenant_id = {tenant} and movie_id = ?", movieRepo.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, credit := range credits {
+			_, err = tx.Exec(ctx, "insert into movie_credits (tenant_id, movie_id, position, role, name) values ({tenant}, ?, ?, ?, ?)",
+				movieRepo.ID, credit.Position, credit.Role, credit.Name)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	// A concurrent import stored one of the external IDs on another movie
+	if mysql.IsDuplicate(err) {
+		return movieRepo, false, ErrExternalIDConflict
+	}
+	if err != nil {
+		return movieRepo, false, err
+	}
+
+	return movieRepo, created, nil
+}
+
+// buildStatsFilter builds the where clause of the stats queries
+func buildStatsFilter(dialect mysql.Dialect, filter entity.MovieStatsFilter) (string, []interface{}) {
+	conditions := []string{"tenant_id = " + mysql.TenantPlaceholder}
+	var args []interface{}
+
+	if filter.Genre != "" {
+		conditions = append(conditions, dialect.FindInSet("?", "replace(genre, ', ', ',')"))
+		args = append(args, filter.Genre)
+	}
+
+	if filter.MinDuration > 0 {
+		conditions = append(conditions, "duration >= ?")
+		args = append(args, filter.MinDuration)
+	}
+
+	if filter.MaxDuration > 0 {
+		conditions = append(conditions, "duration <= ?")
+		args = append(args, filter.MaxDuration)
+	}
+
+	return " where " + strings.Join(conditions, " and "), args
+}
+
+// buildSelectColumns validates the columns against the movies table, all columns are selected when empty
+func buildSelectColumns(columns []string) (string, error) {
+	if len(columns) == 0 {
+		return "id, name, duration, genre, created_at, updated_at", nil
+	}
+
+	for _, c := range columns {
+		if !movieColumns[c] {
+			return "", fmt.Errorf("unknown movie column %q", c)
+		}
+	}
+
+	return strings.Join(columns, ", "), nil
+}
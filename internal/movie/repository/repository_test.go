@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-rest-api/internal/movie/entity"
+	"github.com/go-rest-api/pkg/mysql"
+	"github.com/go-rest-api/pkg/tenant"
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+func newMockedRepository(t *testing.T) (*MovieRepository, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlxDB := sqlx.NewDb(db, "mysql")
+	replicas, err := mysql.NewReplicaSet(mysql.ReplicaOptions{}, &mysql.Replica{Name: "slave", DB: sqlxDB})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewMovieRepository(sqlxDB, replicas, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m, mock
+}
+
+func TestMovieRepositoryReadsOnlyTenantRows(t *testing.T) {
+	m, mock := newMockedRepository(t)
+	defer m.mysql.MasterDB.Close()
+
+	columns := []string{"id", "name", "duration", "genre"}
+	mock.ExpectQuery(regexp.QuoteMeta("select id, name, duration, genre from movies where tenant_id = ?")).
+		WithArgs("brand-a").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "The Matrix", 136, "Sci-Fi"))
+	// Movie 2 belongs to brand-b, the scoped query of brand-a does not find it
+	mock.ExpectQuery(regexp.QuoteMeta("select id, name from movies where tenant_id = ? and id = ?")).
+		WithArgs("brand-a", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	mock.ExpectQuery(regexp.QuoteMeta("select id, name from movies where tenant_id = ? and id = ?")).
+		WithArgs("brand-b", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "Alien"))
+
+	brandA := tenant.WithTenant(context.Background(), "brand-a")
+	brandB := tenant.WithTenant(context.Background(), "brand-b")
+
+	movies, err := m.GetAllMovies(brandA, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) != 1 || movies[0].ID != 1 {
+		t.Errorf("GetAllMovies = %v, want movie 1 only", movies)
+	}
+
+	_, err = m.GetMovie(brandA, 2, []string{"id", "name"})
+	if err != ErrMovieNotFound {
+		t.Errorf("GetMovie of another tenant err = %v, want %v", err, ErrMovieNotFound)
+	}
+
+	movie, err := m.GetMovie(brandB, 2, []string{"id", "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.ID != 2 {
+		t.Errorf("GetMovie = %v, want movie 2", movie)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMovieRepositoryWritesTenantRows(t *testing.T) {
+	m, mock := newMockedRepository(t)
+	defer m.mysql.MasterDB.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta("insert into movies (tenant_id, name, genre, duration, created_at, updated_at) values (?, ?, ?, ?, ?, ?)")).
+		WithArgs("brand-a", "Alien", "Horror", 117, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	ctx := tenant.WithTenant(context.Background(), "brand-a")
+	movie, err := m.SaveMovie(ctx, entity.MovieRepo{Name: "Alien", Genre: "Horror", Duration: 117})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.ID != 3 {
+		t.Errorf("SaveMovie ID = %d, want 3", movie.ID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMovieRepositoryMergeStaysInTenant(t *testing.T) {
+	m, mock := newMockedRepository(t)
+	defer m.mysql.MasterDB.Close()
+
+	// Movie 2 belongs to another tenant, the merge is aborted before any write
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("select id from movies where tenant_id = ? and id in (?, ?) for update")).
+		WithArgs("brand-a", 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectRollback()
+
+	ctx := tenant.WithTenant(context.Background(), "brand-a")
+	err := m.MergeMovies(ctx, 1, 2)
+	if err != ErrMovieNotFound {
+		t.Errorf("MergeMovies err = %v, want %v", err, ErrMovieNotFound)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMovieRepositoryMergeMovesTheChildRows(t *testing.T) {
+	m, mock := newMockedRepository(t)
+	defer m.mysql.MasterDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("select id from movies where tenant_id = ? and id in (?, ?) for update")).
+		WithArgs("brand-a", 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	for _, table := range []string{"collection_items", "movie_credits", "movie_external_ids"} {
+		mock.ExpectExec(regexp.QuoteMeta("update ignore "+table+" set movie_id = ? where movie_id = ? and movie_id in (select id from movies where tenant_id = ?)")).
+			WithArgs(1, 2, "brand-a").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("delete from "+table+" where movie_id = ? and movie_id in (select id from movies where tenant_id = ?)")).
+			WithArgs(2, "brand-a").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(regexp.QuoteMeta("update movie_redirects set to_id = ? where tenant_id = ? and to_id = ?")).
+		WithArgs(1, "brand-a", 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("insert into movie_redirects (tenant_id, from_id, to_id) values (?, ?, ?)")).
+		WithArgs("brand-a", 2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("delete from movies where tenant_id = ? and id = ?")).
+		WithArgs("brand-a", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("update movies set updated_at = ? where tenant_id = ? and id = ?")).
+		WithArgs(sqlmock.AnyArg(), "brand-a", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx := tenant.WithTenant(context.Background(), "brand-a")
+	if err := m.MergeMovies(ctx, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMovieRepositoryGetMovieRedirect(t *testing.T) {
+	m, mock := newMockedRepository(t)
+	defer m.mysql.MasterDB.Close()
+
+	q := regexp.QuoteMeta("select to_id from movie_redirects where tenant_id = ? and from_id = ?")
+	mock.ExpectQuery(q).
+		WithArgs("brand-a", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"to_id"}).AddRow(1))
+	mock.ExpectQuery(q).
+		WithArgs("brand-a", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"to_id"}))
+
+	ctx := tenant.WithTenant(context.Background(), "brand-a")
+
+	toId, err := m.GetMovieRedirect(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if toId != 1 {
+		t.Errorf("GetMovieRedirect = %d, want 1", toId)
+	}
+
+	if _, err := m.GetMovieRedirect(ctx, 3); err != ErrMovieNotFound {
+		t.Errorf("GetMovieRedirect of a movie never merged err = %v, want %v", err, ErrMovieNotFound)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMovieRepositoryRequiresTenant(t *testing.T) {
+	m, mock := newMockedRepository(t)
+	defer m.mysql.MasterDB.Close()
+
+	ctx := context.Background()
+
+	if _, err := m.GetAllMovies(ctx, nil); err != mysql.ErrTenantRequired {
+		t.Errorf("GetAllMovies err = %v, want %v", err, mysql.ErrTenantRequired)
+	}
+
+	if _, err := m.GetMovieByExternalID(ctx, "imdb", "tt0133093"); err != mysql.ErrTenantRequired {
+		t.Errorf("GetMovieByExternalID err = %v, want %v", err, mysql.ErrTenantRequired)
+	}
+
+	if _, err := m.SaveMovie(ctx, entity.MovieRepo{Name: "Alien"}); err != mysql.ErrTenantRequired {
+		t.Errorf("SaveMovie err = %v, want %v", err, mysql.ErrTenantRequired)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMovieRepositoryUpsertRaceIsAConflict(t *testing.T) {
+	m, mock := newMockedRepository(t)
+	defer m.mysql.MasterDB.Close()
+
+	// Another import stores the external ID between the lookup and the insert
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("select distinct movie_id from movie_external_ids where tenant_id = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"movie_id"}))
+	mock.ExpectExec(regexp.QuoteMeta("insert into movies")).WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec(regexp.QuoteMeta("insert into movie_external_ids")).
+		WillReturnError(&driver.MySQLError{Number: 1062, Message: "Duplicate entry 'brand-a-tmdb-949'"})
+	mock.ExpectRollback()
+
+	ctx := tenant.WithTenant(context.Background(), "brand-a")
+	_, _, err := m.UpsertMovie(ctx, entity.MovieRepo{Name: "Heat", Duration: 170, Genre: "crime"},
+		[]entity.ExternalIDRepo{{Provider: "tmdb", ExternalID: "949"}}, nil)
+	if err != ErrExternalIDConflict {
+		t.Errorf("err = %v, want %v", err, ErrExternalIDConflict)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
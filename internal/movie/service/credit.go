@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-rest-api/internal/movie/entity"
+	"strings"
+)
+
+// maxCreditName is the size of the name column of the credits
+const maxCreditName = 255
+
+// creditRoles are the supported roles of the credited people
+var creditRoles = []string{"director", "writer", "producer", "composer", "cast"}
+
+// ErrInvalidCredit returned when an imported credit has no name or an unknown role
+var ErrInvalidCredit = errors.New("invalid credit")
+
+// toCreditRepos validates the imported credits and numbers them in billing order. The credits of the
+// movie are kept when the item has none, a nil result.
+func toCreditRepos(items []entity.MovieCredit) ([]entity.CreditRepo, error) {
+	if items == nil {
+		return nil, nil
+	}
+
+	credits := make([]entity.CreditRepo, 0, len(items))
+	seen := make(map[entity.MovieCredit]bool, len(items))
+	for i, item := range items {
+		item.Name = strings.TrimSpace(item.Name)
+		if item.Name == "" || len(item.Name) > maxCreditName {
+			return nil, fmt.Errorf("%w: credits[%d].name must have 1 to %d characters", ErrInvalidCredit, i, maxCreditName)
+		}
+		if !isCreditRole(item.Role) {
+			return nil, fmt.Errorf("%w: credits[%d].role %q is not one of %s", ErrInvalidCredit, i, item.Role, strings.Join(creditRoles, ", "))
+		}
+		if seen[item] {
+			return nil, fmt.Errorf("%w: credits[%d] %s %q is listed twice", ErrInvalidCredit, i, item.Role, item.Name)
+		}
+		seen[item] = true
+
+		credits = append(credits, entity.CreditRepo{Position: i, Role: item.Role, Name: item.Name})
+	}
+
+	return credits, nil
+}
+
+func isCreditRole(role string) bool {
+	for _, r := range creditRoles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// embedCredits embeds the credits of the movies in billing order
+func (m *MovieService) embedCredits(ctx context.Context, movieRepos []entity.MovieRepo) (map[int64]interface{}, error) {
+	movieIds := make([]int64, 0, len(movieRepos))
+	for _, movieRepo := range movieRepos {
+		movieIds = append(movieIds, movieRepo.ID)
+	}
+
+	credits, err := m.repo.GetCredits(ctx, movieIds)
+	if err != nil {
+		return nil, err
+	}
+
+	byMovie := make(map[int64][]entity.MovieCredit, len(movieRepos))
+	for _, movieRepo := range movieRepos {
+		byMovie[movieRepo.ID] = make([]entity.MovieCredit, 0)
+	}
+	for _, credit := range credits {
+		byMovie[credit.MovieID] = append(byMovie[credit.MovieID], entity.MovieCredit{Name: credit.Name, Role: credit.Role})
+	}
+
+	related := make(map[int64]interface{}, len(byMovie))
+	for movieId, movieCredits := range byMovie {
+		related[movieId] = movieCredits
+	}
+
+	return related, nil
+}
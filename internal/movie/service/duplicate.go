@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-rest-api/internal/movie/entity"
+	"github.com/opentracing/opentracing-go"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Duplicate scoring constants
+const (
+	// DefaultDuplicateScore is the minimum score of a reported duplicate
+	DefaultDuplicateScore = 0.75
+
+	// durationWindow is the maximum duration difference in minutes of two duplicates
+	durationWindow = 15
+
+	titleWeight    = 0.6
+	durationWeight = 0.2
+	genreWeight    = 0.2
+)
+
+// ErrMergeSelf returned when a movie is merged into itself
+var ErrMergeSelf = errors.New("a movie cannot be merged into itself")
+
+// titleArticles are moved or dropped when normalizing the titles
+var titleArticles = []string{"the", "a", "an"}
+
+// MovedError returned when the movie has been merged into another one
+type MovedError struct {
+	MovieID int64
+}
+
+func (e *MovedError) Error() string {
+	return fmt.Sprintf("movie has been merged into %d", e.MovieID)
+}
+
+// FindDuplicates returns the movies scoring at least minScore against the given movie, best first
+func (m *MovieService) FindDuplicates(ctx context.Context, movieId int64, minScore float64) ([]entity.MovieDuplicate, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	duplicates := make([]entity.MovieDuplicate, 0)
+
+	movieRepo, err := m.repo.GetMovie(ctx, movieId, nil)
+	if err != nil {
+		return duplicates, err
+	}
+
+	candidates, err := m.repo.GetDuplicateCandidates(ctx, movieRepo, durationWindow)
+	if err != nil {
+		return duplicates, err
+	}
+
+	for _, candidate := range candidates {
+		duplicate := scoreDuplicate(movieRepo, candidate)
+		if duplicate.Score >= minScore {
+			duplicates = append(duplicates, duplicate)
+		}
+	}
+
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return duplicates[i].Score > duplicates[j].Score
+	})
+
+	return duplicates, nil
+}
+
+// MergeMovies merges the source movie into the target and returns the surviving movie
+func (m *MovieService) MergeMovies(ctx context.Context, targetId int64, sourceId int64) (entity.MovieResp, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	var movieResp entity.MovieResp
+	if targetId == sourceId {
+		return movieResp, ErrMergeSelf
+	}
+
+	source, err := m.repo.GetMovie(ctx, sourceId, nil)
+	if err != nil {
+		return movieResp, err
+	}
+
+	err = m.repo.MergeMovies(ctx, targetId, sourceId)
+	if err != nil {
+		return movieResp, err
+	}
+
+	target, err := m.repo.GetMovie(ctx, targetId, nil)
+	if err != nil {
+		return movieResp, err
+	}
+
+	m.publish(ctx, EventMovieDeleted, source)
+	m.publish(ctx, EventMovieUpdated, target)
+
+	return toMovieResp(target), nil
+}
+
+// scoreDuplicate scores the candidate on normalized title, duration and genre
+func scoreDuplicate(movieRepo entity.MovieRepo, candidate entity.MovieRepo) entity.MovieDuplicate {
+	duplicate := entity.MovieDuplicate{
+		Movie:         toMovieResp(candidate),
+		TitleScore:    similarity(normalizeTitle(movieRepo.Name), normalizeTitle(candidate.Name)),
+		DurationScore: math.Max(0, 1-math.Abs(float64(movieRepo.Duration-candidate.Duration))/durationWindow),
+		GenreScore:    jaccard(genreSet(movieRepo.Genre), genreSet(candidate.Genre)),
+	}
+
+	score := titleWeight*duplicate.TitleScore + durationWeight*duplicate.DurationScore + genreWeight*duplicate.GenreScore
+	duplicate.Score = math.Round(score*1000) / 1000
+
+	return duplicate
+}
+
+// normalizeTitle lower cases the title, strips the punctuation and the leading article,
+// so "Matrix, The" and "The Matrix" both become "matrix"
+func normalizeTitle(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	if len(words) > 1 {
+		for _, article := range titleArticles {
+			if words[0] == article {
+				words = words[1:]
+				break
+			}
+			if words[len(words)-1] == article && strings.Contains(title, ",") {
+				words = words[:len(words)-1]
+				break
+			}
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+// similarity returns 1 minus the normalized Levenshtein distance
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	maxLen := len(ra)
+	if len(rb) > maxLen {
+		maxLen = len(rb)
+	}
+
+	return 1 - float64(prev[len(rb)])/float64(maxLen)
+}
+
+// genreSet splits the comma separated genres
+func genreSet(genre string) map[string]bool {
+	set := make(map[string]bool)
+	for _, g := range strings.Split(genre, ",") {
+		if g = strings.ToLower(strings.TrimSpace(g)); g != "" {
+			set[g] = true
+		}
+	}
+
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	var intersection int
+	for k := range a {
+		if b[k] {
+			intersection++
+		}
+	}
+
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+
+	return min
+}
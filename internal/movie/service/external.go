@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/asaskevich/govalidator"
+	"github.com/go-rest-api/internal/movie/entity"
+	"github.com/go-rest-api/internal/movie/repository"
+	"github.com/opentracing/opentracing-go"
+	logger "github.com/sirupsen/logrus"
+	"regexp"
+	"sort"
+)
+
+// maxImportMovies is the maximum number of movies of an import batch
+const maxImportMovies = 500
+
+// externalProviders are the supported third-party catalogues with the format of their IDs
+var externalProviders = map[string]*regexp.Regexp{
+	"imdb": regexp.MustCompile(`^tt[0-9]{7,}$`),
+	"tmdb": regexp.MustCompile(`^[0-9]+$`),
+}
+
+// Import errors
+var (
+	ErrInvalidExternalID  = errors.New("invalid external id")
+	ErrExternalIDConflict = repository.ErrExternalIDConflict
+	ErrImportTooLarge     = fmt.Errorf("an import is limited to %d movies", maxImportMovies)
+)
+
+// LookupMovie finds the movie by its identifier in a third-party catalogue
+func (m *MovieService) LookupMovie(ctx context.Context, provider string, externalId string) (entity.MovieResp, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	var movieResp entity.MovieResp
+
+	err := validateExternalID(provider, externalId)
+	if err != nil {
+		return movieResp, err
+	}
+
+	movieRepo, err := m.repo.GetMovieByExternalID(ctx, provider, externalId)
+	if err != nil {
+		return movieResp, err
+	}
+
+	return toMovieResp(movieRepo), nil
+}
+
+// errImportFailed is the message of the items failing for an unexpected reason, the error is logged
+const errImportFailed = "the movie could not be imported"
+
+// ImportMovies upserts the movies by their external IDs so re-imports update instead of duplicating.
+// Each movie is imported on its own, the failures of an item are reported with it. The import stops
+// at the first failure of the database or of the request, e.g. a timeout, which is returned.
+func (m *MovieService) ImportMovies(ctx context.Context, items []entity.MovieImportItem) ([]entity.MovieImportResult, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	results := make([]entity.MovieImportResult, 0, len(items))
+	if len(items) > maxImportMovies {
+		return results, ErrImportTooLarge
+	}
+
+	for i, item := range items {
+		result := entity.MovieImportResult{Index: i, Status: entity.ImportFailed}
+
+		movieRepo, created, err := m.importMovie(ctx, item)
+		if err != nil {
+			result.Error, err = importFailure(ctx, err)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+			continue
+		}
+
+		result.ID = movieRepo.ID
+		if created {
+			result.Status = entity.ImportCreated
+			m.publish(ctx, EventMovieCreated, movieRepo)
+		} else {
+			result.Status = entity.ImportUpdated
+			m.publish(ctx, EventMovieUpdated, movieRepo)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// importFailure returns the message of an item which could not be imported. The messages of the
+// invalid items are built by the service, the other errors never reach the client: the failures of
+// the database and the end of the request are returned to abort the import.
+func importFailure(ctx context.Context, err error) (string, error) {
+	var (
+		timeout     interface{ Timeout() bool }
+		unavailable interface{ Unavailable() bool }
+		validation  govalidator.Errors
+	)
+	switch {
+	case ctx.Err() != nil:
+		return "", ctx.Err()
+	case errors.As(err, &timeout) && timeout.Timeout(),
+		errors.As(err, &unavailable) && unavailable.Unavailable():
+		return "", err
+	case errors.Is(err, ErrInvalidExternalID), errors.Is(err, ErrInvalidCredit), errors.As(err, &validation):
+		return err.Error(), nil
+	case errors.Is(err, ErrExternalIDConflict):
+		return ErrExternalIDConflict.Error(), nil
+	case errors.Is(err, ErrMovieNotFound):
+		return ErrMovieNotFound.Error(), nil
+	}
+
+	logger.Warnf("movie import failed: %v", err)
+	return errImportFailed, nil
+}
+
+func (m *MovieService) importMovie(ctx context.Context, item entity.MovieImportItem) (entity.MovieRepo, bool, error) {
+	var movieRepo entity.MovieRepo
+
+	_, err := govalidator.ValidateStruct(item)
+	if err != nil {
+		return movieRepo, false, err
+	}
+
+	var externalIds []entity.ExternalIDRepo
+	for provider, externalId := range item.ExternalIDs {
+		err = validateExternalID(provider, externalId)
+		if err != nil {
+			return movieRepo, false, err
+		}
+		externalIds = append(externalIds, entity.ExternalIDRepo{Provider: provider, ExternalID: externalId})
+	}
+	sort.Slice(externalIds, func(i, j int) bool {
+		return externalIds[i].Provider < externalIds[j].Provider
+	})
+
+	credits, err := toCreditRepos(item.Credits)
+	if err != nil {
+		return movieRepo, false, err
+	}
+
+	movieRepo = entity.MovieRepo{
+		Name:     item.Name,
+		Duration: item.Duration,
+		Genre:    item.Genre,
+	}
+
+	return m.repo.UpsertMovie(ctx, movieRepo, externalIds, credits)
+}
+
+// embedExternalIDs embeds the identifiers per provider of the movies
+func (m *MovieService) embedExternalIDs(ctx context.Context, movieRepos []entity.MovieRepo) (map[int64]interface{}, error) {
+	movieIds := make([]int64, 0, len(movieRepos))
+	for _, movieRepo := range movieRepos {
+		movieIds = append(movieIds, movieRepo.ID)
+	}
+
+	externalIds, err := m.repo.GetExternalIDs(ctx, movieIds)
+	if err != nil {
+		return nil, err
+	}
+
+	byMovie := make(map[int64]map[string]string, len(movieRepos))
+	for _, movieRepo := range movieRepos {
+		byMovie[movieRepo.ID] = make(map[string]string)
+	}
+	for _, externalId := range externalIds {
+		byMovie[externalId.MovieID][externalId.Provider] = externalId.ExternalID
+	}
+
+	related := make(map[int64]interface{}, len(byMovie))
+	for movieId, ids := range byMovie {
+		related[movieId] = ids
+	}
+
+	return related, nil
+}
+
+func validateExternalID(provider string, externalId string) error {
+	format, ok := externalProviders[provider]
+	if !ok {
+		return fmt.Errorf("%w: unknown provider %q", ErrInvalidExternalID, provider)
+	}
+
+	if !format.MatchString(externalId) {
+		return fmt.Errorf("%w: %q is not a valid %s id", ErrInvalidExternalID, externalId, provider)
+	}
+
+	return nil
+}
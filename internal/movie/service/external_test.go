@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-rest-api/internal/movie/entity"
+	"github.com/go-rest-api/internal/movie/repository"
+	"github.com/go-rest-api/pkg/breaker"
+	"github.com/go-rest-api/pkg/mysql"
+)
+
+// upsertRepository fails the upserts of the movies named in errs
+type upsertRepository struct {
+	repository.MovieRepositoryFactory
+	errs    map[string]error
+	upserts int
+}
+
+func (r *upsertRepository) UpsertMovie(ctx context.Context, movieRepo entity.MovieRepo, externalIds []entity.ExternalIDRepo, credits []entity.CreditRepo) (entity.MovieRepo, bool, error) {
+	r.upserts++
+	if err := r.errs[movieRepo.Name]; err != nil {
+		return movieRepo, false, err
+	}
+
+	movieRepo.ID = int64(r.upserts)
+	return movieRepo, true, nil
+}
+
+func importItem(name string) entity.MovieImportItem {
+	return entity.MovieImportItem{Name: name, Duration: 120, Genre: "drama", ExternalIDs: map[string]string{"tmdb": "949"}}
+}
+
+func TestImportMoviesReportsTheItemFailures(t *testing.T) {
+	repo := &upsertRepository{errs: map[string]error{
+		"Conflict":   ErrExternalIDConflict,
+		"Unexpected": errors.New("Error 1406: Data too long for column 'name' at row 1"),
+	}}
+	m, err := NewMovieService(repo, nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalidID := importItem("Invalid ID")
+	invalidID.ExternalIDs = map[string]string{"tmdb": "tt0113277"}
+	invalidCredit := importItem("Invalid credit")
+	invalidCredit.Credits = []entity.MovieCredit{{Name: "Michael Mann", Role: "catering"}}
+
+	items := []entity.MovieImportItem{importItem("Heat"), invalidID, invalidCredit, {Name: "Incomplete"}, importItem("Conflict"), importItem("Unexpected")}
+	results, err := m.ImportMovies(context.Background(), items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		status string
+		err    string
+	}{
+		{entity.ImportCreated, ""},
+		{entity.ImportFailed, `invalid external id: "tt0113277" is not a valid tmdb id`},
+		{entity.ImportFailed, "invalid credit: credits[0].role"},
+		{entity.ImportFailed, "non zero value required"},
+		{entity.ImportFailed, "external ids belong to different movies"},
+		{entity.ImportFailed, errImportFailed},
+	}
+	if len(results) != len(tests) {
+		t.Fatalf("results = %+v, want one per item", results)
+	}
+	for i, tt := range tests {
+		if results[i].Index != i || results[i].Status != tt.status || !strings.Contains(results[i].Error, tt.err) || (tt.err == "") != (results[i].Error == "") {
+			t.Errorf("results[%d] = %+v, want %s %q", i, results[i], tt.status, tt.err)
+		}
+	}
+	if strings.Contains(results[5].Error, "1406") {
+		t.Errorf("the unexpected error reaches the client: %q", results[5].Error)
+	}
+}
+
+func TestImportMoviesStopsAtTheServerFailures(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+	}{
+		{"timeout", context.Background(), breaker.ErrTimeout},
+		{"open breaker", context.Background(), fmt.Errorf("upsert: %w", breaker.ErrOpen)},
+		{"no replica", context.Background(), mysql.ErrNoReplica},
+		{"request canceled", canceled, ErrExternalIDConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &upsertRepository{errs: map[string]error{"Heat": tt.err}}
+			m, err := NewMovieService(repo, nil, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := m.ImportMovies(tt.ctx, []entity.MovieImportItem{importItem("Heat"), importItem("Ronin"), importItem("Thief")})
+			if err == nil {
+				t.Fatalf("results = %+v, want the import aborted", results)
+			}
+			if repo.upserts != 1 {
+				t.Errorf("upserts = %d, want the import stopped at the first failure", repo.upserts)
+			}
+		})
+	}
+}
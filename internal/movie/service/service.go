@@ -2,68 +2,151 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/go-rest-api/internal/movie/entity"
 	"github.com/go-rest-api/internal/movie/repository"
+	"github.com/go-rest-api/pkg/cache"
+	"github.com/go-rest-api/pkg/tenant"
 	"github.com/opentracing/opentracing-go"
+	"sort"
+	"strings"
+	"time"
 )
 
+// Catalogue change event types
+const (
+	EventMovieCreated = "movie.created"
+	EventMovieUpdated = "movie.updated"
+	EventMovieDeleted = "movie.deleted"
+)
+
+// Service constants
+const (
+	// maxBatchIDs is the maximum number of movies fetched with ?ids=
+	maxBatchIDs = 100
+
+	defaultStatsCacheTTL = time.Minute
+)
+
+// Service errors
+var (
+	// ErrInvalidQuery returned when the requested fields, includes or ids are not valid
+	ErrInvalidQuery = errors.New("invalid query")
+	// ErrMovieNotFound returned when the movie does not exist
+	ErrMovieNotFound = repository.ErrMovieNotFound
+)
+
+// EventPublisher publishes the catalogue change events
+type EventPublisher interface {
+	Publish(scope string, eventType string, data interface{})
+}
+
 type MovieServiceFactory interface {
-	GetAllMovies(ctx context.Context) ([]entity.MovieResp, error)
-	GetMovie(ctx context.Context, movieId int64) (entity.MovieResp, error)
+	GetAllMovies(ctx context.Context, query entity.MovieQuery) ([]entity.MovieDoc, error)
+	GetMovie(ctx context.Context, movieId int64, query entity.MovieQuery) (entity.MovieDoc, error)
 	SaveMovie(ctx context.Context, movieRepo entity.MovieRepo) (entity.MovieRepo, error)
+	FindDuplicates(ctx context.Context, movieId int64, minScore float64) ([]entity.MovieDuplicate, error)
+	MergeMovies(ctx context.Context, targetId int64, sourceId int64) (entity.MovieResp, error)
+	GetMovieStats(ctx context.Context, query entity.MovieStatsQuery) (entity.MovieStats, error)
+	LookupMovie(ctx context.Context, provider string, externalId string) (entity.MovieResp, error)
+	ImportMovies(ctx context.Context, items []entity.MovieImportItem) ([]entity.MovieImportResult, error)
+}
+
+// Options of the movie service
+type Options struct {
+	// StatsCacheTTL is how long the catalogue statistics are cached
+	StatsCacheTTL time.Duration
+}
+
+// includer embeds a related resource into the movie documents
+type includer struct {
+	// columns needed on the movies to resolve the relation
+	columns []string
+	// embed returns the related resource per movie ID
+	embed func(ctx context.Context, movies []entity.MovieRepo) (map[int64]interface{}, error)
 }
 
 type MovieService struct {
-	repo repository.MovieRepositoryFactory
+	repo       repository.MovieRepositoryFactory
+	events     EventPublisher
+	includes   map[string]includer
+	statsCache *cache.TTL
 }
 
-func NewMovieService(repo repository.MovieRepositoryFactory) (*MovieService, error) {
-	return &MovieService{
-		repo: repo,
-	}, nil
+func NewMovieService(repo repository.MovieRepositoryFactory, events EventPublisher, options Options) (*MovieService, error) {
+	if options.StatsCacheTTL <= 0 {
+		options.StatsCacheTTL = defaultStatsCacheTTL
+	}
+
+	m := &MovieService{
+		repo:       repo,
+		events:     events,
+		statsCache: cache.NewTTL(options.StatsCacheTTL, statsLoadTimeout),
+	}
+
+	m.includes = map[string]includer{
+		"genres":       {columns: []string{"genre"}, embed: embedGenres},
+		"credits":      {embed: m.embedCredits},
+		"external_ids": {embed: m.embedExternalIDs},
+	}
+
+	return m, nil
 }
 
-func (m *MovieService) GetAllMovies(ctx context.Context) ([]entity.MovieResp, error) {
+func (m *MovieService) GetAllMovies(ctx context.Context, query entity.MovieQuery) ([]entity.MovieDoc, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "")
 	defer span.Finish()
 
-	var movieResps []entity.MovieResp
-	movieRepos, err := m.repo.GetAllMovies(ctx)
+	var movieDocs []entity.MovieDoc
+
+	columns, err := m.columns(query)
 	if err != nil {
-		return movieResps, err
+		return movieDocs, err
 	}
 
-	for _, movieRepo := range movieRepos {
-		movieResp := entity.MovieResp{
-			ID:       movieRepo.ID,
-			Name:     movieRepo.Name,
-			Duration: movieRepo.Duration,
-			Genre:    movieRepo.Genre,
-		}
-		movieResps = append(movieResps, movieResp)
+	var movieRepos []entity.MovieRepo
+	if len(query.IDs) > 0 {
+		movieRepos, err = m.getMoviesByIDs(ctx, query.IDs, columns)
+	} else {
+		movieRepos, err = m.repo.GetAllMovies(ctx, columns)
+	}
+	if err != nil {
+		return movieDocs, err
 	}
 
-	return movieResps, nil
+	return m.project(ctx, movieRepos, query)
 }
 
-func (m *MovieService) GetMovie(ctx context.Context, movieId int64) (entity.MovieResp, error) {
+func (m *MovieService) GetMovie(ctx context.Context, movieId int64, query entity.MovieQuery) (entity.MovieDoc, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "")
 	defer span.Finish()
 
-	var movieResp entity.MovieResp
-	movieRepo, err := m.repo.GetMovie(ctx, movieId)
+	var movieDoc entity.MovieDoc
+
+	columns, err := m.columns(query)
 	if err != nil {
-		return movieResp, err
+		return movieDoc, err
 	}
 
-	movieResp = entity.MovieResp{
-		ID:       movieRepo.ID,
-		Name:     movieRepo.Name,
-		Duration: movieRepo.Duration,
-		Genre:    movieRepo.Genre,
+	movieRepo, err := m.repo.GetMovie(ctx, movieId, columns)
+	if err == repository.ErrMovieNotFound {
+		// Merged movies resolve to the surviving one
+		toId, redirectErr := m.repo.GetMovieRedirect(ctx, movieId)
+		if redirectErr == nil {
+			return movieDoc, &MovedError{MovieID: toId}
+		}
+	}
+	if err != nil {
+		return movieDoc, err
 	}
 
-	return movieResp, nil
+	movieDocs, err := m.project(ctx, []entity.MovieRepo{movieRepo}, query)
+	if err != nil {
+		return movieDoc, err
+	}
+
+	return movieDocs[0], nil
 }
 
 func (m *MovieService) SaveMovie(ctx context.Context, movieRepo entity.MovieRepo) (entity.MovieRepo, error) {
@@ -75,5 +158,188 @@ func (m *MovieService) SaveMovie(ctx context.Context, movieRepo entity.MovieRepo
 		return movie, err
 	}
 
+	m.publish(ctx, EventMovieCreated, movie)
+
 	return movie, nil
 }
+
+// getMoviesByIDs fetches the movies in the requested order, unknown IDs are skipped
+func (m *MovieService) getMoviesByIDs(ctx context.Context, movieIds []int64, columns []string) ([]entity.MovieRepo, error) {
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, id := range movieIds {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) > maxBatchIDs {
+		return nil, fmt.Errorf("%w: at most %d ids are allowed", ErrInvalidQuery, maxBatchIDs)
+	}
+
+	movieRepos, err := m.repo.GetMoviesByIDs(ctx, ids, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]entity.MovieRepo, len(movieRepos))
+	for _, movieRepo := range movieRepos {
+		byID[movieRepo.ID] = movieRepo
+	}
+
+	var ordered []entity.MovieRepo
+	for _, id := range ids {
+		if movieRepo, ok := byID[id]; ok {
+			ordered = append(ordered, movieRepo)
+		}
+	}
+
+	return ordered, nil
+}
+
+// columns validates the query and returns the columns the repository has to select
+func (m *MovieService) columns(query entity.MovieQuery) ([]string, error) {
+	fields := query.Fields
+	if len(fields) == 0 {
+		fields = entity.MovieFields
+	}
+
+	columns := []string{"id", "updated_at"}
+	selected := map[string]bool{"id": true, "updated_at": true}
+	add := func(column string) {
+		if !selected[column] {
+			selected[column] = true
+			columns = append(columns, column)
+		}
+	}
+
+	for _, field := range fields {
+		if !isMovieField(field) {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, field)
+		}
+		add(field)
+	}
+
+	for _, name := range query.Include {
+		inc, ok := m.includes[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported include %q, supported: %s", ErrInvalidQuery, name, strings.Join(m.includeNames(), ","))
+		}
+		for _, column := range inc.columns {
+			add(column)
+		}
+	}
+
+	return columns, nil
+}
+
+// project builds the movie documents with the requested fields and embedded resources
+func (m *MovieService) project(ctx context.Context, movieRepos []entity.MovieRepo, query entity.MovieQuery) ([]entity.MovieDoc, error) {
+	fields := query.Fields
+	if len(fields) == 0 {
+		fields = entity.MovieFields
+	}
+
+	movieDocs := make([]entity.MovieDoc, 0, len(movieRepos))
+	for _, movieRepo := range movieRepos {
+		movieDoc := entity.MovieDoc{
+			Fields:    map[string]interface{}{"id": movieRepo.ID},
+			UpdatedAt: movieRepo.UpdatedAt,
+		}
+		for _, field := range fields {
+			movieDoc.Fields[field] = movieField(movieRepo, field)
+		}
+		movieDocs = append(movieDocs, movieDoc)
+	}
+
+	for _, name := range query.Include {
+		related, err := m.includes[name].embed(ctx, movieRepos)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, movieRepo := range movieRepos {
+			movieDocs[i].Fields[name] = related[movieRepo.ID]
+		}
+	}
+
+	return movieDocs, nil
+}
+
+// includeNames returns the supported includes
+func (m *MovieService) includeNames() []string {
+	var names []string
+	for name := range m.includes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// publish sends the movie change to the event subscribers of the tenant
+func (m *MovieService) publish(ctx context.Context, eventType string, movieRepo entity.MovieRepo) {
+	if m.events == nil {
+		return
+	}
+
+	tenantID, _ := tenant.FromContext(ctx)
+	m.events.Publish(tenantID, eventType, toMovieResp(movieRepo))
+}
+
+func toMovieResp(movieRepo entity.MovieRepo) entity.MovieResp {
+	return entity.MovieResp{
+		ID:        movieRepo.ID,
+		Name:      movieRepo.Name,
+		Duration:  movieRepo.Duration,
+		Genre:     movieRepo.Genre,
+		CreatedAt: movieRepo.CreatedAt,
+		UpdatedAt: movieRepo.UpdatedAt,
+	}
+}
+
+// embedGenres embeds the genres of the movie, the genre column holds a comma separated list
+func embedGenres(ctx context.Context, movieRepos []entity.MovieRepo) (map[int64]interface{}, error) {
+	genres := make(map[int64]interface{}, len(movieRepos))
+	for _, movieRepo := range movieRepos {
+		related := make([]map[string]string, 0)
+		for _, genre := range strings.Split(movieRepo.Genre, ",") {
+			if genre = strings.TrimSpace(genre); genre != "" {
+				related = append(related, map[string]string{"name": genre})
+			}
+		}
+		genres[movieRepo.ID] = related
+	}
+
+	return genres, nil
+}
+
+func isMovieField(field string) bool {
+	for _, f := range entity.MovieFields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+func movieField(movieRepo entity.MovieRepo, field string) interface{} {
+	switch field {
+	case "id":
+		return movieRepo.ID
+	case "name":
+		return movieRepo.Name
+	case "duration":
+		return movieRepo.Duration
+	case "genre":
+		return movieRepo.Genre
+	case "created_at":
+		return movieRepo.CreatedAt
+	case "updated_at":
+		return movieRepo.UpdatedAt
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-rest-api/internal/movie/entity"
+	"github.com/go-rest-api/pkg/tenant"
+	"github.com/opentracing/opentracing-go"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stats constants
+const (
+	defaultBucketSize = 30
+	maxBucketSize     = 600
+	groupByGenre      = "genre"
+	// statsLoadTimeout bounds the aggregation shared by the concurrent requests of a query
+	statsLoadTimeout = 30 * time.Second
+)
+
+// GetMovieStats returns the catalogue statistics, cached for the configured TTL per query
+func (m *MovieService) GetMovieStats(ctx context.Context, query entity.MovieStatsQuery) (entity.MovieStats, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "")
+	defer span.Finish()
+
+	var stats entity.MovieStats
+
+	if query.GroupBy != "" && query.GroupBy != groupByGenre {
+		return stats, fmt.Errorf("%w: unsupported group_by %q, supported: %s", ErrInvalidQuery, query.GroupBy, groupByGenre)
+	}
+
+	if query.BucketSize == 0 {
+		query.BucketSize = defaultBucketSize
+	}
+	if query.BucketSize < 1 || query.BucketSize > maxBucketSize {
+		return stats, fmt.Errorf("%w: bucket_size must be between 1 and %d", ErrInvalidQuery, maxBucketSize)
+	}
+
+	if query.MinDuration < 0 || query.MaxDuration < 0 || (query.MaxDuration > 0 && query.MinDuration > query.MaxDuration) {
+		return stats, fmt.Errorf("%w: invalid duration range", ErrInvalidQuery)
+	}
+
+	// The statistics are cached per tenant
+	tenantID, _ := tenant.FromContext(ctx)
+	value, err := m.statsCache.GetOrLoad(ctx, fmt.Sprintf("%s|%+v", tenantID, query), func(ctx context.Context) (interface{}, error) {
+		return m.loadMovieStats(ctx, query)
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	return value.(entity.MovieStats), nil
+}
+
+// loadMovieStats runs the aggregation queries, the genre column may hold several comma separated genres
+// so the per genre rows are split and merged here
+func (m *MovieService) loadMovieStats(ctx context.Context, query entity.MovieStatsQuery) (entity.MovieStats, error) {
+	var stats entity.MovieStats
+
+	genreRows, err := m.repo.GetGenreDurationStats(ctx, query.MovieStatsFilter)
+	if err != nil {
+		return stats, err
+	}
+
+	buckets, err := m.repo.GetDurationHistogram(ctx, query.MovieStatsFilter, query.BucketSize)
+	if err != nil {
+		return stats, err
+	}
+
+	var total entity.DurationStats
+	groups := make(map[string]entity.DurationStats)
+	for _, row := range genreRows {
+		rowStats := entity.DurationStats{Count: row.Count, Min: row.Min, Max: row.Max, Avg: row.Avg}
+		total = mergeDurationStats(total, rowStats)
+
+		for _, genre := range strings.Split(row.Group, ",") {
+			if genre = strings.TrimSpace(genre); genre != "" {
+				groups[genre] = mergeDurationStats(groups[genre], rowStats)
+			}
+		}
+	}
+
+	stats.Duration = total
+	stats.Genres = make([]entity.GenreCount, 0, len(groups))
+	for genre, group := range groups {
+		stats.Genres = append(stats.Genres, entity.GenreCount{Genre: genre, Count: group.Count})
+	}
+	sort.Slice(stats.Genres, func(i, j int) bool {
+		if stats.Genres[i].Count != stats.Genres[j].Count {
+			return stats.Genres[i].Count > stats.Genres[j].Count
+		}
+		return stats.Genres[i].Genre < stats.Genres[j].Genre
+	})
+
+	stats.Histogram = make([]entity.HistogramBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		stats.Histogram = append(stats.Histogram, entity.HistogramBucket{
+			From:  bucket.Bucket,
+			To:    bucket.Bucket + query.BucketSize,
+			Count: bucket.Count,
+		})
+	}
+
+	if query.GroupBy == groupByGenre {
+		stats.Groups = groups
+	}
+
+	return stats, nil
+}
+
+// mergeDurationStats combines two aggregates, the average is weighted by the counts
+func mergeDurationStats(a, b entity.DurationStats) entity.DurationStats {
+	if a.Count == 0 {
+		return b
+	}
+	if b.Count == 0 {
+		return a
+	}
+
+	merged := entity.DurationStats{
+		Count: a.Count + b.Count,
+		Min:   a.Min,
+		Max:   a.Max,
+		Avg:   (a.Avg*float64(a.Count) + b.Avg*float64(b.Count)) / float64(a.Count+b.Count),
+	}
+	if b.Min < merged.Min {
+		merged.Min = b.Min
+	}
+	if b.Max > merged.Max {
+		merged.Max = b.Max
+	}
+
+	return merged
+}
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE movies (
+    id       BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    name     VARCHAR(255)    NOT NULL,
+    duration INT UNSIGNED    NOT NULL DEFAULT 0,
+    genre    VARCHAR(255)    NOT NULL DEFAULT '',
+    PRIMARY KEY (id),
+    KEY idx_movies_name (name)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- +goose Down
+DROP TABLE movies;
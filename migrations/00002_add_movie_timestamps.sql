@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE movies
+    ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD KEY idx_movies_updated_at (updated_at);
+
+-- +goose Down
+ALTER TABLE movies
+    DROP KEY idx_movies_updated_at,
+    DROP COLUMN updated_at,
+    DROP COLUMN created_at;
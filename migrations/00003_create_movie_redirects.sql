@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE movie_redirects (
+    from_id BIGINT UNSIGNED NOT NULL,
+    to_id   BIGINT UNSIGNED NOT NULL,
+    PRIMARY KEY (from_id),
+    KEY idx_movie_redirects_to_id (to_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- +goose Down
+DROP TABLE movie_redirects;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE collections (
+    id          BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    name        VARCHAR(255)    NOT NULL,
+    description TEXT            NOT NULL,
+    created_at  DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE collection_items (
+    collection_id BIGINT UNSIGNED NOT NULL,
+    movie_id      BIGINT UNSIGNED NOT NULL,
+    position      INT UNSIGNED    NOT NULL,
+    PRIMARY KEY (collection_id, movie_id),
+    KEY idx_collection_items_movie_id (movie_id),
+    CONSTRAINT fk_collection_items_collection FOREIGN KEY (collection_id) REFERENCES collections (id) ON DELETE CASCADE,
+    CONSTRAINT fk_collection_items_movie FOREIGN KEY (movie_id) REFERENCES movies (id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- +goose Down
+DROP TABLE collection_items;
+DROP TABLE collections;
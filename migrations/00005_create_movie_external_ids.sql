@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE movie_external_ids (
+    movie_id    BIGINT UNSIGNED NOT NULL,
+    provider    VARCHAR(32)     NOT NULL,
+    external_id VARCHAR(64)     NOT NULL,
+    UNIQUE KEY uq_movie_external_ids_external_id (provider, external_id),
+    UNIQUE KEY uq_movie_external_ids_movie (movie_id, provider),
+    CONSTRAINT fk_movie_external_ids_movie FOREIGN KEY (movie_id) REFERENCES movies (id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- +goose Down
+DROP TABLE movie_external_ids;
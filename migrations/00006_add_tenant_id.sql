@@ -0,0 +1,33 @@
+-- +goose Up
+ALTER TABLE movies
+    ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default' AFTER id,
+    ADD KEY idx_movies_tenant_name (tenant_id, name);
+
+ALTER TABLE movie_redirects
+    ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default' FIRST;
+
+ALTER TABLE collections
+    ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default' AFTER id,
+    ADD KEY idx_collections_tenant_name (tenant_id, name);
+
+ALTER TABLE movie_external_ids
+    ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default' FIRST,
+    DROP KEY uq_movie_external_ids_external_id,
+    ADD UNIQUE KEY uq_movie_external_ids_external_id (tenant_id, provider, external_id);
+
+-- +goose Down
+ALTER TABLE movie_external_ids
+    DROP KEY uq_movie_external_ids_external_id,
+    ADD UNIQUE KEY uq_movie_external_ids_external_id (provider, external_id),
+    DROP COLUMN tenant_id;
+
+ALTER TABLE collections
+    DROP KEY idx_collections_tenant_name,
+    DROP COLUMN tenant_id;
+
+ALTER TABLE movie_redirects
+    DROP COLUMN tenant_id;
+
+ALTER TABLE movies
+    DROP KEY idx_movies_tenant_name,
+    DROP COLUMN tenant_id;
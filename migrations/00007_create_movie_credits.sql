@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE movie_credits (
+    tenant_id VARCHAR(64)     NOT NULL DEFAULT 'default',
+    movie_id  BIGINT UNSIGNED NOT NULL,
+    position  INT UNSIGNED    NOT NULL,
+    role      VARCHAR(32)     NOT NULL,
+    name      VARCHAR(255)    NOT NULL,
+    UNIQUE KEY uq_movie_credits_person (movie_id, role, name),
+    CONSTRAINT fk_movie_credits_movie FOREIGN KEY (movie_id) REFERENCES movies (id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- +goose Down
+DROP TABLE movie_credits;
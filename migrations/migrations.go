@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL schema migrations of the service
+package migrations
+
+import "embed"
+
+// FS holds the goose SQL migrations, named <version>_<description>.sql
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-rest-api/pkg/response"
+)
+
+// Tokens are the bearer tokens allowed through, they can change while serving, e.g. on a secret rotation
+type Tokens struct {
+	mu     sync.RWMutex
+	tokens []string
+}
+
+// NewTokens creates new Tokens
+func NewTokens(tokens []string) *Tokens {
+	t := &Tokens{}
+	t.Set(tokens)
+	return t
+}
+
+// Set replaces the allowed tokens
+func (t *Tokens) Set(tokens []string) {
+	tokens = append([]string(nil), tokens...)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.tokens = tokens
+}
+
+// Require only lets through the requests presenting one of the current tokens
+func (t *Tokens) Require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t.mu.RLock()
+		tokens := t.tokens
+		t.mu.RUnlock()
+
+		RequireBearer(tokens, next)(w, r)
+	}
+}
+
+// RequireBearer only lets through the requests presenting one of the tokens as
+// "Authorization: Bearer <token>". Every request is rejected when no token is configured.
+func RequireBearer(tokens []string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validToken(tokens, bearerToken(r)) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="go-rest-api"`)
+			response.WriteAPIErrorMessage(w, response.APIErrUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// bearerToken extracts the token of the Authorization header
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return ""
+	}
+
+	return strings.TrimSpace(header[7:])
+}
+
+func validToken(tokens []string, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	valid := false
+	for _, t := range tokens {
+		if t != "" && subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			valid = true
+		}
+	}
+
+	return valid
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireBearer(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+	tests := []struct {
+		name   string
+		tokens []string
+		header string
+		want   int
+	}{
+		{"valid token", []string{"token-a", "token-b"}, "Bearer token-b", http.StatusNoContent},
+		{"case-insensitive scheme", []string{"token-a"}, "bearer token-a", http.StatusNoContent},
+		{"unknown token", []string{"token-a"}, "Bearer token-c", http.StatusUnauthorized},
+		{"basic scheme", []string{"token-a"}, "Basic token-a", http.StatusUnauthorized},
+		{"no header", []string{"token-a"}, "", http.StatusUnauthorized},
+		{"empty token", []string{"token-a", ""}, "Bearer ", http.StatusUnauthorized},
+		{"no token configured", nil, "Bearer token-a", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		RequireBearer(tt.tokens, ok)(w, r)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate challenge", tt.name)
+		}
+	}
+}
+
+func TestTokensFollowTheRotation(t *testing.T) {
+	tokens := NewTokens([]string{"token-a"})
+	handler := tokens.Require(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	status := func(token string) int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+
+	if code := status("token-a"); code != http.StatusNoContent {
+		t.Errorf("status = %d before the rotation, want %d", code, http.StatusNoContent)
+	}
+
+	tokens.Set([]string{"token-b"})
+	if code := status("token-a"); code != http.StatusUnauthorized {
+		t.Errorf("status of the rotated token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := status("token-b"); code != http.StatusNoContent {
+		t.Errorf("status of the new token = %d, want %d", code, http.StatusNoContent)
+	}
+}
@@ -0,0 +1,166 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix"
+)
+
+// Config of a circuit breaker, the zero fields take the defaults
+type Config struct {
+	// Timeout of a call, the breaker answers ErrTimeout after it
+	Timeout time.Duration
+	// MaxConcurrent calls, the extra ones are rejected
+	MaxConcurrent int
+	// RequestVolume is the minimum of calls in the rolling window before the breaker can trip
+	RequestVolume int
+	// ErrorPercent of the calls failing in the rolling window which trips the breaker
+	ErrorPercent int
+	// SleepWindow after tripping, before a single call tests whether the dependency recovered
+	SleepWindow time.Duration
+}
+
+// Breaker errors, they tell the response layer whether the dependency timed out or is unavailable
+var (
+	ErrOpen          error = unavailableError("circuit open")
+	ErrMaxConcurrent error = unavailableError("too many concurrent calls")
+	ErrTimeout       error = timeoutError{}
+)
+
+type unavailableError string
+
+func (e unavailableError) Error() string { return string(e) }
+
+// Unavailable tells the response layer that the dependency is unavailable
+func (e unavailableError) Unavailable() bool { return true }
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "circuit timeout" }
+
+// Timeout tells the response layer that the dependency timed out
+func (timeoutError) Timeout() bool { return true }
+
+var (
+	mu         sync.Mutex
+	enabled    = true
+	defaults   Config
+	commands   map[string]Config
+	configured = make(map[string]bool)
+)
+
+// SetEnabled turns the breakers on or off, the calls run unguarded when off
+func SetEnabled(on bool) {
+	mu.Lock()
+	enabled = on
+	mu.Unlock()
+}
+
+// Configure sets the default config and the configs by breaker name, the breakers in use are reconfigured
+func Configure(defaultConfig Config, commandConfigs map[string]Config) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	defaults = defaultConfig
+	commands = commandConfigs
+	for name := range configured {
+		configure(name)
+	}
+	for name := range commands {
+		configure(name)
+	}
+}
+
+// configure applies the config of the breaker, the caller holds mu
+func configure(name string) {
+	config := defaults
+	if c, ok := commands[name]; ok {
+		if c.Timeout > 0 {
+			config.Timeout = c.Timeout
+		}
+		if c.MaxConcurrent > 0 {
+			config.MaxConcurrent = c.MaxConcurrent
+		}
+		if c.RequestVolume > 0 {
+			config.RequestVolume = c.RequestVolume
+		}
+		if c.ErrorPercent > 0 {
+			config.ErrorPercent = c.ErrorPercent
+		}
+		if c.SleepWindow > 0 {
+			config.SleepWindow = c.SleepWindow
+		}
+	}
+
+	hystrix.ConfigureCommand(name, hystrix.CommandConfig{
+		Timeout:                int(config.Timeout / time.Millisecond),
+		MaxConcurrentRequests:  config.MaxConcurrent,
+		RequestVolumeThreshold: config.RequestVolume,
+		SleepWindow:            int(config.SleepWindow / time.Millisecond),
+		ErrorPercentThreshold:  config.ErrorPercent,
+	})
+	configured[name] = true
+}
+
+// Do runs fn in the named breaker. The errors of fn count as failures, fn returns nil for the errors
+// which do not tell about the health of the dependency. The context of fn is cancelled when Do returns,
+// the breaker timeout included.
+func Do(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	mu.Lock()
+	on := enabled
+	if on && !configured[name] {
+		configure(name)
+	}
+	mu.Unlock()
+
+	if !on {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	err := hystrix.DoC(ctx, name, fn, nil)
+	switch {
+	case errors.Is(err, hystrix.ErrCircuitOpen):
+		return ErrOpen
+	case errors.Is(err, hystrix.ErrMaxConcurrency):
+		return ErrMaxConcurrent
+	case errors.Is(err, hystrix.ErrTimeout):
+		return ErrTimeout
+	}
+
+	return err
+}
+
+// State of a breaker
+type State struct {
+	Name string `json:"name"`
+	Open bool   `json:"open"`
+}
+
+// States returns the state of the breakers in use, sorted by name
+func States() []State {
+	mu.Lock()
+	names := make([]string, 0, len(configured))
+	for name := range configured {
+		names = append(names, name)
+	}
+	mu.Unlock()
+	sort.Strings(names)
+
+	states := make([]State, 0, len(names))
+	for _, name := range names {
+		circuit, _, err := hystrix.GetCircuit(name)
+		if err != nil {
+			continue
+		}
+		states = append(states, State{Name: name, Open: circuit.IsOpen()})
+	}
+
+	return states
+}
@@ -0,0 +1,90 @@
+package breaker
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/go-rest-api/pkg/response"
+)
+
+// ErrStreamClosed returned when a dashboard connects to a closed stream
+var ErrStreamClosed = errors.New("the breaker stream is closed")
+
+// Stream publishes the metrics of the breakers every second to the hystrix dashboards
+type Stream struct {
+	handler *hystrix.StreamHandler
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewStream creates new Stream, started until Close
+func NewStream() *Stream {
+	handler := hystrix.NewStreamHandler()
+	handler.Start()
+
+	return &Stream{handler: handler, done: make(chan struct{})}
+}
+
+// ServeHTTP streams the metrics as server-sent events until the client goes or the stream is closed,
+// the writer must flush
+func (s *Stream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		response.WriteAPIError(w, response.APIInternalError, "streaming unsupported")
+		return
+	}
+
+	select {
+	case <-s.done:
+		response.WriteAPIError(w, response.APIErrServiceUnavailable, ErrStreamClosed)
+		return
+	default:
+	}
+
+	// The hystrix handler stops on the close notification, sent when the client goes or the stream closes
+	notify := make(chan bool, 1)
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-r.Context().Done():
+		case <-s.done:
+		case <-finished:
+			return
+		}
+		notify <- true
+	}()
+
+	s.handler.ServeHTTP(&streamWriter{ResponseWriter: w, flusher: flusher, notify: notify}, r)
+}
+
+// Close stops the publication and ends the connected streams so the server can shut down
+func (s *Stream) Close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.handler.Stop()
+	})
+}
+
+// streamWriter notifies the hystrix handler of the end of the stream
+type streamWriter struct {
+	http.ResponseWriter
+	flusher http.Flusher
+	notify  chan bool
+}
+
+func (w *streamWriter) Flush() {
+	w.flusher.Flush()
+}
+
+// CloseNotify is the only notification of the hystrix handler
+func (w *streamWriter) CloseNotify() <-chan bool {
+	return w.notify
+}
+
+// SummaryHandler answers the Summary of the breakers
+func SummaryHandler(w http.ResponseWriter, r *http.Request) {
+	response.WriteAPIOKWithData(w, Summary())
+}
@@ -0,0 +1,139 @@
+package breaker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-rest-api/pkg/auth"
+)
+
+func TestSummaryHandlerBehindTheAdminTokens(t *testing.T) {
+	_ = Do(context.Background(), "handler-test-ok", func(ctx context.Context) error { return nil })
+	_ = Do(context.Background(), "handler-test-down", func(ctx context.Context) error { return errors.New("down") })
+	waitForCalls(t, "handler-test-ok", "handler-test-down")
+
+	tokens := auth.NewTokens([]string{"admin"})
+	handler := tokens.Require(SummaryHandler)
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"editor token", "editor", http.StatusUnauthorized},
+		{"admin token", "admin", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/v1/admin/breakers", nil)
+		if tt.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/admin/breakers", nil)
+	r.Header.Set("Authorization", "Bearer admin")
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+	var summary []Metrics
+	if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]Metrics)
+	for _, m := range summary {
+		byName[m.Name] = m
+	}
+	if m := byName["handler-test-ok"]; m.RequestVolume == 0 || m.ErrorPercent != 0 || m.State != StateClosed {
+		t.Errorf("handler-test-ok = %+v, want calls without error", m)
+	}
+	if m := byName["handler-test-down"]; m.RequestVolume == 0 || m.ErrorPercent != 100 {
+		t.Errorf("handler-test-down = %+v, want failed calls", m)
+	}
+
+	// The rotated tokens replace the previous ones
+	tokens.Set([]string{"rotated"})
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status with the previous token = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+// waitForCalls waits until the metrics of the breakers record their call, hystrix updates them asynchronously
+func waitForCalls(t *testing.T, names ...string) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		recorded := 0
+		for _, m := range Summary() {
+			for _, name := range names {
+				if m.Name == name && m.RequestVolume > 0 {
+					recorded++
+				}
+			}
+		}
+		if recorded == len(names) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("the calls of %v are not recorded", names)
+}
+
+func TestStreamCloseEndsTheStreams(t *testing.T) {
+	stream := NewStream()
+	server := httptest.NewServer(stream)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if contentType := res.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", contentType)
+	}
+
+	ended := make(chan error, 1)
+	go func() {
+		_, err := ioutil.ReadAll(res.Body)
+		ended <- err
+	}()
+
+	stream.Close()
+	select {
+	case err := <-ended:
+		if err != nil {
+			t.Errorf("stream ended with %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream is still open after Close")
+	}
+
+	// Closing again is harmless and the new dashboards are turned away
+	stream.Close()
+	res, err = http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status after Close = %d, want %d", res.StatusCode, http.StatusServiceUnavailable)
+	}
+}
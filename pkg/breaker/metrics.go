@@ -0,0 +1,132 @@
+package breaker
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix"
+	metricCollector "github.com/afex/hystrix-go/hystrix/metric_collector"
+	"github.com/afex/hystrix-go/hystrix/rolling"
+)
+
+// Metrics of a breaker over the rolling window of 10 seconds, the latencies over the last minute
+type Metrics struct {
+	Name string `json:"name"`
+	// State is open or closed
+	State         string  `json:"state"`
+	RequestVolume uint64  `json:"request_volume"`
+	ErrorPercent  float64 `json:"error_percent"`
+	Latency       Latency `json:"latency_ms"`
+}
+
+// Latency percentiles of the calls in milliseconds
+type Latency struct {
+	Mean uint32 `json:"mean"`
+	P50  uint32 `json:"p50"`
+	P90  uint32 `json:"p90"`
+	P99  uint32 `json:"p99"`
+	Max  uint32 `json:"max"`
+}
+
+// Breaker states
+const (
+	StateOpen   = "open"
+	StateClosed = "closed"
+)
+
+var (
+	collectorsMu sync.Mutex
+	collectors   = make(map[string]*collector)
+)
+
+func init() {
+	metricCollector.Registry.Register(func(name string) metricCollector.MetricCollector {
+		c := &collector{}
+		c.Reset()
+
+		collectorsMu.Lock()
+		collectors[name] = c
+		collectorsMu.Unlock()
+
+		return c
+	})
+}
+
+// collector keeps the metrics of the summary, hystrix updates it after every call
+type collector struct {
+	mu       sync.RWMutex
+	requests *rolling.Number
+	errors   *rolling.Number
+	latency  *rolling.Timing
+}
+
+func (c *collector) Update(result metricCollector.MetricResult) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	c.requests.Increment(result.Attempts)
+	c.errors.Increment(result.Errors)
+	c.latency.Add(result.RunDuration)
+}
+
+func (c *collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests = rolling.NewNumber()
+	c.errors = rolling.NewNumber()
+	c.latency = rolling.NewTiming()
+}
+
+func (c *collector) metrics(name string, now time.Time) Metrics {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	m := Metrics{Name: name, State: StateClosed, RequestVolume: uint64(c.requests.Sum(now))}
+	if m.RequestVolume > 0 {
+		m.ErrorPercent = c.errors.Sum(now) / float64(m.RequestVolume) * 100
+	}
+
+	m.Latency = Latency{
+		Mean: c.latency.Mean(),
+		P50:  c.latency.Percentile(50),
+		P90:  c.latency.Percentile(90),
+		P99:  c.latency.Percentile(99),
+		Max:  c.latency.Percentile(100),
+	}
+
+	return m
+}
+
+// Summary returns the metrics of the breakers in use, sorted by name
+func Summary() []Metrics {
+	collectorsMu.Lock()
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		names = append(names, name)
+	}
+	collectorsMu.Unlock()
+	sort.Strings(names)
+
+	now := time.Now()
+	summary := make([]Metrics, 0, len(names))
+	for _, name := range names {
+		circuit, _, err := hystrix.GetCircuit(name)
+		if err != nil {
+			continue
+		}
+
+		collectorsMu.Lock()
+		c := collectors[name]
+		collectorsMu.Unlock()
+
+		m := c.metrics(name, now)
+		if circuit.IsOpen() {
+			m.State = StateOpen
+		}
+		summary = append(summary, m)
+	}
+
+	return summary
+}
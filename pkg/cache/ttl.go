@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// call is a load in flight shared by the concurrent callers of the same key
+type call struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// TTL is an in-memory cache whose entries expire after a fixed duration.
+// Concurrent loads of the same key are collapsed into one.
+type TTL struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	loadTimeout time.Duration
+	entries     map[string]entry
+	inflight    map[string]*call
+}
+
+// NewTTL creates new TTL cache, the loads are bounded by loadTimeout unless 0
+func NewTTL(ttl time.Duration, loadTimeout time.Duration) *TTL {
+	return &TTL{
+		ttl:         ttl,
+		loadTimeout: loadTimeout,
+		entries:     make(map[string]entry),
+		inflight:    make(map[string]*call),
+	}
+}
+
+// GetOrLoad returns the cached value of the key or loads and caches it. Errors are not cached.
+// The load is shared by the callers of the key, it runs with the values of ctx but not its
+// cancellation, so a caller going away does not fail the others. Each caller stops waiting
+// when its own ctx is done.
+func (c *TTL) GetOrLoad(ctx context.Context, key string, load func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && time.Now().Before(e.expiresAt) {
+		c.mu.Unlock()
+		return e.value, nil
+	}
+
+	current, ok := c.inflight[key]
+	if !ok {
+		current = &call{done: make(chan struct{})}
+		c.inflight[key] = current
+		go c.load(detached{ctx}, key, current, load)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-current.done:
+		return current.value, current.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load runs the load of the call and caches its value
+func (c *TTL) load(ctx context.Context, key string, current *call, load func(ctx context.Context) (interface{}, error)) {
+	var cancel context.CancelFunc
+	if c.loadTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.loadTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	current.value, current.err = load(ctx)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if current.err == nil {
+		c.entries[key] = entry{value: current.value, expiresAt: time.Now().Add(c.ttl)}
+	}
+	c.evictExpired()
+	c.mu.Unlock()
+	close(current.done)
+}
+
+// evictExpired removes the expired entries, the caller holds the lock
+func (c *TTL) evictExpired() {
+	now := time.Now()
+	for key, e := range c.entries {
+		if now.After(e.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// detached keeps the values of the parent context, e.g. the tenant, without its deadline and cancellation
+type detached struct {
+	parent context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detached) Done() <-chan struct{} { return nil }
+
+func (detached) Err() error { return nil }
+
+func (d detached) Value(key interface{}) interface{} { return d.parent.Value(key) }
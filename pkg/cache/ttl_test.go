@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type contextKey struct{}
+
+func TestTTLExpiresTheEntries(t *testing.T) {
+	c := NewTTL(50*time.Millisecond, 0)
+
+	var loads int32
+	load := func(ctx context.Context) (interface{}, error) {
+		return atomic.AddInt32(&loads, 1), nil
+	}
+
+	for i := 0; i < 3; i++ {
+		value, err := c.GetOrLoad(context.Background(), "key", load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != int32(1) {
+			t.Errorf("GetOrLoad = %v before the expiry, want the first load", value)
+		}
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	value, err := c.GetOrLoad(context.Background(), "key", load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != int32(2) {
+		t.Errorf("GetOrLoad = %v after the expiry, want a new load", value)
+	}
+}
+
+func TestTTLDoesNotCacheErrors(t *testing.T) {
+	c := NewTTL(time.Minute, 0)
+
+	failure := errors.New("down")
+	if _, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		return nil, failure
+	}); err != failure {
+		t.Fatalf("err = %v, want %v", err, failure)
+	}
+
+	value, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		return "loaded", nil
+	})
+	if err != nil || value != "loaded" {
+		t.Errorf("GetOrLoad after an error = %v, %v, want a new load", value, err)
+	}
+}
+
+func TestTTLCoalescesTheConcurrentLoads(t *testing.T) {
+	c := NewTTL(time.Minute, 0)
+
+	var loads int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "loaded", nil
+	}
+
+	var wg sync.WaitGroup
+	values := make([]interface{}, 10)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], _ = c.GetOrLoad(context.Background(), "key", load)
+		}(i)
+	}
+
+	// Let the callers join the load in flight before it completes
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("loads = %d, want 1", loads)
+	}
+	for i, value := range values {
+		if value != "loaded" {
+			t.Errorf("caller %d got %v, want the shared load", i, value)
+		}
+	}
+}
+
+func TestTTLLoadOutlivesTheFirstCaller(t *testing.T) {
+	c := NewTTL(time.Minute, time.Second)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loadErr := make(chan error, 1)
+	load := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		loadErr <- ctx.Err()
+		return ctx.Value(contextKey{}), nil
+	}
+
+	first, cancel := context.WithCancel(context.WithValue(context.Background(), contextKey{}, "tenant"))
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrLoad(first, "key", load)
+		firstErr <- err
+	}()
+
+	<-started
+	second := make(chan interface{}, 1)
+	go func() {
+		value, _ := c.GetOrLoad(context.Background(), "key", load)
+		second <- value
+	}()
+
+	// The first caller goes away, it stops waiting but the load goes on for the second one
+	cancel()
+	if err := <-firstErr; err != context.Canceled {
+		t.Errorf("first caller err = %v, want %v", err, context.Canceled)
+	}
+	close(release)
+
+	if err := <-loadErr; err != nil {
+		t.Errorf("load ctx err = %v, want the load to outlive the first caller", err)
+	}
+	if value := <-second; value != "tenant" {
+		t.Errorf("second caller got %v, want the load with the values of the first caller", value)
+	}
+}
+
+func TestTTLBoundsTheLoad(t *testing.T) {
+	c := NewTTL(time.Minute, 20*time.Millisecond)
+
+	_, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -1,11 +1,25 @@
 package config
 
 import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/fsnotify/fsnotify"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
+// EnvPrefix of the environment variables overriding the config, e.g. APP_DATABASE_MASTER_PASSWORD
+const EnvPrefix = "APP"
+
+// current is the config loaded by SetConfig
+var (
+	mu      sync.RWMutex
+	current *Config
+)
+
 // SetConfig location
 func SetConfig(p string) {
 	viper.SetConfigName("App")
@@ -18,8 +32,189 @@ func SetConfig(p string) {
 		log.Fatal("config error: ", err)
 	}
 
+	cfg, err := Load(viper.GetViper())
+	if err != nil {
+		log.Fatal(err)
+	}
+	set(cfg)
+
 	viper.WatchConfig()
 	viper.OnConfigChange(func(e fsnotify.Event) {
 		log.Warn("Config file changed:", e.Name)
+
+		// The previous config stays current when the new one is invalid or cannot be applied
+		cfg, err := Load(viper.GetViper())
+		if err == nil {
+			err = apply(cfg)
+		}
+		if err != nil {
+			log.Error("config change rejected: ", err)
+		}
 	})
 }
+
+// Get returns the config loaded by SetConfig
+func Get() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return current
+}
+
+func set(cfg *Config) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	current = cfg
+}
+
+// WatchSecrets resolves the secret references of the config every secrets.refresh_interval,
+// the rotated values are applied to the subscribers of their keys, e.g. database.master.password.
+// The returned function stops the watch.
+func WatchSecrets() (stop func()) {
+	done := make(chan struct{})
+	interval := time.Duration(Get().Secrets.RefreshInterval) * time.Second
+	if interval <= 0 {
+		return func() {}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			if err := rotate(); err != nil {
+				log.Error("secret rotation error: ", err)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
+
+// rotate applies the rotated secrets of the current config. A file reload cannot run in between,
+// its change would be lost when the rotated copy of the previous config becomes current.
+func rotate() error {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+
+	rotated, keys, err := Get().rotateSecrets()
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	log.WithField("keys", keys).Warn("secrets rotated")
+	return applyLocked(rotated)
+}
+
+// LoadOptions tune the loading of a config outside of the server, e.g. to validate a deployment config in CI
+type LoadOptions struct {
+	// IgnoreEnv skips the APP_ environment overrides
+	IgnoreEnv bool
+	// SkipSecrets leaves the secret references unresolved
+	SkipSecrets bool
+}
+
+// LoadDir reads and validates the App.yaml of dir, or of ./configurations when missing from dir
+func LoadDir(dir string, options LoadOptions) (*Config, error) {
+	v := viper.New()
+	v.SetConfigName("App")
+	v.SetConfigType("yaml")
+	v.AddConfigPath(dir)
+	v.AddConfigPath("./configurations")
+
+	err := v.ReadInConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return load(v, options)
+}
+
+// LoadFile reads and validates the config file at path, with the defaults and the environment overrides
+func LoadFile(path string, options LoadOptions) (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	err := v.ReadInConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return load(v, options)
+}
+
+// Load unmarshals the settings of v on top of the defaults and the environment overrides, then validates them
+func Load(v *viper.Viper) (*Config, error) {
+	return load(v, LoadOptions{})
+}
+
+func load(v *viper.Viper, options LoadOptions) (*Config, error) {
+	if !options.IgnoreEnv {
+		v.SetEnvPrefix(EnvPrefix)
+		v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+		v.AutomaticEnv()
+	}
+
+	// viper only looks up the environment of the known keys, registering every default makes them all known
+	setDefaults(v, "", reflect.ValueOf(Default()))
+
+	cfg := Config{
+		file:      v.ConfigFileUsed(),
+		ignoreEnv: options.IgnoreEnv,
+	}
+	// The items are decoded over the defaults, viper has no defaults for the list items
+	if replicas, ok := v.Get("database.replicas").([]interface{}); ok {
+		for range replicas {
+			cfg.Database.Replicas = append(cfg.Database.Replicas, defaultDatabaseServer())
+		}
+	}
+	err := v.Unmarshal(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.resolveSecrets(!options.SkipSecrets)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// setDefaults registers the leaves of the struct under their mapstructure keys
+func setDefaults(v *viper.Viper, prefix string, value reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			setDefaults(v, key, value.Field(i))
+			continue
+		}
+
+		v.SetDefault(key, value.Field(i).Interface())
+	}
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// databaseConfig holds the database keys without default
+const databaseConfig = `
+database:
+  master:
+    host: 127.0.0.1
+    user: movies
+    name: movies
+  slave:
+    host: 127.0.0.1
+    user: movies
+    name: movies
+`
+
+// writeConfig writes the App.yaml of a test directory and returns its path
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "App.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// validConfig returns the defaults with the database keys
+func validConfig() Config {
+	cfg := Default()
+	for _, server := range []*DatabaseServer{&cfg.Database.Master, &cfg.Database.Slave} {
+		server.Host = "127.0.0.1"
+		server.User = "movies"
+		server.Name = "movies"
+	}
+
+	return cfg
+}
+
+func TestDefaultMissesOnlyTheDatabaseServers(t *testing.T) {
+	cfg := Default()
+
+	var validationErr *ValidationError
+	if err := cfg.Validate(); !errors.As(err, &validationErr) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+	for _, problem := range validationErr.Problems {
+		if !strings.HasPrefix(problem, "database.master.") && !strings.HasPrefix(problem, "database.slave.") {
+			t.Errorf("problem %q of the defaults, want only the database servers", problem)
+		}
+	}
+
+	cfg = validConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSampleConfigHoldsTheDefaults(t *testing.T) {
+	sample, err := ioutil.ReadFile("../../configurations/App.yaml.dist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadFile(writeConfig(t, string(sample)), LoadOptions{IgnoreEnv: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sample fills the keys without default and gives examples of the cache headers
+	defaults := validConfig()
+	for _, key := range ChangedKeys(cfg, &defaults) {
+		if !strings.HasPrefix(key, "http.cache_control.") {
+			t.Errorf("the sample differs from the default of %s", key)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     map[string]string
+		options LoadOptions
+		check   func(t *testing.T, cfg *Config)
+	}{
+		{
+			name:    "database keys only",
+			content: databaseConfig,
+			options: LoadOptions{IgnoreEnv: true},
+			check: func(t *testing.T, cfg *Config) {
+				defaults := validConfig()
+				if keys := ChangedKeys(cfg, &defaults); len(keys) > 0 {
+					t.Errorf("keys %v differ from the defaults", keys)
+				}
+			},
+		},
+		{
+			name:    "file values over the defaults",
+			content: databaseConfig + "app:\n  port: 9000\nlog:\n  level: warn\n",
+			options: LoadOptions{IgnoreEnv: true},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.App.Port != 9000 || cfg.Log.Level != "warn" {
+					t.Errorf("port %d and level %q, want the file values", cfg.App.Port, cfg.Log.Level)
+				}
+				if cfg.App.GracefulTimeout != 30 {
+					t.Errorf("graceful_timeout = %d, want the default", cfg.App.GracefulTimeout)
+				}
+			},
+		},
+		{
+			name:    "replica items take the server defaults",
+			content: databaseConfig + "  replicas:\n    - host: replica-1\n      user: movies\n      name: movies\n",
+			options: LoadOptions{IgnoreEnv: true},
+			check: func(t *testing.T, cfg *Config) {
+				if len(cfg.Database.Replicas) != 1 {
+					t.Fatalf("replicas = %v, want 1", cfg.Database.Replicas)
+				}
+				replica, defaults := cfg.Database.Replicas[0], defaultDatabaseServer()
+				if replica.Host != "replica-1" || replica.Port != defaults.Port || replica.Collation != defaults.Collation {
+					t.Errorf("replica = %+v, want the host over the server defaults", replica)
+				}
+			},
+		},
+		{
+			name:    "environment over the file",
+			content: databaseConfig + "app:\n  port: 9000\n",
+			env:     map[string]string{"APP_APP_PORT": "9100", "APP_AUTH_EDITOR_TOKENS": "token-a,token-b"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.App.Port != 9100 {
+					t.Errorf("port = %d, want the environment value", cfg.App.Port)
+				}
+				if tokens := Values(cfg.Auth.EditorTokens); len(tokens) != 2 || tokens[0] != "token-a" || tokens[1] != "token-b" {
+					t.Errorf("editor tokens = %v, want the comma separated list", tokens)
+				}
+			},
+		},
+		{
+			name:    "environment ignored",
+			content: databaseConfig + "app:\n  port: 9000\n",
+			env:     map[string]string{"APP_APP_PORT": "9100"},
+			options: LoadOptions{IgnoreEnv: true},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.App.Port != 9000 {
+					t.Errorf("port = %d, want the file value", cfg.App.Port)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+				defer os.Unsetenv(key)
+			}
+
+			cfg, err := LoadFile(writeConfig(t, tt.content), tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadFileReportsEveryProblem(t *testing.T) {
+	path := writeConfig(t, databaseConfig+"app:\n  port: 0\n  graceful_timeout: -1\nlog:\n  level: loud\n")
+
+	_, err := LoadFile(path, LoadOptions{IgnoreEnv: true})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+	if len(validationErr.Problems) != 3 {
+		t.Errorf("problems = %v, want the port, the graceful timeout and the level", validationErr.Problems)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *Config)
+		want   string
+	}{
+		{"unknown env", func(cfg *Config) { cfg.App.Env = "qa" }, "app.env"},
+		{"port out of range", func(cfg *Config) { cfg.App.Port = 70000 }, "app.port must be between 1 and 65535"},
+		{"negative graceful timeout", func(cfg *Config) { cfg.App.GracefulTimeout = -1 }, "app.graceful_timeout must be between 0 and 3600"},
+		{"graceful timeout too long", func(cfg *Config) { cfg.App.GracefulTimeout = 3601 }, "app.graceful_timeout must be between 0 and 3600"},
+		{"unknown log level", func(cfg *Config) { cfg.Log.Level = "loud" }, `log.level "loud" is not a log level`},
+		{"missing master host", func(cfg *Config) { cfg.Database.Master.Host = " " }, "database.master.host is required"},
+		{"idle over open conns", func(cfg *Config) {
+			cfg.Database.Master.MaxOpenConns = 5
+			cfg.Database.Master.MaxIdleConns = 10
+		}, "database.master.max_idle_conns must not exceed max_open_conns"},
+		{"unknown time zone", func(cfg *Config) { cfg.Database.Slave.Location = "Mars/Olympus" }, `database.slave.location "Mars/Olympus" is not a time zone`},
+		{"certificate without key", func(cfg *Config) { cfg.Database.Master.TLS.CertFile = "client.pem" }, "database.master.tls.cert_file and key_file go together"},
+		{"skip verify in production", func(cfg *Config) {
+			cfg.App.Env = EnvProduction
+			cfg.Database.Master.TLS.SkipVerify = true
+		}, "database.master.tls.skip_verify is not allowed in production"},
+		{"consistency without secret", func(cfg *Config) { cfg.Database.Consistency.Mode = ConsistencyMaster }, "database.consistency.token_secret is required"},
+		{"empty editor token", func(cfg *Config) { cfg.Auth.EditorTokens = []Secret{"token-a", ""} }, "auth.editor_tokens[1] is empty"},
+		{"cors origin without scheme", func(cfg *Config) { cfg.HTTP.CORS.AllowedOrigins = []string{"example.com"} }, "http.cors.allowed_origins[0]"},
+		{"unknown tenant resolver", func(cfg *Config) { cfg.Tenancy.Resolvers = []string{"cookie"} }, "tenancy.resolvers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.change(&cfg)
+
+			err := cfg.Validate()
+			if err == nil {
+				t.Fatalf("Validate succeeded, want %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Flatten returns the leaves of the config by key, e.g. database.master.port.
+// The map entries are leaves of their own, e.g. http.cache_control.movies_list.
+func Flatten(cfg *Config) map[string]interface{} {
+	leaves := make(map[string]interface{})
+	flatten(reflect.ValueOf(cfg).Elem(), "", leaves)
+
+	return leaves
+}
+
+func flatten(value reflect.Value, prefix string, leaves map[string]interface{}) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		f := value.Field(i)
+		switch f.Kind() {
+		case reflect.Struct:
+			flatten(f, key, leaves)
+		case reflect.Map:
+			for _, k := range f.MapKeys() {
+				leaves[fmt.Sprintf("%s.%v", key, k.Interface())] = f.MapIndex(k).Interface()
+			}
+		default:
+			leaves[key] = f.Interface()
+		}
+	}
+}
+
+// ChangedKeys returns the sorted keys whose value differs between the configs
+func ChangedKeys(a *Config, b *Config) []string {
+	leavesA, leavesB := Flatten(a), Flatten(b)
+
+	var keys []string
+	for key, valueA := range leavesA {
+		valueB, ok := leavesB[key]
+		if !ok || !equalValues(valueA, valueB) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range leavesB {
+		if _, ok := leavesA[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// equalValues compares two leaves, the empty and nil slices are equal
+func equalValues(a interface{}, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() == reflect.Slice && vb.Kind() == reflect.Slice && va.Len() == 0 && vb.Len() == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// matchKey reports whether key is prefix or one of its children
+func matchKey(key string, prefix string) bool {
+	return key == prefix || strings.HasPrefix(key, prefix+".") || strings.HasPrefix(key, prefix+"[")
+}
+
+// Sources of the config values
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+// Value is a config leaf with its origin
+type Value struct {
+	Key string
+	// Value redacts the secrets when printed
+	Value  interface{}
+	Source string
+	// Ref is the secret reference the value was resolved from
+	Ref string
+}
+
+// EnvVar returns the environment variable overriding the key, e.g. APP_DATABASE_MASTER_PASSWORD
+func EnvVar(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.NewReplacer(".", "_").Replace(key))
+}
+
+// Describe returns every leaf of the config sorted by key, with its source: the environment,
+// the file or the defaults
+func (c *Config) Describe() ([]Value, error) {
+	fileKeys := make(map[string]bool)
+	if c.file != "" {
+		v := viper.New()
+		v.SetConfigFile(c.file)
+
+		err := v.ReadInConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range v.AllKeys() {
+			fileKeys[key] = true
+		}
+	}
+
+	leaves := Flatten(c)
+	values := make([]Value, 0, len(leaves))
+	for key, value := range leaves {
+		source := SourceDefault
+		if _, ok := os.LookupEnv(EnvVar(key)); ok && !c.ignoreEnv {
+			source = SourceEnv
+		} else if fileKeys[key] {
+			source = SourceFile
+		}
+
+		values = append(values, Value{
+			Key:    key,
+			Value:  value,
+			Source: source,
+			Ref:    c.secretRef(key),
+		})
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Key < values[j].Key
+	})
+
+	return values, nil
+}
+
+// secretRef returns the references of the key, the slices list the references of their items
+func (c *Config) secretRef(key string) string {
+	if ref, ok := c.refs[key]; ok {
+		return ref
+	}
+
+	var refs []string
+	for k, ref := range c.refs {
+		if strings.HasPrefix(k, key+"[") {
+			refs = append(refs, k[len(key):]+"="+ref)
+		}
+	}
+	sort.Strings(refs)
+
+	return strings.Join(refs, ", ")
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	os.Setenv("CONFIG_TEST_DESCRIBE_PASS", "hunter2")
+	defer os.Unsetenv("CONFIG_TEST_DESCRIBE_PASS")
+	os.Setenv("APP_LOG_LEVEL", "warn")
+	defer os.Unsetenv("APP_LOG_LEVEL")
+
+	path := writeConfig(t, databaseConfig+
+		"    password: env://CONFIG_TEST_DESCRIBE_PASS\n"+
+		"app:\n  port: 9000\n"+
+		"auth:\n  editor_tokens:\n    - plain\n    - env://CONFIG_TEST_DESCRIBE_PASS\n")
+
+	tests := []struct {
+		name    string
+		options LoadOptions
+		want    map[string]Value
+	}{
+		{
+			name: "with the environment",
+			want: map[string]Value{
+				"app.port":                 {Value: 9000, Source: SourceFile},
+				"app.env":                  {Value: EnvDevelopment, Source: SourceDefault},
+				"log.level":                {Value: "warn", Source: SourceEnv},
+				"database.slave.password":  {Value: Secret("hunter2"), Source: SourceFile, Ref: "env://CONFIG_TEST_DESCRIBE_PASS"},
+				"database.master.password": {Value: Secret(""), Source: SourceDefault},
+				"auth.editor_tokens":       {Value: []Secret{"plain", "hunter2"}, Source: SourceFile, Ref: "[1]=env://CONFIG_TEST_DESCRIBE_PASS"},
+			},
+		},
+		{
+			name:    "environment ignored",
+			options: LoadOptions{IgnoreEnv: true},
+			want: map[string]Value{
+				"log.level": {Value: Default().Log.Level, Source: SourceDefault},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadFile(path, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			values, err := cfg.Describe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sort.SliceIsSorted(values, func(i, j int) bool { return values[i].Key < values[j].Key }) {
+				t.Error("the values are not sorted by key")
+			}
+			if leaves := Flatten(cfg); len(values) != len(leaves) {
+				t.Errorf("%d values, want one by leaf: %d", len(values), len(leaves))
+			}
+
+			byKey := make(map[string]Value, len(values))
+			for _, v := range values {
+				byKey[v.Key] = v
+			}
+			for key, want := range tt.want {
+				got, ok := byKey[key]
+				if !ok {
+					t.Errorf("%s is not described", key)
+					continue
+				}
+				if !reflect.DeepEqual(got.Value, want.Value) || got.Source != want.Source || got.Ref != want.Ref {
+					t.Errorf("%s = %v from %s (%s), want %v from %s (%s)", key, got.Value, got.Source, got.Ref, want.Value, want.Source, want.Ref)
+				}
+			}
+		})
+	}
+}
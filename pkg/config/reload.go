@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Subscriber applies a new config, an error rolls the whole change back
+type Subscriber func(cfg *Config) error
+
+type subscription struct {
+	fn   Subscriber
+	keys []string
+}
+
+// matches reports whether one of the changed keys is watched
+func (s subscription) matches(changed []string) bool {
+	if len(s.keys) == 0 {
+		return true
+	}
+
+	for _, key := range changed {
+		for _, prefix := range s.keys {
+			if matchKey(key, prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+var (
+	subscriptionsMu sync.Mutex
+	subscriptions   []subscription
+
+	// applyMu serializes the file reloads and the secret rotations
+	applyMu sync.Mutex
+)
+
+// Subscribe calls fn with the new config when one of the keys or of their children changes,
+// e.g. "log.level" or "database.master", on every change when no key is given.
+// The subscribers run in their subscription order.
+func Subscribe(fn Subscriber, keys ...string) {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+
+	subscriptions = append(subscriptions, subscription{fn: fn, keys: keys})
+}
+
+// apply notifies the subscribers of the changed keys and makes next the current config.
+// When a subscriber fails, it and the subscribers already notified are given the previous config back,
+// the failing one may have applied part of the change, and the previous config stays current.
+func apply(next *Config) error {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+
+	return applyLocked(next)
+}
+
+// applyLocked is apply with applyMu held
+func applyLocked(next *Config) error {
+	prev := Get()
+	keys := ChangedKeys(prev, next)
+	if len(keys) == 0 {
+		set(next)
+		return nil
+	}
+
+	subscriptionsMu.Lock()
+	subs := append([]subscription(nil), subscriptions...)
+	subscriptionsMu.Unlock()
+
+	var applied []subscription
+	for _, s := range subs {
+		if !s.matches(keys) {
+			continue
+		}
+
+		err := s.fn(next)
+		if err != nil {
+			applied = append(applied, s)
+			for i := len(applied) - 1; i >= 0; i-- {
+				if rerr := applied[i].fn(prev); rerr != nil {
+					log.Error("config rollback error: ", rerr)
+				}
+			}
+
+			return fmt.Errorf("apply config change of %v: %w", keys, err)
+		}
+		applied = append(applied, s)
+	}
+
+	set(next)
+	log.WithField("keys", keys).Info("config change applied")
+
+	return nil
+}
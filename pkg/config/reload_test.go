@@ -0,0 +1,226 @@
+package config
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-rest-api/pkg/cors"
+	"github.com/go-rest-api/pkg/ratelimit"
+)
+
+// withConfig makes cfg current without subscribers for the test
+func withConfig(t *testing.T, cfg Config) {
+	prev, prevSubscriptions := Get(), subscriptions
+	set(&cfg)
+	subscriptions = nil
+
+	t.Cleanup(func() {
+		set(prev)
+		subscriptions = prevSubscriptions
+	})
+}
+
+func TestApplyRollsBackTheFailingSubscriber(t *testing.T) {
+	withConfig(t, Default())
+
+	var levels []string
+	Subscribe(func(cfg *Config) error {
+		levels = append(levels, "first "+cfg.Log.Level)
+		return nil
+	}, "log")
+	Subscribe(func(cfg *Config) error {
+		levels = append(levels, "failing "+cfg.Log.Level)
+		if cfg.Log.Level == "info" {
+			return errors.New("rejected")
+		}
+		return nil
+	}, "log.level")
+	Subscribe(func(cfg *Config) error {
+		levels = append(levels, "unrelated "+cfg.Log.Level)
+		return nil
+	}, "http")
+
+	next := Default()
+	next.Log.Level = "info"
+	if err := apply(&next); err == nil {
+		t.Fatal("apply succeeded, want the subscriber error")
+	}
+
+	// The failing subscriber may have applied part of the change, it is rolled back first
+	want := []string{"first info", "failing info", "failing debug", "first debug"}
+	if len(levels) != len(want) {
+		t.Fatalf("calls = %v, want %v", levels, want)
+	}
+	for i := range want {
+		if levels[i] != want[i] {
+			t.Fatalf("calls = %v, want %v", levels, want)
+		}
+	}
+
+	if Get().Log.Level != "debug" {
+		t.Errorf("current log.level = %s, want the previous debug", Get().Log.Level)
+	}
+}
+
+func TestApplyNotifiesTheSubscribersOfTheChangedKeys(t *testing.T) {
+	withConfig(t, Default())
+
+	origins := cors.New(Get().HTTP.CORS.AllowedOrigins)
+	Subscribe(func(cfg *Config) error {
+		origins.SetOrigins(cfg.HTTP.CORS.AllowedOrigins)
+		return nil
+	}, "http.cors")
+
+	limiter := ratelimit.New(Get().HTTP.RateLimit.RequestsPerSecond, Get().HTTP.RateLimit.Burst)
+	Subscribe(func(cfg *Config) error {
+		limiter.SetLimit(cfg.HTTP.RateLimit.RequestsPerSecond, cfg.HTTP.RateLimit.Burst)
+		return nil
+	}, "http.rate_limit")
+
+	var calls int
+	Subscribe(func(cfg *Config) error {
+		calls++
+		return nil
+	}, "database.master")
+
+	next := Default()
+	next.HTTP.CORS.AllowedOrigins = []string{"https://app.example.com"}
+	next.HTTP.RateLimit.RequestsPerSecond = 1
+	next.HTTP.RateLimit.Burst = 1
+	if err := apply(&next); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 0 {
+		t.Errorf("the database.master subscriber was called %d times, want none", calls)
+	}
+	if Get() != &next {
+		t.Error("the applied config is not current")
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	origins.ServeHTTP(w, r, func(http.ResponseWriter, *http.Request) {})
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Error("the new CORS origin is not allowed")
+	}
+
+	if allowed, _ := limiter.Allow("client"); !allowed {
+		t.Error("the first request of the burst is rejected")
+	}
+	if allowed, _ := limiter.Allow("client"); allowed {
+		t.Error("the request over the new limit is allowed")
+	}
+}
+
+func TestRotateAppliesTheRotatedSecrets(t *testing.T) {
+	password := "old"
+	RegisterSecretProvider("rotate-test", SecretProviderFunc(func(ref string) (string, error) {
+		return password, nil
+	}))
+
+	cfg := Default()
+	cfg.Database.Master.Password = "old"
+	cfg.refs = map[string]string{"database.master.password": "rotate-test://db"}
+	withConfig(t, cfg)
+
+	var applied string
+	Subscribe(func(cfg *Config) error {
+		applied = cfg.Database.Master.Password.Value()
+		return nil
+	}, "database.master")
+
+	if err := rotate(); err != nil || applied != "" {
+		t.Fatalf("rotate of unchanged secrets = %v, applied %q, want nothing", err, applied)
+	}
+
+	password = "new"
+	if err := rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if applied != "new" || Get().Database.Master.Password.Value() != "new" {
+		t.Errorf("applied %q, current %q, want the new password", applied, Get().Database.Master.Password.Value())
+	}
+}
+
+func TestChangedKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *Config)
+		want   []string
+	}{
+		{
+			name:   "unchanged",
+			change: func(cfg *Config) {},
+		},
+		{
+			name:   "leaf",
+			change: func(cfg *Config) { cfg.Log.Level = "info" },
+			want:   []string{"log.level"},
+		},
+		{
+			name: "several leaves sorted",
+			change: func(cfg *Config) {
+				cfg.HTTP.RateLimit.Burst = 1
+				cfg.Database.Master.Port = 3307
+			},
+			want: []string{"database.master.port", "http.rate_limit.burst"},
+		},
+		{
+			name:   "empty and nil slices are equal",
+			change: func(cfg *Config) { cfg.Auth.EditorTokens = nil },
+		},
+		{
+			name:   "slice item",
+			change: func(cfg *Config) { cfg.HTTP.CORS.AllowedOrigins = []string{"*"} },
+			want:   []string{"http.cors.allowed_origins"},
+		},
+		{
+			name:   "added map entry",
+			change: func(cfg *Config) { cfg.HTTP.CacheControl = map[string]string{"movies_get": "max-age=60"} },
+			want:   []string{"http.cache_control.movies_get"},
+		},
+		{
+			name:   "secret",
+			change: func(cfg *Config) { cfg.Database.Master.Password = "rotated" },
+			want:   []string{"database.master.password"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Default(), Default()
+			tt.change(&b)
+
+			got := ChangedKeys(&a, &b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChangedKeys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionMatches(t *testing.T) {
+	tests := []struct {
+		keys    []string
+		changed []string
+		want    bool
+	}{
+		{nil, []string{"log.level"}, true},
+		{[]string{"http.cors"}, []string{"http.cors.allowed_origins"}, true},
+		{[]string{"database.replicas"}, []string{"database.replicas[0].host"}, true},
+		{[]string{"database.master"}, []string{"database.master_timeout"}, false},
+		{[]string{"http.cors"}, []string{"http.rate_limit.burst"}, false},
+	}
+
+	for _, tt := range tests {
+		got := subscription{keys: tt.keys}.matches(tt.changed)
+		if got != tt.want {
+			t.Errorf("keys %v matches %v = %t, want %t", tt.keys, tt.changed, got, tt.want)
+		}
+	}
+}
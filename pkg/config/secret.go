@@ -0,0 +1,243 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// redacted replaces the secret values when printed
+const redacted = "******"
+
+// ErrSecretNotFound returned when a secret reference cannot be resolved
+var ErrSecretNotFound = errors.New("secret not found")
+
+// Secret is a config value which is redacted when printed, logged or marshalled, use Value to read it
+type Secret string
+
+// Value returns the secret in clear
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// String redacts the secret
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+// GoString redacts the secret from the %#v verb
+func (s Secret) GoString() string {
+	return fmt.Sprintf("%q", s.String())
+}
+
+// MarshalText redacts the secret from the encoders
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// SecretProvider resolves the secret references of a scheme, ref is the part after "<scheme>://"
+type SecretProvider interface {
+	Resolve(ref string) (string, error)
+}
+
+// SecretProviderFunc adapts a function to SecretProvider
+type SecretProviderFunc func(ref string) (string, error)
+
+// Resolve calls f
+func (f SecretProviderFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]SecretProvider{
+		// file:///run/secrets/db_password reads the file, without the trailing new line
+		"file": SecretProviderFunc(func(ref string) (string, error) {
+			data, err := ioutil.ReadFile(ref)
+			if os.IsNotExist(err) {
+				return "", fmt.Errorf("%w: %s", ErrSecretNotFound, ref)
+			}
+			if err != nil {
+				return "", err
+			}
+
+			return strings.TrimRight(string(data), "\r\n"), nil
+		}),
+		// env://DB_PASS reads the environment variable
+		"env": SecretProviderFunc(func(ref string) (string, error) {
+			value, ok := os.LookupEnv(ref)
+			if !ok {
+				return "", fmt.Errorf("%w: %s", ErrSecretNotFound, ref)
+			}
+
+			return value, nil
+		}),
+	}
+)
+
+// RegisterSecretProvider registers the provider of the references starting with "<scheme>://",
+// e.g. a vault client. The file and env schemes are built in.
+func RegisterSecretProvider(scheme string, provider SecretProvider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	providers[scheme] = provider
+}
+
+// resolveSecret returns the value of a secret reference, ok is false when value is not a reference
+func resolveSecret(value string) (resolved string, ok bool, err error) {
+	if !isSecretRef(value) {
+		return "", false, nil
+	}
+
+	i := strings.Index(value, "://")
+	providersMu.RLock()
+	provider := providers[value[:i]]
+	providersMu.RUnlock()
+
+	resolved, err = provider.Resolve(value[i+3:])
+	return resolved, true, err
+}
+
+// resolveSecrets remembers the secret references of the config for the rotation and replaces them
+// by their value when resolve is true
+func (c *Config) resolveSecrets(resolve bool) error {
+	c.refs = make(map[string]string)
+
+	var problems []string
+	walkSecrets(reflect.ValueOf(c).Elem(), "", func(key string, secret *Secret) {
+		if !resolve {
+			if isSecretRef(secret.Value()) {
+				c.refs[key] = secret.Value()
+			}
+			return
+		}
+
+		resolved, ok, err := resolveSecret(secret.Value())
+		if !ok {
+			return
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+			return
+		}
+
+		c.refs[key] = secret.Value()
+		*secret = Secret(resolved)
+	})
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// isSecretRef reports whether value starts with the scheme of a registered provider
+func isSecretRef(value string) bool {
+	i := strings.Index(value, "://")
+	if i <= 0 {
+		return false
+	}
+
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	_, ok := providers[value[:i]]
+	return ok
+}
+
+// rotateSecrets resolves the references again and returns a copy of the config when some values changed
+func (c *Config) rotateSecrets() (*Config, []string, error) {
+	if len(c.refs) == 0 {
+		return c, nil, nil
+	}
+
+	rotated := *c
+
+	var (
+		keys []string
+		errs []string
+	)
+	walkSecrets(reflect.ValueOf(&rotated).Elem(), "", func(key string, secret *Secret) {
+		ref, ok := c.refs[key]
+		if !ok {
+			return
+		}
+
+		resolved, _, err := resolveSecret(ref)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+			return
+		}
+
+		if Secret(resolved) != *secret {
+			*secret = Secret(resolved)
+			keys = append(keys, key)
+		}
+	})
+
+	if len(errs) > 0 {
+		return c, nil, errors.New(strings.Join(errs, "; "))
+	}
+
+	return &rotated, keys, nil
+}
+
+// walkSecrets calls fn with the key and the address of every Secret of the struct.
+// The slices are copied first, so walking a shallow copy leaves the original untouched.
+func walkSecrets(value reflect.Value, prefix string, fn func(key string, secret *Secret)) {
+	secretType := reflect.TypeOf(Secret(""))
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag := field.Tag.Get("mapstructure")
+		if tag == "" {
+			continue
+		}
+
+		key := tag
+		if prefix != "" {
+			key = prefix + "." + tag
+		}
+
+		f := value.Field(i)
+		switch {
+		case field.Type == secretType:
+			fn(key, f.Addr().Interface().(*Secret))
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem() == secretType:
+			secrets := append([]Secret(nil), f.Interface().([]Secret)...)
+			f.Set(reflect.ValueOf(secrets))
+			for j := range secrets {
+				fn(fmt.Sprintf("%s[%d]", key, j), &secrets[j])
+			}
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct:
+			items := reflect.MakeSlice(field.Type, f.Len(), f.Len())
+			reflect.Copy(items, f)
+			f.Set(items)
+			for j := 0; j < items.Len(); j++ {
+				walkSecrets(items.Index(j), fmt.Sprintf("%s[%d]", key, j), fn)
+			}
+		case field.Type.Kind() == reflect.Struct:
+			walkSecrets(f, key, fn)
+		}
+	}
+}
+
+// Values returns the secrets in clear
+func Values(secrets []Secret) []string {
+	values := make([]string, 0, len(secrets))
+	for _, s := range secrets {
+		values = append(values, s.Value())
+	}
+
+	return values
+}
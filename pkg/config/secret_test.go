@@ -0,0 +1,138 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestSecretIsRedacted(t *testing.T) {
+	server := DatabaseServer{User: "movies", Password: "hunter2"}
+
+	jsonServer, err := json.Marshal(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var textLog, jsonLog bytes.Buffer
+	for buf, formatter := range map[*bytes.Buffer]log.Formatter{&textLog: &log.TextFormatter{}, &jsonLog: &log.JSONFormatter{}} {
+		logger := log.New()
+		logger.SetOutput(buf)
+		logger.SetFormatter(formatter)
+		logger.WithField("password", server.Password).WithField("server", server).Info("connecting")
+	}
+
+	tests := []struct {
+		name string
+		got  string
+	}{
+		{"String", server.Password.String()},
+		{"%v", fmt.Sprintf("%v", server.Password)},
+		{"%s", fmt.Sprintf("%s", server.Password)},
+		{"%#v", fmt.Sprintf("%#v", server.Password)},
+		{"%+v of the struct", fmt.Sprintf("%+v", server)},
+		{"%#v of the struct", fmt.Sprintf("%#v", server)},
+		{"JSON", string(jsonServer)},
+		{"text log", textLog.String()},
+		{"JSON log", jsonLog.String()},
+	}
+	for _, tt := range tests {
+		if strings.Contains(tt.got, "hunter2") {
+			t.Errorf("%s leaks the secret: %s", tt.name, tt.got)
+		}
+		if !strings.Contains(tt.got, redacted) {
+			t.Errorf("%s = %s, want it redacted", tt.name, tt.got)
+		}
+	}
+
+	if server.Password.Value() != "hunter2" {
+		t.Errorf("Value = %q, want the secret in clear", server.Password.Value())
+	}
+	if empty := Secret(""); empty.String() != "" {
+		t.Errorf("String of an empty secret = %q, want it empty", empty.String())
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "db_password")
+	if err := ioutil.WriteFile(passwordFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("CONFIG_TEST_DB_PASS", "from-env")
+	defer os.Unsetenv("CONFIG_TEST_DB_PASS")
+
+	tests := []struct {
+		name     string
+		password string
+		want     string
+		err      error
+	}{
+		{"plain value", "plain", "plain", nil},
+		{"file without the trailing new line", "file://" + passwordFile, "from-file", nil},
+		{"environment variable", "env://CONFIG_TEST_DB_PASS", "from-env", nil},
+		{"unknown scheme kept as is", "vault://db/password", "vault://db/password", nil},
+		{"missing file", "file://" + filepath.Join(dir, "missing"), "", ErrSecretNotFound},
+		{"missing environment variable", "env://CONFIG_TEST_MISSING", "", ErrSecretNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Database.Master.Password = Secret(tt.password)
+
+			err := cfg.resolveSecrets(true)
+			if tt.err != nil {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || len(validationErr.Problems) != 1 ||
+					!strings.Contains(validationErr.Problems[0], "database.master.password: "+tt.err.Error()) {
+					t.Errorf("err = %v, want %v of database.master.password", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := cfg.Database.Master.Password.Value(); got != tt.want {
+				t.Errorf("password = %q, want %q", got, tt.want)
+			}
+			// The references are kept for the rotation
+			if ref, ok := cfg.refs["database.master.password"]; ok != (tt.want != tt.password) || (ok && ref != tt.password) {
+				t.Errorf("refs = %v, want the reference %q only when resolved", cfg.refs, tt.password)
+			}
+		})
+	}
+}
+
+func TestLoadFileResolvesTheSecretReferences(t *testing.T) {
+	os.Setenv("CONFIG_TEST_EDITOR_TOKEN", "editor")
+	defer os.Unsetenv("CONFIG_TEST_EDITOR_TOKEN")
+
+	path := writeConfig(t, databaseConfig+"auth:\n  editor_tokens:\n    - env://CONFIG_TEST_EDITOR_TOKEN\n    - plain\n")
+
+	cfg, err := LoadFile(path, LoadOptions{IgnoreEnv: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens := Values(cfg.Auth.EditorTokens); len(tokens) != 2 || tokens[0] != "editor" || tokens[1] != "plain" {
+		t.Errorf("editor tokens = %v, want the resolved reference and the plain value", tokens)
+	}
+
+	// SkipSecrets leaves the reference, e.g. to check a deployment config without its secrets
+	cfg, err = LoadFile(path, LoadOptions{IgnoreEnv: true, SkipSecrets: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token := cfg.Auth.EditorTokens[0].Value(); token != "env://CONFIG_TEST_EDITOR_TOKEN" {
+		t.Errorf("editor token = %q, want the reference", token)
+	}
+}
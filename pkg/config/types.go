@@ -0,0 +1,399 @@
+package config
+
+import "github.com/go-rest-api/pkg/tenant"
+
+// Environments of the app.env key
+const (
+	EnvDevelopment = "development"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
+
+// Config is the typed content of App.yaml, see configurations/App.yaml.dist for the documentation of every key
+type Config struct {
+	App      App      `mapstructure:"app"`
+	Log      Log      `mapstructure:"log"`
+	Database Database `mapstructure:"database"`
+	// Breakers of the database operations and health checks
+	Breakers CircuitBreaker `mapstructure:"circuit_breaker"`
+	ReadOnly ReadOnly       `mapstructure:"read_only"`
+	HTTP     HTTP           `mapstructure:"http"`
+	Auth     Auth           `mapstructure:"auth"`
+	SSE      SSE            `mapstructure:"sse"`
+	Movie    Movie          `mapstructure:"movie"`
+	Tenancy  Tenancy        `mapstructure:"tenancy"`
+	Secrets  Secrets        `mapstructure:"secrets"`
+	Features Features       `mapstructure:"features"`
+
+	// refs are the secret references of the file, by key
+	refs map[string]string
+	// file is the path of the loaded file
+	file string
+	// ignoreEnv is true when the environment overrides were skipped
+	ignoreEnv bool
+}
+
+// App settings
+type App struct {
+	Env  string `mapstructure:"env"`
+	Port int    `mapstructure:"port"`
+	// GracefulTimeout in seconds, 0 is 30
+	GracefulTimeout int  `mapstructure:"graceful_timeout"`
+	AutoMigrate     bool `mapstructure:"auto_migrate"`
+}
+
+// Log settings
+type Log struct {
+	Level string `mapstructure:"level"`
+}
+
+// Database drivers
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite"
+)
+
+// Database holds the master and slave servers
+type Database struct {
+	// Driver is mysql or sqlite, the servers are ignored with sqlite
+	Driver string         `mapstructure:"driver"`
+	SQLite SQLite         `mapstructure:"sqlite"`
+	Master DatabaseServer `mapstructure:"master"`
+	Slave  DatabaseServer `mapstructure:"slave"`
+	// Replicas replace the slave when set, the unset keys of an item take the defaults
+	Replicas    []DatabaseServer `mapstructure:"replicas"`
+	Replication Replication      `mapstructure:"replication"`
+	Consistency Consistency      `mapstructure:"consistency"`
+	Timeouts    QueryTimeouts    `mapstructure:"query_timeouts"`
+	Fallback    Fallback         `mapstructure:"fallback"`
+}
+
+// SQLite database of the sqlite driver, a single local file serving the reads and the writes
+type SQLite struct {
+	Path string `mapstructure:"path"`
+	// BusyTimeoutMs waited for the write lock held by another connection
+	BusyTimeoutMs int `mapstructure:"busy_timeout_ms"`
+}
+
+// Fallback of the reads to the master when the replica fails or its circuit breaker is open
+type Fallback struct {
+	Enabled bool `mapstructure:"enabled"`
+	// RequestsPerSecond of the fallback reads protecting the master, 0 is unlimited
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+	Burst             int     `mapstructure:"burst"`
+}
+
+// QueryTimeouts of the repository operations in milliseconds, 0 is no timeout
+type QueryTimeouts struct {
+	ReadMs        int `mapstructure:"read_ms"`
+	WriteMs       int `mapstructure:"write_ms"`
+	TransactionMs int `mapstructure:"transaction_ms"`
+}
+
+// ReadReplicas returns the servers of the reads, the replicas or else the slave
+func (d Database) ReadReplicas() []DatabaseServer {
+	if len(d.Replicas) > 0 {
+		return d.Replicas
+	}
+
+	return []DatabaseServer{d.Slave}
+}
+
+// Load balancing policies of the replicas
+const (
+	BalancerRoundRobin       = "round_robin"
+	BalancerLeastConnections = "least_connections"
+)
+
+// Replication lag sources
+const (
+	LagSourceSlaveStatus = "slave_status"
+	LagSourceHeartbeat   = "heartbeat"
+)
+
+// Consistency modes of the reads following a write
+const (
+	ConsistencyEventual = "eventual"
+	ConsistencyMaster   = "master"
+	ConsistencyWait     = "wait"
+)
+
+// Consistency of the reads following a write of the same client
+type Consistency struct {
+	// Mode is eventual, master or wait
+	Mode string `mapstructure:"mode"`
+	// Window in seconds after a write during which the reads of the client see it
+	Window int `mapstructure:"window"`
+	// WaitTimeoutMs for a replica to apply the write in wait mode
+	WaitTimeoutMs int `mapstructure:"wait_timeout_ms"`
+	// Header and Cookie carry the session token of the client, no cookie when empty
+	Header string `mapstructure:"header"`
+	Cookie string `mapstructure:"cookie"`
+	// TokenSecret signs the session tokens, required unless the mode is eventual
+	TokenSecret Secret `mapstructure:"token_secret"`
+}
+
+// Replication settings of the read replicas
+type Replication struct {
+	// Balancer is round_robin or least_connections
+	Balancer string `mapstructure:"balancer"`
+	// CheckInterval in seconds between two health checks of the replicas
+	CheckInterval int `mapstructure:"check_interval"`
+	// MaxLag in seconds after which a replica is ejected, 0 disables the lag checks
+	MaxLag int `mapstructure:"max_lag"`
+	// LagSource is slave_status or heartbeat
+	LagSource string `mapstructure:"lag_source"`
+	// HeartbeatTable is the table of the heartbeat lag source, its ts column is written on the master
+	HeartbeatTable string `mapstructure:"heartbeat_table"`
+}
+
+// DatabaseServer is the connection of a MySQL server
+type DatabaseServer struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	User     string `mapstructure:"user"`
+	Password Secret `mapstructure:"password"`
+	Name     string `mapstructure:"name"`
+	// Collation of the connections
+	Collation string `mapstructure:"collation"`
+	// Location of the DATETIME values, e.g. UTC or Local
+	Location string `mapstructure:"location"`
+	// DialTimeout, ReadTimeout and WriteTimeout in seconds, 0 waits forever. The read and write
+	// timeouts default to 30 and cut the longer statements.
+	DialTimeout  int `mapstructure:"dial_timeout"`
+	ReadTimeout  int `mapstructure:"read_timeout"`
+	WriteTimeout int `mapstructure:"write_timeout"`
+	TLS          TLS `mapstructure:"tls"`
+	// MaxOpenConns of the pool, 0 is unlimited
+	MaxOpenConns int `mapstructure:"max_open_conns"`
+	MaxIdleConns int `mapstructure:"max_idle_conns"`
+	// ConnMaxLifetime and ConnMaxIdleTime in seconds, 0 keeps the connections forever
+	ConnMaxLifetime int `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime int `mapstructure:"conn_max_idle_time"`
+}
+
+// TLS settings of a database server
+type TLS struct {
+	Enabled bool `mapstructure:"enabled"`
+	// CAFile verifies the server, the system roots when empty
+	CAFile string `mapstructure:"ca_file"`
+	// CertFile and KeyFile are the client certificate, both or none
+	CertFile   string `mapstructure:"cert_file"`
+	KeyFile    string `mapstructure:"key_file"`
+	ServerName string `mapstructure:"server_name"`
+	// SkipVerify accepts any server certificate, refused in production
+	SkipVerify bool `mapstructure:"skip_verify"`
+}
+
+// CircuitBreaker settings, the breakers are named mysql-master, mysql-slave, mysql-<replica>, mysql-fallback
+// and health-master
+type CircuitBreaker struct {
+	Enabled bool    `mapstructure:"enabled"`
+	Default Breaker `mapstructure:"default"`
+	// Commands override the default by breaker name, the unset keys take the default
+	Commands map[string]Breaker `mapstructure:"commands"`
+}
+
+// ReadOnly settings of the degraded mode rejecting the writes
+type ReadOnly struct {
+	// Enabled switches the mode on, as the admin endpoint does
+	Enabled bool `mapstructure:"enabled"`
+	// RetryAfter in seconds suggested to the rejected writes
+	RetryAfter int `mapstructure:"retry_after"`
+	// Auto switches the mode while the master fails its health checks
+	Auto bool `mapstructure:"auto"`
+	// CheckInterval in seconds between two checks of the master
+	CheckInterval int `mapstructure:"check_interval"`
+	// FailureThreshold is the number of consecutive failed checks switching the mode on
+	FailureThreshold int `mapstructure:"failure_threshold"`
+}
+
+// Breaker settings of a circuit breaker
+type Breaker struct {
+	// TimeoutMs of a call, above the query timeouts it only bounds the hung calls
+	TimeoutMs int `mapstructure:"timeout_ms"`
+	// MaxConcurrent calls, the extra ones are rejected
+	MaxConcurrent int `mapstructure:"max_concurrent"`
+	// RequestVolume is the minimum of calls in 10 seconds before the breaker can open
+	RequestVolume int `mapstructure:"request_volume"`
+	// ErrorPercent of the failing calls which opens the breaker
+	ErrorPercent int `mapstructure:"error_percent"`
+	// SleepWindowMs the breaker stays open before a call tests the server
+	SleepWindowMs int `mapstructure:"sleep_window_ms"`
+}
+
+// HTTP settings
+type HTTP struct {
+	// CacheControl is the Cache-Control header per route name
+	CacheControl map[string]string `mapstructure:"cache_control"`
+	CORS         CORS              `mapstructure:"cors"`
+	RateLimit    RateLimit         `mapstructure:"rate_limit"`
+}
+
+// CORS settings
+type CORS struct {
+	// AllowedOrigins of the cross-origin requests, "*" allows any origin
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+}
+
+// RateLimit settings of the requests per client address
+type RateLimit struct {
+	// RequestsPerSecond allowed per client, 0 disables the limit
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+	Burst             int     `mapstructure:"burst"`
+}
+
+// Auth settings
+type Auth struct {
+	// EditorTokens are allowed on the imports, the merges and the collection writes
+	EditorTokens []Secret `mapstructure:"editor_tokens"`
+	// AdminTokens are allowed on the admin endpoints, e.g. the breaker dashboard
+	AdminTokens []Secret `mapstructure:"admin_tokens"`
+}
+
+// SSE settings of the event streams
+type SSE struct {
+	LogSize      int `mapstructure:"log_size"`
+	ClientBuffer int `mapstructure:"client_buffer"`
+	// HeartbeatInterval in seconds
+	HeartbeatInterval int `mapstructure:"heartbeat_interval"`
+}
+
+// Movie settings
+type Movie struct {
+	// StatsCacheTTL in seconds
+	StatsCacheTTL int `mapstructure:"stats_cache_ttl"`
+}
+
+// Tenancy settings
+type Tenancy struct {
+	Default     string   `mapstructure:"default"`
+	Resolvers   []string `mapstructure:"resolvers"`
+	Header      string   `mapstructure:"header"`
+	BaseDomain  string   `mapstructure:"base_domain"`
+	TokenSecret Secret   `mapstructure:"token_secret"`
+	TokenClaim  string   `mapstructure:"token_claim"`
+	Tenants     []string `mapstructure:"tenants"`
+}
+
+// Secrets settings of the secret references, e.g. file:///run/secrets/db_password or env://DB_PASS
+type Secrets struct {
+	// RefreshInterval in seconds between the rotation checks, 0 disables them
+	RefreshInterval int `mapstructure:"refresh_interval"`
+}
+
+// Features are the feature flags by name, read them from Get so they follow the config changes
+type Features map[string]bool
+
+// Enabled reports whether the feature is on, the unknown features are off
+func (f Features) Enabled(name string) bool {
+	return f[name]
+}
+
+// Default returns the config used for the keys missing from the file and the environment
+func Default() Config {
+	return Config{
+		App: App{
+			Env:             EnvDevelopment,
+			Port:            8080,
+			GracefulTimeout: 30,
+		},
+		Log: Log{
+			Level: "debug",
+		},
+		Database: Database{
+			Driver: DriverMySQL,
+			SQLite: SQLite{
+				Path:          "go-rest-api.db",
+				BusyTimeoutMs: 5000,
+			},
+			Master: defaultDatabaseServer(),
+			Slave:  defaultDatabaseServer(),
+			Replication: Replication{
+				Balancer:       BalancerRoundRobin,
+				CheckInterval:  5,
+				LagSource:      LagSourceSlaveStatus,
+				HeartbeatTable: "heartbeat",
+			},
+			Consistency: Consistency{
+				Mode:          ConsistencyEventual,
+				Window:        5,
+				WaitTimeoutMs: 100,
+				Header:        "X-Consistency-Token",
+			},
+			Timeouts: QueryTimeouts{
+				ReadMs:        5000,
+				WriteMs:       10000,
+				TransactionMs: 30000,
+			},
+			Fallback: Fallback{
+				Enabled:           true,
+				RequestsPerSecond: 50,
+				Burst:             100,
+			},
+		},
+		Breakers: CircuitBreaker{
+			Enabled: true,
+			Default: Breaker{
+				TimeoutMs:     30000,
+				MaxConcurrent: 100,
+				RequestVolume: 20,
+				ErrorPercent:  50,
+				SleepWindowMs: 5000,
+			},
+			Commands: map[string]Breaker{},
+		},
+		ReadOnly: ReadOnly{
+			RetryAfter:       30,
+			Auto:             true,
+			CheckInterval:    5,
+			FailureThreshold: 3,
+		},
+		HTTP: HTTP{
+			CacheControl: map[string]string{},
+			CORS: CORS{
+				AllowedOrigins: []string{},
+			},
+			RateLimit: RateLimit{
+				Burst: 20,
+			},
+		},
+		Auth: Auth{
+			EditorTokens: []Secret{},
+			AdminTokens:  []Secret{},
+		},
+		SSE: SSE{
+			LogSize:           1000,
+			ClientBuffer:      64,
+			HeartbeatInterval: 15,
+		},
+		Movie: Movie{
+			StatsCacheTTL: 60,
+		},
+		Tenancy: Tenancy{
+			Default:    tenant.DefaultTenant,
+			Resolvers:  []string{tenant.ResolverHeader, tenant.ResolverSubdomain, tenant.ResolverToken},
+			Header:     "X-Tenant-ID",
+			TokenClaim: "tenant",
+			Tenants:    []string{},
+		},
+		Secrets: Secrets{
+			RefreshInterval: 60,
+		},
+		Features: Features{},
+	}
+}
+
+func defaultDatabaseServer() DatabaseServer {
+	return DatabaseServer{
+		Port:            3306,
+		Collation:       "utf8mb4_general_ci",
+		Location:        "UTC",
+		DialTimeout:     5,
+		ReadTimeout:     30,
+		WriteTimeout:    30,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: 300,
+		ConnMaxIdleTime: 60,
+	}
+}
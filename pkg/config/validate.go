@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-rest-api/pkg/tenant"
+	log "github.com/sirupsen/logrus"
+)
+
+// identifier matches the table names interpolated in the queries
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// ValidationError lists every invalid key of the config
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the required keys and the ranges, every problem is reported at once
+func (c *Config) Validate() error {
+	e := &ValidationError{}
+
+	e.oneOf("app.env", c.App.Env, EnvDevelopment, EnvStaging, EnvProduction)
+	e.between("app.port", c.App.Port, 1, 65535)
+	e.between("app.graceful_timeout", c.App.GracefulTimeout, 0, 3600)
+
+	if _, err := log.ParseLevel(c.Log.Level); err != nil {
+		e.add("log.level %q is not a log level", c.Log.Level)
+	}
+
+	type namedServer struct {
+		prefix string
+		server DatabaseServer
+	}
+	var servers []namedServer
+	e.oneOf("database.driver", c.Database.Driver, DriverMySQL, DriverSQLite)
+	if c.Database.Driver == DriverSQLite {
+		e.required("database.sqlite.path", c.Database.SQLite.Path)
+		e.between("database.sqlite.busy_timeout_ms", c.Database.SQLite.BusyTimeoutMs, 0, 600000)
+	} else {
+		servers = append(servers, namedServer{"database.master", c.Database.Master})
+		if len(c.Database.Replicas) == 0 {
+			servers = append(servers, namedServer{"database.slave", c.Database.Slave})
+		}
+		for i, replica := range c.Database.Replicas {
+			servers = append(servers, namedServer{fmt.Sprintf("database.replicas[%d]", i), replica})
+		}
+	}
+	for _, s := range servers {
+		prefix, server := s.prefix, s.server
+		e.required(prefix+".host", server.Host)
+		e.between(prefix+".port", server.Port, 1, 65535)
+		e.required(prefix+".user", server.User)
+		e.required(prefix+".name", server.Name)
+		e.between(prefix+".max_open_conns", server.MaxOpenConns, 0, 10000)
+		e.between(prefix+".max_idle_conns", server.MaxIdleConns, 0, 10000)
+		if server.MaxOpenConns > 0 && server.MaxIdleConns > server.MaxOpenConns {
+			e.add("%s.max_idle_conns must not exceed max_open_conns", prefix)
+		}
+		e.between(prefix+".conn_max_lifetime", server.ConnMaxLifetime, 0, 86400)
+		e.between(prefix+".conn_max_idle_time", server.ConnMaxIdleTime, 0, 86400)
+		e.between(prefix+".dial_timeout", server.DialTimeout, 0, 3600)
+		e.between(prefix+".read_timeout", server.ReadTimeout, 0, 3600)
+		e.between(prefix+".write_timeout", server.WriteTimeout, 0, 3600)
+		e.required(prefix+".collation", server.Collation)
+		if _, err := time.LoadLocation(server.Location); err != nil {
+			e.add("%s.location %q is not a time zone", prefix, server.Location)
+		}
+		if (server.TLS.CertFile == "") != (server.TLS.KeyFile == "") {
+			e.add("%s.tls.cert_file and key_file go together", prefix)
+		}
+		if server.TLS.SkipVerify && c.App.Env == EnvProduction {
+			e.add("%s.tls.skip_verify is not allowed in production", prefix)
+		}
+	}
+
+	consistency := c.Database.Consistency
+	e.oneOf("database.consistency.mode", consistency.Mode, ConsistencyEventual, ConsistencyMaster, ConsistencyWait)
+	e.between("database.consistency.window", consistency.Window, 0, 3600)
+	e.between("database.consistency.wait_timeout_ms", consistency.WaitTimeoutMs, 0, 60000)
+	e.required("database.consistency.header", consistency.Header)
+	if consistency.Mode != ConsistencyEventual {
+		e.required("database.consistency.token_secret", consistency.TokenSecret.Value())
+	}
+
+	timeouts := c.Database.Timeouts
+	e.between("database.query_timeouts.read_ms", timeouts.ReadMs, 0, 600000)
+	e.between("database.query_timeouts.write_ms", timeouts.WriteMs, 0, 600000)
+	e.between("database.query_timeouts.transaction_ms", timeouts.TransactionMs, 0, 600000)
+
+	fallback := c.Database.Fallback
+	if fallback.RequestsPerSecond < 0 {
+		e.add("database.fallback.requests_per_second must not be negative")
+	}
+	if fallback.RequestsPerSecond > 0 {
+		e.between("database.fallback.burst", fallback.Burst, 1, 100000)
+	}
+
+	replication := c.Database.Replication
+	e.oneOf("database.replication.balancer", replication.Balancer, BalancerRoundRobin, BalancerLeastConnections)
+	e.between("database.replication.check_interval", replication.CheckInterval, 1, 3600)
+	e.between("database.replication.max_lag", replication.MaxLag, 0, 86400)
+	if replication.MaxLag > 0 {
+		e.oneOf("database.replication.lag_source", replication.LagSource, LagSourceSlaveStatus, LagSourceHeartbeat)
+		if replication.LagSource == LagSourceHeartbeat && !identifier.MatchString(replication.HeartbeatTable) {
+			e.add("database.replication.heartbeat_table %q is not a table name", replication.HeartbeatTable)
+		}
+	}
+
+	e.breaker("circuit_breaker.default", c.Breakers.Default, 1)
+	for name, b := range c.Breakers.Commands {
+		// The unset keys of a command take the default
+		e.breaker("circuit_breaker.commands."+name, b, 0)
+	}
+
+	e.between("read_only.retry_after", c.ReadOnly.RetryAfter, 1, 86400)
+	e.between("read_only.check_interval", c.ReadOnly.CheckInterval, 1, 3600)
+	e.between("read_only.failure_threshold", c.ReadOnly.FailureThreshold, 1, 1000)
+
+	for i, token := range c.Auth.EditorTokens {
+		if strings.TrimSpace(token.Value()) == "" {
+			e.add("auth.editor_tokens[%d] is empty", i)
+		}
+	}
+	for i, token := range c.Auth.AdminTokens {
+		if strings.TrimSpace(token.Value()) == "" {
+			e.add("auth.admin_tokens[%d] is empty", i)
+		}
+	}
+
+	for i, origin := range c.HTTP.CORS.AllowedOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			e.add("http.cors.allowed_origins[%d] must be * or an http(s) origin, got %q", i, origin)
+		}
+	}
+	if c.HTTP.RateLimit.RequestsPerSecond < 0 {
+		e.add("http.rate_limit.requests_per_second must not be negative")
+	}
+	if c.HTTP.RateLimit.RequestsPerSecond > 0 {
+		e.between("http.rate_limit.burst", c.HTTP.RateLimit.Burst, 1, 100000)
+	}
+
+	e.between("sse.log_size", c.SSE.LogSize, 1, 1000000)
+	e.between("sse.client_buffer", c.SSE.ClientBuffer, 1, 100000)
+	e.between("sse.heartbeat_interval", c.SSE.HeartbeatInterval, 1, 3600)
+	e.between("movie.stats_cache_ttl", c.Movie.StatsCacheTTL, 1, 86400)
+
+	e.between("secrets.refresh_interval", c.Secrets.RefreshInterval, 0, 86400)
+
+	for _, resolver := range c.Tenancy.Resolvers {
+		e.oneOf("tenancy.resolvers", resolver, tenant.ResolverHeader, tenant.ResolverSubdomain, tenant.ResolverToken)
+	}
+
+	if len(e.Problems) > 0 {
+		return e
+	}
+
+	return nil
+}
+
+// breaker validates the settings of a circuit breaker, min is 0 when the keys can be unset
+func (e *ValidationError) breaker(key string, b Breaker, min int) {
+	e.between(key+".timeout_ms", b.TimeoutMs, min, 600000)
+	e.between(key+".max_concurrent", b.MaxConcurrent, min, 100000)
+	e.between(key+".request_volume", b.RequestVolume, min, 100000)
+	e.between(key+".error_percent", b.ErrorPercent, min, 100)
+	e.between(key+".sleep_window_ms", b.SleepWindowMs, min, 600000)
+}
+
+func (e *ValidationError) add(format string, args ...interface{}) {
+	e.Problems = append(e.Problems, fmt.Sprintf(format, args...))
+}
+
+func (e *ValidationError) required(key string, value string) {
+	if strings.TrimSpace(value) == "" {
+		e.add("%s is required", key)
+	}
+}
+
+func (e *ValidationError) between(key string, value int, min int, max int) {
+	if value < min || value > max {
+		e.add("%s must be between %d and %d, got %d", key, min, max, value)
+	}
+}
+
+func (e *ValidationError) oneOf(key string, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+
+	e.add("%s must be one of %s, got %q", key, strings.Join(allowed, ", "), value)
+}
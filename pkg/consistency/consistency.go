@@ -0,0 +1,128 @@
+package consistency
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidToken is returned when a session token can not be decoded or its signature does not match
+var ErrInvalidToken = errors.New("invalid consistency token")
+
+// Session remembers the last write of a client, so its next reads can see it
+type Session struct {
+	mu        sync.Mutex
+	lastWrite time.Time
+	gtid      string
+	written   bool
+}
+
+type contextKey struct{}
+
+// WithSession carries the session in the context
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, contextKey{}, session)
+}
+
+// FromContext returns the session carried by the context
+func FromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(contextKey{}).(*Session)
+	return session, ok && session != nil
+}
+
+// MarkWrite records a write committed at the time, gtid is the GTID set of the master after the write
+// or empty when unknown
+func (s *Session) MarkWrite(at time.Time, gtid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if at.After(s.lastWrite) {
+		s.lastWrite = at
+		s.gtid = gtid
+	}
+	s.written = true
+}
+
+// LastWrite returns the time and the GTID set of the last write, the time is zero without write
+func (s *Session) LastWrite() (time.Time, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastWrite, s.gtid
+}
+
+// Written reports whether a write was marked since the session was created or decoded
+func (s *Session) Written() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.written
+}
+
+// Token encodes the last write for the client, to be sent back with its next requests. The token is
+// signed with the secret so the client can not forge it.
+func (s *Session) Token(secret []byte) string {
+	at, gtid := s.LastWrite()
+	if at.IsZero() || len(secret) == 0 {
+		return ""
+	}
+
+	raw := strconv.FormatInt(at.UnixNano()/int64(time.Millisecond), 10)
+	if gtid != "" {
+		raw += "|" + gtid
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw)) + "." + base64.RawURLEncoding.EncodeToString(sign(secret, raw))
+}
+
+// ParseToken decodes the session of a token signed with the secret. The token comes from the client,
+// a time in the future is brought back to now.
+func ParseToken(token string, secret []byte) (*Session, error) {
+	if len(secret) == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, sign(secret, string(raw))) {
+		return nil, ErrInvalidToken
+	}
+
+	fields := strings.SplitN(string(raw), "|", 2)
+	ms, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || ms <= 0 {
+		return nil, ErrInvalidToken
+	}
+
+	at := time.Unix(0, ms*int64(time.Millisecond))
+	if now := time.Now(); at.After(now) {
+		at = now
+	}
+
+	session := &Session{lastWrite: at}
+	if len(fields) == 2 {
+		session.gtid = fields[1]
+	}
+
+	return session, nil
+}
+
+// sign returns the HMAC-SHA256 of the token payload
+func sign(secret []byte, raw string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(raw))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,163 @@
+package consistency
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Options of the session tokens
+type Options struct {
+	// Header carrying the token in both directions, X-Consistency-Token when empty
+	Header string
+	// Cookie carrying the token, no cookie when empty
+	Cookie string
+	// Window after a write during which the token is kept by the client
+	Window time.Duration
+	// Secret signing the tokens, no token is sent or accepted when empty
+	Secret string
+}
+
+// Middleware carries a session in the request context. The session is restored from the token of the
+// client, and the token of a new write is sent back in the response header and cookie.
+// The window and the secret can change while serving.
+type Middleware struct {
+	mu      sync.RWMutex
+	options Options
+}
+
+// NewMiddleware creates new Middleware
+func NewMiddleware(options Options) *Middleware {
+	if options.Header == "" {
+		options.Header = "X-Consistency-Token"
+	}
+
+	return &Middleware{options: options}
+}
+
+// SetWindow replaces the window after a write during which the token is kept by the client
+func (m *Middleware) SetWindow(window time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.options.Window = window
+}
+
+// SetSecret replaces the secret signing the tokens, the tokens signed with the previous one are rejected
+func (m *Middleware) SetSecret(secret string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.options.Secret = secret
+}
+
+// Handler wraps next with the session of the client
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.RLock()
+		options := m.options
+		m.mu.RUnlock()
+
+		session := restore(r, options)
+
+		next.ServeHTTP(&responseWriter{ResponseWriter: w, session: session, options: options}, r.WithContext(WithSession(r.Context(), session)))
+	})
+}
+
+// Vary returns the request headers carrying the token, a shared cache must key the responses on them
+func (m *Middleware) Vary() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	headers := []string{m.options.Header}
+	if m.options.Cookie != "" {
+		headers = append(headers, "Cookie")
+	}
+
+	return headers
+}
+
+// FollowsWrite reports whether the request carries the token of a write of the client, its response
+// must not be shared with the clients which did not see the write
+func FollowsWrite(r *http.Request) bool {
+	session, ok := FromContext(r.Context())
+	if !ok {
+		return false
+	}
+
+	lastWrite, _ := session.LastWrite()
+	return !lastWrite.IsZero()
+}
+
+// restore restores the session of the token of the request, an invalid token starts a new session
+func restore(r *http.Request, options Options) *Session {
+	token := r.Header.Get(options.Header)
+	if token == "" && options.Cookie != "" {
+		if cookie, err := r.Cookie(options.Cookie); err == nil {
+			token = cookie.Value
+		}
+	}
+
+	if token != "" {
+		if session, err := ParseToken(token, []byte(options.Secret)); err == nil {
+			return session
+		}
+	}
+
+	return &Session{}
+}
+
+// responseWriter sends the token of the session with the headers, when the request wrote
+type responseWriter struct {
+	http.ResponseWriter
+	session     *Session
+	options     Options
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.writeToken()
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush keeps the event streams working behind the middleware
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) writeToken() {
+	if !w.session.Written() {
+		return
+	}
+
+	token := w.session.Token([]byte(w.options.Secret))
+	if token == "" {
+		return
+	}
+
+	w.Header().Set(w.options.Header, token)
+	if w.options.Cookie != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     w.options.Cookie,
+			Value:    token,
+			Path:     "/",
+			MaxAge:   int(w.options.Window.Seconds()) + 1,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
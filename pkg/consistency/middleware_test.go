@@ -0,0 +1,173 @@
+package consistency
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareRoundTrip(t *testing.T) {
+	m := NewMiddleware(Options{Cookie: "consistency", Window: 5 * time.Second, Secret: "secret"})
+
+	var got *Session
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = FromContext(r.Context())
+		if r.Method == http.MethodPost {
+			got.MarkWrite(time.Now(), "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	// A read without token sends no token back
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if token := w.Header().Get("X-Consistency-Token"); token != "" {
+		t.Errorf("token = %q after a read, want none", token)
+	}
+
+	// A write sends the token in the header and the cookie
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	token := w.Header().Get("X-Consistency-Token")
+	if token == "" {
+		t.Fatal("no token after a write")
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != token {
+		t.Errorf("cookies = %v, want the token", cookies)
+	}
+
+	// The next request restores the write from the cookie
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookies[0])
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	lastWrite, gtid := got.LastWrite()
+	if time.Since(lastWrite) > time.Second || gtid != "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5" {
+		t.Errorf("restored write = %s %q", lastWrite, gtid)
+	}
+}
+
+func TestParseTokenClampsFutureWrites(t *testing.T) {
+	future := &Session{}
+	future.MarkWrite(time.Now().Add(time.Hour), "")
+
+	session, err := ParseToken(future.Token([]byte("secret")), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastWrite, _ := session.LastWrite(); lastWrite.After(time.Now()) {
+		t.Errorf("last write %s is in the future", lastWrite)
+	}
+
+	if _, err := ParseToken("not a token", []byte("secret")); err != ErrInvalidToken {
+		t.Errorf("err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestParseTokenRejectsUnsignedTokens(t *testing.T) {
+	session := &Session{}
+	session.MarkWrite(time.Now().Add(-time.Second), "")
+	token := session.Token([]byte("secret"))
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)))
+	tests := []struct {
+		name   string
+		token  string
+		secret string
+	}{
+		{"unsigned", payload, "secret"},
+		{"forged signature", payload + "." + base64.RawURLEncoding.EncodeToString([]byte("forged")), "secret"},
+		{"other payload", payload + token[strings.Index(token, "."):], "secret"},
+		{"other secret", token, "rotated"},
+		{"no secret", token, ""},
+	}
+	for _, tt := range tests {
+		if _, err := ParseToken(tt.token, []byte(tt.secret)); err != ErrInvalidToken {
+			t.Errorf("%s: err = %v, want ErrInvalidToken", tt.name, err)
+		}
+	}
+
+	if token := session.Token(nil); token != "" {
+		t.Errorf("token = %q without secret, want none", token)
+	}
+}
+
+func TestMiddlewareFollowsTheWindowAndTheSecret(t *testing.T) {
+	m := NewMiddleware(Options{Cookie: "consistency", Window: 5 * time.Second, Secret: "secret"})
+
+	var got *Session
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = FromContext(r.Context())
+		if r.Method == http.MethodPost {
+			got.MarkWrite(time.Now(), "")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	m.SetWindow(time.Minute)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].MaxAge != 61 {
+		t.Fatalf("cookies = %v, want a max age of 61", cookies)
+	}
+
+	// The tokens signed with the previous secret are ignored after a rotation
+	m.SetSecret("rotated")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookies[0])
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if lastWrite, _ := got.LastWrite(); !lastWrite.IsZero() {
+		t.Errorf("restored write = %s with the rotated secret, want none", lastWrite)
+	}
+}
+
+func TestFollowsWrite(t *testing.T) {
+	m := NewMiddleware(Options{Window: 5 * time.Second, Secret: "secret"})
+	if vary := m.Vary(); strings.Join(vary, ",") != "X-Consistency-Token" {
+		t.Errorf("Vary = %v, want the header", vary)
+	}
+	if vary := NewMiddleware(Options{Cookie: "consistency"}).Vary(); strings.Join(vary, ",") != "X-Consistency-Token,Cookie" {
+		t.Errorf("Vary with a cookie = %v, want the header and Cookie", vary)
+	}
+
+	session := &Session{}
+	session.MarkWrite(time.Now(), "")
+	token := session.Token([]byte("secret"))
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"no token", "", false},
+		{"token of a write", token, true},
+		{"forged token", strings.Split(token, ".")[0] + ".c2lnbmF0dXJl", false},
+	}
+
+	for _, tt := range tests {
+		var got bool
+		handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = FollowsWrite(r)
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.token != "" {
+			r.Header.Set("X-Consistency-Token", tt.token)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		if got != tt.want {
+			t.Errorf("%s: FollowsWrite = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if FollowsWrite(httptest.NewRequest(http.MethodGet, "/", nil)) {
+		t.Error("FollowsWrite without the middleware, want false")
+	}
+}
@@ -0,0 +1,82 @@
+package cors
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Allowed methods and headers of the cross-origin requests
+const (
+	allowedMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	allowedHeaders = "Authorization, Content-Type, If-None-Match, If-Modified-Since, Last-Event-ID, X-Tenant-ID"
+	maxAge         = "600"
+)
+
+// CORS answers the preflight requests and sets the CORS headers of the allowed origins.
+// The origins can change while serving.
+type CORS struct {
+	mu      sync.RWMutex
+	any     bool
+	origins map[string]bool
+}
+
+// New creates new CORS
+func New(origins []string) *CORS {
+	c := &CORS{}
+	c.SetOrigins(origins)
+	return c
+}
+
+// SetOrigins replaces the allowed origins, "*" allows any origin
+func (c *CORS) SetOrigins(origins []string) {
+	allowed := make(map[string]bool, len(origins))
+	anyOrigin := false
+	for _, o := range origins {
+		if o == "*" {
+			anyOrigin = true
+		}
+		allowed[strings.TrimRight(o, "/")] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.any = anyOrigin
+	c.origins = allowed
+}
+
+func (c *CORS) allowed(origin string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.any || c.origins[origin]
+}
+
+// ServeHTTP is the negroni middleware
+func (c *CORS) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		next(w, r)
+		return
+	}
+
+	w.Header().Add("Vary", "Origin")
+	if !c.allowed(origin) {
+		next(w, r)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified, Location, Retry-After, X-Consistency-Token")
+
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+		w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+		w.Header().Set("Access-Control-Max-Age", maxAge)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	next(w, r)
+}
@@ -0,0 +1,69 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag builds a strong entity tag from the response body
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// WriteJSON encodes the data, sets the ETag and Last-Modified validators and answers
+// 304 Not Modified when the request preconditions match
+func WriteJSON(w http.ResponseWriter, r *http.Request, lastModified time.Time, data interface{}) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	etag := ETag(body.Bytes())
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body.Bytes())
+}
+
+// notModified evaluates If-None-Match, and If-Modified-Since only when If-None-Match is absent
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
@@ -0,0 +1,77 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	etag := `"5d41402abc4b2a76b9719d911017c592"`
+	lastModified := time.Date(2026, 10, 1, 12, 0, 0, 500000000, time.UTC)
+	before := lastModified.Add(-time.Hour).Format(http.TimeFormat)
+	after := lastModified.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		name         string
+		method       string
+		headers      map[string]string
+		lastModified time.Time
+		want         bool
+	}{
+		{"no precondition", http.MethodGet, nil, lastModified, false},
+		{"matching tag", http.MethodGet, map[string]string{"If-None-Match": etag}, lastModified, true},
+		{"matching tag of HEAD", http.MethodHead, map[string]string{"If-None-Match": etag}, lastModified, true},
+		{"matching tag of POST", http.MethodPost, map[string]string{"If-None-Match": etag}, lastModified, false},
+		{"weak tag", http.MethodGet, map[string]string{"If-None-Match": "W/" + etag}, lastModified, true},
+		{"tag in a list", http.MethodGet, map[string]string{"If-None-Match": `"other", ` + etag}, lastModified, true},
+		{"any tag", http.MethodGet, map[string]string{"If-None-Match": "*"}, lastModified, true},
+		{"other tag", http.MethodGet, map[string]string{"If-None-Match": `"other"`}, lastModified, false},
+		{"unquoted tag", http.MethodGet, map[string]string{"If-None-Match": etag[1 : len(etag)-1]}, lastModified, false},
+		{"other tag over a matching date", http.MethodGet, map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": after}, lastModified, false},
+		{"matching tag over an older date", http.MethodGet, map[string]string{"If-None-Match": etag, "If-Modified-Since": before}, lastModified, true},
+		{"not modified since", http.MethodGet, map[string]string{"If-Modified-Since": after}, lastModified, true},
+		{"same second", http.MethodGet, map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, lastModified, true},
+		{"modified since", http.MethodGet, map[string]string{"If-Modified-Since": before}, lastModified, false},
+		{"invalid date", http.MethodGet, map[string]string{"If-Modified-Since": "yesterday"}, lastModified, false},
+		{"no Last-Modified", http.MethodGet, map[string]string{"If-Modified-Since": after}, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/", nil)
+		for name, value := range tt.headers {
+			r.Header.Set(name, value)
+		}
+
+		if got := notModified(r, etag, tt.lastModified); got != tt.want {
+			t.Errorf("%s: notModified = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	lastModified := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	data := map[string]string{"title": "Heat"}
+
+	w := httptest.NewRecorder()
+	WriteJSON(w, httptest.NewRequest(http.MethodGet, "/", nil), lastModified, data)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Body.String() != "{\"title\":\"Heat\"}\n" {
+		t.Fatalf("status %d, ETag %q and body %q, want the document", w.Code, etag, w.Body.String())
+	}
+	if lm := w.Header().Get("Last-Modified"); lm != "Thu, 01 Oct 2026 12:00:00 GMT" {
+		t.Errorf("Last-Modified = %q, want the time", lm)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	WriteJSON(w, r, time.Time{}, data)
+	if w.Code != http.StatusNotModified || w.Body.Len() > 0 {
+		t.Errorf("status %d and body %q, want 304 without body", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") != etag || w.Header().Get("Last-Modified") != "" {
+		t.Errorf("headers %v, want the ETag only", w.Header())
+	}
+}
@@ -0,0 +1,69 @@
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Policies are the Cache-Control values of the routes
+type Policies struct {
+	// Routes maps a route name to its Cache-Control value, e.g. "movies_get": "public, max-age=60"
+	Routes map[string]string
+	// Vary lists the request headers selecting the response besides the URL, e.g. the tenant header.
+	// A shared cache keeps a copy per value of them.
+	Vary []string
+	// Private reports the requests whose response must not be stored by a shared cache, e.g. the reads
+	// following a write of the client. They get privateControl in place of the policy.
+	Private func(r *http.Request) bool
+}
+
+// privateControl keeps the response out of the shared caches, the client revalidates it before reuse
+const privateControl = "private, no-cache"
+
+// Wrap sets the Cache-Control policy of the route on its successful responses, and the Vary header
+// on all of them. Routes without a policy are returned untouched.
+func (p Policies) Wrap(route string, next http.HandlerFunc) http.HandlerFunc {
+	policy, ok := p.Routes[route]
+	if !ok || policy == "" {
+		return next
+	}
+
+	vary := strings.Join(p.Vary, ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		control := policy
+		if p.Private != nil && p.Private(r) {
+			control = privateControl
+		}
+
+		next(&policyWriter{ResponseWriter: w, policy: control, vary: vary}, r)
+	}
+}
+
+// policyWriter adds the Cache-Control header when the status is cacheable
+type policyWriter struct {
+	http.ResponseWriter
+	policy      string
+	vary        string
+	wroteHeader bool
+}
+
+func (w *policyWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if w.vary != "" {
+			w.Header().Add("Vary", w.vary)
+		}
+		if code == http.StatusOK || code == http.StatusNotModified {
+			w.Header().Set("Cache-Control", w.policy)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *policyWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,139 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// cacheKey is the key of a shared cache storing the response of the request: the URL and the values
+// of the request headers listed in Vary
+func cacheKey(r *http.Request, header http.Header) string {
+	key := r.Method + " " + r.URL.String()
+	for _, vary := range header.Values("Vary") {
+		for _, name := range strings.Split(vary, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			key += "\n" + name + ": " + r.Header.Get(name)
+		}
+	}
+
+	return key
+}
+
+func TestWrapKeysTheSharedCacheOnTheTenant(t *testing.T) {
+	policies := Policies{
+		Routes: map[string]string{"movies_get": "public, max-age=60"},
+		Vary:   []string{"X-Tenant-ID", "Authorization"},
+	}
+	handler := policies.Wrap("movies_get", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"tenant":"` + r.Header.Get("X-Tenant-ID") + `"}`))
+	})
+
+	tests := []struct {
+		name    string
+		headers [2]map[string]string
+	}{
+		{"tenant header", [2]map[string]string{{"X-Tenant-ID": "brand-a"}, {"X-Tenant-ID": "brand-b"}}},
+		{"tenant token", [2]map[string]string{{"Authorization": "Bearer token-a"}, {"Authorization": "Bearer token-b"}}},
+	}
+
+	for _, tt := range tests {
+		var keys [2]string
+		for i, headers := range tt.headers {
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies/1", nil)
+			for name, value := range headers {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=60" {
+				t.Errorf("%s: Cache-Control = %q, want the policy", tt.name, cc)
+			}
+			keys[i] = cacheKey(r, w.Header())
+		}
+
+		if keys[0] == keys[1] {
+			t.Errorf("%s: both tenants share the cache key %q", tt.name, keys[0])
+		}
+	}
+}
+
+func TestWrapKeepsTheReadsAfterAWriteOutOfTheSharedCaches(t *testing.T) {
+	policies := Policies{
+		Routes:  map[string]string{"movies_list": "public, max-age=30"},
+		Vary:    []string{"X-Tenant-ID", "X-Consistency-Token"},
+		Private: func(r *http.Request) bool { return r.Header.Get("X-Consistency-Token") != "" },
+	}
+	handler := policies.Wrap("movies_list", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	before := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+	before.Header.Set("X-Tenant-ID", "brand-a")
+	beforeW := httptest.NewRecorder()
+	handler(beforeW, before)
+
+	after := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+	after.Header.Set("X-Tenant-ID", "brand-a")
+	after.Header.Set("X-Consistency-Token", "token")
+	afterW := httptest.NewRecorder()
+	handler(afterW, after)
+
+	if cc := beforeW.Header().Get("Cache-Control"); cc != "public, max-age=30" {
+		t.Errorf("Cache-Control before the write = %q, want the policy", cc)
+	}
+	if cc := afterW.Header().Get("Cache-Control"); cc != privateControl {
+		t.Errorf("Cache-Control after the write = %q, want %q", cc, privateControl)
+	}
+	// The copy stored before the write is not served to the read following it
+	if cacheKey(before, beforeW.Header()) == cacheKey(after, beforeW.Header()) {
+		t.Error("the read after the write matches the copy stored before it")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	policies := Policies{
+		Routes: map[string]string{"movies_get": "public, max-age=60", "movies_stats": ""},
+		Vary:   []string{"X-Tenant-ID"},
+	}
+
+	tests := []struct {
+		name   string
+		route  string
+		status int
+		write  bool
+		cc     string
+		vary   string
+	}{
+		{"ok", "movies_get", http.StatusOK, false, "public, max-age=60", "X-Tenant-ID"},
+		{"implicit ok", "movies_get", 0, true, "public, max-age=60", "X-Tenant-ID"},
+		{"not modified", "movies_get", http.StatusNotModified, false, "public, max-age=60", "X-Tenant-ID"},
+		{"not found", "movies_get", http.StatusNotFound, false, "", "X-Tenant-ID"},
+		{"server error", "movies_get", http.StatusServiceUnavailable, false, "", "X-Tenant-ID"},
+		{"empty policy", "movies_stats", http.StatusOK, false, "", ""},
+		{"route without policy", "movies_list", http.StatusOK, false, "", ""},
+	}
+
+	for _, tt := range tests {
+		handler := policies.Wrap(tt.route, func(w http.ResponseWriter, r *http.Request) {
+			if tt.status != 0 {
+				w.WriteHeader(tt.status)
+			}
+			if tt.write {
+				_, _ = w.Write([]byte("{}"))
+			}
+		})
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if cc := w.Header().Get("Cache-Control"); cc != tt.cc {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.name, cc, tt.cc)
+		}
+		if vary := w.Header().Get("Vary"); vary != tt.vary {
+			t.Errorf("%s: Vary = %q, want %q", tt.name, vary, tt.vary)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package migration
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pressly/goose"
+	logger "github.com/sirupsen/logrus"
+)
+
+// Dialects of the migrated databases, the database/sql driver names
+const (
+	MySQL  = "mysql"
+	SQLite = "sqlite"
+)
+
+// gooseDialects are the goose dialects of the version table
+var gooseDialects = map[string]string{
+	MySQL:  "mysql",
+	SQLite: "sqlite3",
+}
+
+// ErrSchemaBehind returned when the database misses some of the migrations
+var ErrSchemaBehind = errors.New("the database schema is behind")
+
+func init() {
+	goose.SetLogger(logger.StandardLogger())
+}
+
+// Migrator runs the goose migrations of a file system against a database.
+// goose reads the migrations from disk, so they are copied to a temporary directory removed by Close.
+// The migrations are written for MySQL, they are translated when the database is SQLite.
+type Migrator struct {
+	db  *sql.DB
+	dir string
+}
+
+// NewMigrator creates new Migrator of the database of dialect, MySQL or SQLite
+func NewMigrator(db *sql.DB, dialect string, migrations fs.FS) (*Migrator, error) {
+	if db == nil {
+		return nil, errors.New("the DB connection is nil")
+	}
+
+	gooseDialect, ok := gooseDialects[dialect]
+	if !ok {
+		return nil, fmt.Errorf("unknown migration dialect %q", dialect)
+	}
+
+	if err := goose.SetDialect(gooseDialect); err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	err = extract(migrations, dir, dialect)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return &Migrator{db: db, dir: dir}, nil
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up() error {
+	return goose.Up(m.db, m.dir)
+}
+
+// Down rolls back the last applied migration
+func (m *Migrator) Down() error {
+	return goose.Down(m.db, m.dir)
+}
+
+// Redo rolls back and applies again the last migration
+func (m *Migrator) Redo() error {
+	return goose.Redo(m.db, m.dir)
+}
+
+// Status logs the state of every migration
+func (m *Migrator) Status() error {
+	return goose.Status(m.db, m.dir)
+}
+
+// Version logs the current schema version
+func (m *Migrator) Version() error {
+	return goose.Version(m.db, m.dir)
+}
+
+// Versions returns the current schema version and the latest known migration version
+func (m *Migrator) Versions() (current int64, latest int64, err error) {
+	current, err = goose.GetDBVersion(m.db)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	migrations, err := goose.CollectMigrations(m.dir, 0, goose.MaxVersion)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if last, err := migrations.Last(); err == nil {
+		latest = last.Version
+	}
+
+	return current, latest, nil
+}
+
+// Check returns ErrSchemaBehind when some migrations have not been applied
+func (m *Migrator) Check() error {
+	current, latest, err := m.Versions()
+	if err != nil {
+		return err
+	}
+
+	if current < latest {
+		return fmt.Errorf("%w: version %d, expected %d", ErrSchemaBehind, current, latest)
+	}
+
+	return nil
+}
+
+// Close removes the extracted migrations
+func (m *Migrator) Close() error {
+	return os.RemoveAll(m.dir)
+}
+
+// Create writes a new timestamped sql or go migration in dir
+func Create(dir string, name string, migrationType string) error {
+	return goose.Create(nil, dir, name, migrationType)
+}
+
+// extract copies the sql migrations to dir, translated for the dialect
+func extract(migrations fs.FS, dir string, dialect string) error {
+	files, err := fs.Glob(migrations, "*.sql")
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := fs.ReadFile(migrations, file)
+		if err != nil {
+			return err
+		}
+
+		if dialect == SQLite {
+			data = []byte(translateSQLite(string(data)))
+		}
+
+		err = ioutil.WriteFile(filepath.Join(dir, file), data, 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
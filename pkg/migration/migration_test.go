@@ -0,0 +1,70 @@
+package migration
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-rest-api/migrations"
+	"github.com/go-rest-api/pkg/mysql"
+)
+
+func TestMigrationsOnSQLite(t *testing.T) {
+	db, err := mysql.OpenSQLite(filepath.Join(t.TempDir(), "test.db"), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := NewMigrator(db.DB, SQLite, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err = m.Up(); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if err = m.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec("insert into movies (name, duration, genre) values ('Alien', 117, 'horror')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("insert into movie_external_ids (movie_id, provider, external_id) values (1, 'imdb', 'tt0078748')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("insert into movie_external_ids (movie_id, provider, external_id) values (1, 'tmdb', 'tt0078748')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("insert into movie_external_ids (movie_id, provider, external_id) values (1, 'imdb', 'tt0078749')")
+	if !mysql.IsDuplicate(err) {
+		t.Fatalf("err = %v, want a duplicate", err)
+	}
+	_, err = db.Exec("insert into movie_credits (movie_id, position, role, name) values (1, 0, 'director', 'Ridley Scott')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("insert into movie_credits (movie_id, position, role, name) values (1, 1, 'director', 'Ridley Scott')")
+	if !mysql.IsDuplicate(err) {
+		t.Fatalf("err = %v, want a duplicate", err)
+	}
+	_, err = db.Exec("insert into collection_items (collection_id, movie_id, position) values (1, 2, 0)")
+	if !mysql.IsForeignKeyViolation(err) {
+		t.Fatalf("err = %v, want a foreign key violation", err)
+	}
+
+	current, _, err := m.Versions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ; current > 0; current-- {
+		if err = m.Down(); err != nil {
+			t.Fatalf("down from %d: %v", current, err)
+		}
+	}
+}
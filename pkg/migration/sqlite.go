@@ -0,0 +1,148 @@
+package migration
+
+import (
+	"regexp"
+	"strings"
+)
+
+// The MySQL DDL of the migrations rewritten for SQLite
+var (
+	createTable   = regexp.MustCompile(`(?is)^CREATE\s+TABLE\s+(\w+)\s*\((.*)\)[^)]*$`)
+	alterTable    = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(\w+)\s+(.*)$`)
+	keyDef        = regexp.MustCompile(`(?is)^(?:ADD\s+)?(UNIQUE\s+)?(?:KEY|INDEX)\s+(\w+)\s*(\(.*\))$`)
+	primaryKeyDef = regexp.MustCompile(`(?is)^PRIMARY\s+KEY\s*\(\s*(\w+)\s*\)$`)
+	addColumn     = regexp.MustCompile(`(?is)^ADD\s+COLUMN\s+(.*)$`)
+	dropKey       = regexp.MustCompile(`(?is)^DROP\s+(?:KEY|INDEX)\s+(\w+)$`)
+	unsigned      = regexp.MustCompile(`(?i)\s+UNSIGNED\b`)
+	position      = regexp.MustCompile(`(?i)\s+(AFTER\s+\w+|FIRST)$`)
+	autoIncrement = regexp.MustCompile(`(?i)\bAUTO_INCREMENT\b`)
+)
+
+// translateSQLite rewrites the MySQL DDL of a goose migration for SQLite: the table options, the unsigned
+// types and the column positions are dropped, the auto increment key becomes an INTEGER PRIMARY KEY,
+// the keys become indexes and every clause of an ALTER TABLE becomes a statement of its own.
+// The other statements and the goose annotations are kept as is.
+func translateSQLite(migration string) string {
+	var out, statement strings.Builder
+	for _, line := range strings.Split(migration, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			if statement.Len() == 0 {
+				out.WriteString(line + "\n")
+			}
+			continue
+		}
+
+		statement.WriteString(trimmed + " ")
+		if !strings.HasSuffix(trimmed, ";") {
+			continue
+		}
+
+		for _, s := range sqliteStatements(strings.TrimSuffix(strings.TrimSpace(statement.String()), ";")) {
+			out.WriteString(s + ";\n")
+		}
+		statement.Reset()
+	}
+	out.WriteString(statement.String())
+
+	return out.String()
+}
+
+func sqliteStatements(statement string) []string {
+	if m := createTable.FindStringSubmatch(statement); m != nil {
+		return sqliteCreateTable(m[1], splitDefinitions(m[2]))
+	}
+
+	if m := alterTable.FindStringSubmatch(statement); m != nil {
+		return sqliteAlterTable(m[1], splitDefinitions(m[2]))
+	}
+
+	return []string{statement}
+}
+
+func sqliteCreateTable(table string, definitions []string) []string {
+	var (
+		columns []string
+		indexes []string
+		serial  string
+	)
+	for _, d := range definitions {
+		if autoIncrement.MatchString(d) {
+			serial = strings.Fields(d)[0]
+			columns = append(columns, serial+" INTEGER PRIMARY KEY AUTOINCREMENT")
+			continue
+		}
+
+		if m := primaryKeyDef.FindStringSubmatch(d); m != nil && m[1] == serial {
+			continue
+		}
+
+		if m := keyDef.FindStringSubmatch(d); m != nil {
+			indexes = append(indexes, createIndex(table, m))
+			continue
+		}
+
+		columns = append(columns, unsigned.ReplaceAllString(d, ""))
+	}
+
+	return append([]string{"CREATE TABLE " + table + " (" + strings.Join(columns, ", ") + ")"}, indexes...)
+}
+
+func sqliteAlterTable(table string, clauses []string) []string {
+	var statements []string
+	for _, c := range clauses {
+		switch {
+		case keyDef.MatchString(c):
+			statements = append(statements, createIndex(table, keyDef.FindStringSubmatch(c)))
+		case dropKey.MatchString(c):
+			statements = append(statements, "DROP INDEX "+dropKey.FindStringSubmatch(c)[1])
+		case addColumn.MatchString(c):
+			column := addColumn.FindStringSubmatch(c)[1]
+			column = position.ReplaceAllString(unsigned.ReplaceAllString(column, ""), "")
+			statements = append(statements, "ALTER TABLE "+table+" ADD COLUMN "+column)
+		default:
+			statements = append(statements, "ALTER TABLE "+table+" "+c)
+		}
+	}
+
+	return statements
+}
+
+// createIndex writes the index of a key definition match: unique, name and columns
+func createIndex(table string, key []string) string {
+	kind := "INDEX"
+	if key[1] != "" {
+		kind = "UNIQUE INDEX"
+	}
+
+	return "CREATE " + kind + " " + key[2] + " ON " + table + " " + key[3]
+}
+
+// splitDefinitions splits the comma separated definitions outside of the parentheses and the quotes
+func splitDefinitions(definitions string) []string {
+	var (
+		parts []string
+		depth int
+		quote rune
+		start int
+	)
+	for i, r := range definitions {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(definitions[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(parts, strings.TrimSpace(definitions[start:]))
+}
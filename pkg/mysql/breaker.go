@@ -0,0 +1,96 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+
+	"github.com/go-rest-api/pkg/breaker"
+	"github.com/go-sql-driver/mysql"
+)
+
+// BreakerPrefix names the circuit breakers of the servers, e.g. mysql-master or mysql-slave-0
+const BreakerPrefix = "mysql-"
+
+// masterBreaker guards the operations on Master DB
+const masterBreaker = BreakerPrefix + "master"
+
+// States of a guarded operation
+const (
+	opPending int32 = iota
+	opRunning
+	opAbandoned
+)
+
+// guard runs fn in the circuit breaker of the server. Only the failures of the server count,
+// the other errors of fn are returned as is.
+//
+// The breaker gives up on fn at its timeout, guard then waits for fn to exit, its context cancelled,
+// so fn never writes the memory of the caller after guard returned.
+func guard(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	var (
+		state int32
+		opErr error
+		done  = make(chan struct{})
+	)
+	err := breaker.Do(ctx, name, func(ctx context.Context) error {
+		if !atomic.CompareAndSwapInt32(&state, opPending, opRunning) {
+			return nil
+		}
+		defer close(done)
+
+		opErr = fn(ctx)
+		if isFailure(opErr) {
+			return opErr
+		}
+
+		return nil
+	})
+	// fn is not started once abandoned, else it is waited for
+	if !atomic.CompareAndSwapInt32(&state, opPending, opAbandoned) {
+		<-done
+	}
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return opErr
+}
+
+// MySQL errors of a server unable to serve the queries
+const (
+	errTooManyConnections = 1040
+	errServerShutdown     = 1053
+	errOutOfMemory        = 1037
+)
+
+// isFailure reports whether err tells that the server is failing: a lost or refused connection,
+// a driver failure, a timeout or a server unable to serve. The errors of the queries and the callers,
+// e.g. a duplicate key or a movie not found returned by a transaction, do not count.
+func isFailure(err error) bool {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrTimeout),
+		errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, mysql.ErrInvalidConn),
+		errors.Is(err, mysql.ErrMalformPkt),
+		errors.Is(err, mysql.ErrPktSync),
+		errors.Is(err, mysql.ErrPktSyncMul),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.As(err, &netErr):
+		return true
+	}
+
+	return isMySQLError(err, errQueryInterrupted) || isMySQLError(err, errTooManyConnections) ||
+		isMySQLError(err, errServerShutdown) || isMySQLError(err, errOutOfMemory) ||
+		isSQLiteError(err, sqliteIOErr, sqliteCorrupt, sqliteFull, sqliteCantOpen)
+}
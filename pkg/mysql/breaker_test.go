@@ -0,0 +1,263 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-rest-api/pkg/breaker"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestBreakerOpensOnServerFailures(t *testing.T) {
+	replica, mock := newMockedReplica(t, "breaker-failures")
+	set, err := NewReplicaSet(ReplicaOptions{}, replica)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &BaseRepository{Replicas: set}
+
+	breaker.Configure(breaker.Config{}, map[string]breaker.Config{
+		"mysql-breaker-failures": {Timeout: time.Second, RequestVolume: 2, ErrorPercent: 50, SleepWindow: time.Minute},
+	})
+
+	// The missing rows tell nothing about the server
+	for i := 0; i < 3; i++ {
+		mock.ExpectQuery("select").WillReturnError(sql.ErrNoRows)
+
+		var id int64
+		err = r.FetchRow(context.Background(), "select id from movies", &id)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("err = %v, want sql.ErrNoRows", err)
+		}
+	}
+
+	down := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	deadline := time.Now().Add(time.Second)
+	for {
+		mock.ExpectQuery("select").WillReturnError(down)
+
+		var id int64
+		err = r.FetchRow(context.Background(), "select id from movies", &id)
+		if errors.Is(err, breaker.ErrOpen) {
+			break
+		}
+		if !errors.Is(err, down) {
+			t.Fatalf("err = %v, want the server error", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the breaker did not open")
+		}
+
+		// The metrics of the breaker are collected asynchronously
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBreakerIgnoresDomainErrors(t *testing.T) {
+	master, mock := newMockedReplica(t, "breaker-domain")
+	r := &BaseRepository{MasterDB: master.DB}
+
+	breaker.Configure(breaker.Config{}, map[string]breaker.Config{
+		masterBreaker: {Timeout: time.Second, RequestVolume: 2, ErrorPercent: 50, SleepWindow: time.Minute},
+	})
+
+	// A client repeating the writes of a missing movie leaves the master available
+	errNotFound := errors.New("movie not found")
+	for i := 0; i < 20; i++ {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		err := r.WithTransaction(context.Background(), func(ctx context.Context) error {
+			return errNotFound
+		})
+		if !errors.Is(err, errNotFound) {
+			t.Fatalf("err = %v, want the domain error", err)
+		}
+
+		// The metrics of the breaker are collected asynchronously
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestIsFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"no rows", sql.ErrNoRows, false},
+		{"canceled", context.Canceled, false},
+		{"domain error", errors.New("movie not found"), false},
+		{"tenant required", ErrTenantRequired, false},
+		{"duplicate key", &mysql.MySQLError{Number: errDuplicateEntry}, false},
+		{"deadlock", &mysql.MySQLError{Number: errDeadlock}, false},
+		{"timeout", ErrTimeout, true},
+		{"query interrupted", &mysql.MySQLError{Number: errQueryInterrupted}, true},
+		{"too many connections", &mysql.MySQLError{Number: errTooManyConnections}, true},
+		{"bad connection", driver.ErrBadConn, true},
+		{"invalid connection", mysql.ErrInvalidConn, true},
+		{"lost connection", io.ErrUnexpectedEOF, true},
+		{"refused connection", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFailure(tt.err); got != tt.want {
+				t.Errorf("isFailure(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryErrorTellsTheResponseLayer(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		err         error
+		timeout     bool
+		unavailable bool
+	}{
+		{"no rows", context.Background(), sql.ErrNoRows, false, false},
+		{"duplicate key", context.Background(), &mysql.MySQLError{Number: errDuplicateEntry}, false, false},
+		{"deadline", expired, errors.New("canceled"), true, false},
+		{"query interrupted", context.Background(), &mysql.MySQLError{Number: errQueryInterrupted}, true, false},
+		{"bad connection", context.Background(), driver.ErrBadConn, false, true},
+		{"too many connections", context.Background(), &mysql.MySQLError{Number: errTooManyConnections}, false, true},
+		{"refused connection", context.Background(), &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, false, true},
+		{"no replica", context.Background(), ErrNoReplica, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := queryError(tt.ctx, tt.err)
+
+			var (
+				timeout     interface{ Timeout() bool }
+				unavailable interface{ Unavailable() bool }
+			)
+			if got := errors.As(err, &timeout) && timeout.Timeout(); got != tt.timeout {
+				t.Errorf("timeout = %t, want %t", got, tt.timeout)
+			}
+			if got := errors.As(err, &unavailable) && unavailable.Unavailable(); got != tt.unavailable {
+				t.Errorf("unavailable = %t, want %t", got, tt.unavailable)
+			}
+			// The cause stays in the chain, for the breakers and the callers
+			if tt.timeout {
+				return
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v in the chain", err, tt.err)
+			}
+			if isFailure(err) != isFailure(tt.err) {
+				t.Errorf("isFailure = %t, want %t", isFailure(err), isFailure(tt.err))
+			}
+		})
+	}
+}
+
+func TestReadFallsBackToMaster(t *testing.T) {
+	replica, replicaMock := newMockedReplica(t, "fallback")
+	set, err := NewReplicaSet(ReplicaOptions{}, replica)
+	if err != nil {
+		t.Fatal(err)
+	}
+	master, masterMock := newMockedReplica(t, "master")
+
+	r := &BaseRepository{
+		MasterDB: master.DB,
+		Replicas: set,
+		Settings: NewSettings(Options{Fallback: Fallback{Enabled: true, RequestsPerSecond: 0.001, Burst: 1}}),
+	}
+
+	down := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	replicaMock.ExpectQuery("select").WillReturnError(down)
+	masterMock.ExpectQuery("select").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	var id int64
+	err = r.FetchRow(context.Background(), "select id from movies", &id)
+	if err != nil || id != 7 {
+		t.Fatalf("id, err = %d, %v, want the master row", id, err)
+	}
+
+	// The burst is spent, the replica error is returned
+	replicaMock.ExpectQuery("select").WillReturnError(down)
+	err = r.FetchRow(context.Background(), "select id from movies", &id)
+	if !errors.Is(err, down) {
+		t.Fatalf("err = %v, want the replica error", err)
+	}
+
+	// The missing rows are not a failure of the replica
+	r.Settings.Set(Options{Fallback: Fallback{Enabled: true}})
+	replicaMock.ExpectQuery("select").WillReturnError(sql.ErrNoRows)
+	err = r.FetchRow(context.Background(), "select id from movies", &id)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("err = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := masterMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGuardWaitsForTheAbandonedOperation(t *testing.T) {
+	breaker.Configure(breaker.Config{}, map[string]breaker.Config{
+		"mysql-guard-timeout": {Timeout: 10 * time.Millisecond},
+	})
+
+	var resp []int
+	err := guard(context.Background(), "mysql-guard-timeout", func(ctx context.Context) error {
+		<-ctx.Done()
+		// A scan still writing the destination after the breaker timeout
+		time.Sleep(20 * time.Millisecond)
+		resp = append(resp, 1)
+		return ctx.Err()
+	})
+	if !errors.Is(err, breaker.ErrTimeout) {
+		t.Fatalf("err = %v, want breaker.ErrTimeout", err)
+	}
+
+	// Read after guard returned, the race detector reports a write still running
+	if len(resp) != 1 {
+		t.Errorf("resp = %v, want the write of the finished operation", resp)
+	}
+}
+
+func TestFallbackReadsIntoFreshRows(t *testing.T) {
+	replica, replicaMock := newMockedReplica(t, "fallback-partial")
+	set, err := NewReplicaSet(ReplicaOptions{}, replica)
+	if err != nil {
+		t.Fatal(err)
+	}
+	master, masterMock := newMockedReplica(t, "master")
+
+	r := &BaseRepository{
+		MasterDB: master.DB,
+		Replicas: set,
+		Settings: NewSettings(Options{Fallback: Fallback{Enabled: true}}),
+	}
+
+	// The replica fails after the first row
+	lost := io.ErrUnexpectedEOF
+	replicaMock.ExpectQuery("select").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).RowError(1, lost))
+	masterMock.ExpectQuery("select").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+
+	var ids []int64
+	err = r.FetchRows(context.Background(), "select id from movies", &ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("ids = %v, want the master rows only", ids)
+	}
+}
@@ -0,0 +1,103 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// defaultMaxIdleConns is the idle pool size of database/sql
+const defaultMaxIdleConns = 2
+
+// Connector opens the pool connections with the current DSN, so the credentials can rotate
+// without replacing the *sqlx.DB shared by the repositories
+type Connector struct {
+	mu      sync.RWMutex
+	dsn     string
+	db      *sqlx.DB
+	maxIdle int
+}
+
+// Open creates a pool on a Connector of dsn and checks the connection
+func Open(dsn string) (*sqlx.DB, *Connector, error) {
+	c := &Connector{dsn: dsn, maxIdle: defaultMaxIdleConns}
+	c.db = sqlx.NewDb(sql.OpenDB(c), "mysql")
+
+	err := c.db.Ping()
+	if err != nil {
+		_ = c.db.Close()
+		return nil, nil, err
+	}
+
+	return c.db, c, nil
+}
+
+// Connect opens a connection with the current DSN
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.RLock()
+	dsn := c.dsn
+	c.mu.RUnlock()
+
+	connector, err := mysql.MySQLDriver{}.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return connector.Connect(ctx)
+}
+
+// Driver returns the MySQL driver
+func (c *Connector) Driver() driver.Driver {
+	return mysql.MySQLDriver{}
+}
+
+// SetMaxIdleConns sets the idle pool size kept after a Reconnect
+func (c *Connector) SetMaxIdleConns(n int) {
+	c.mu.Lock()
+	c.maxIdle = n
+	c.mu.Unlock()
+
+	c.db.SetMaxIdleConns(n)
+}
+
+// Reconnect switches to dsn and closes the idle connections, the new ones use dsn.
+// The busy connections stay open until they are released. Nothing happens when dsn is unchanged.
+// The previous DSN is restored when the server rejects dsn.
+func (c *Connector) Reconnect(dsn string) error {
+	c.mu.Lock()
+	if c.dsn == dsn {
+		c.mu.Unlock()
+		return nil
+	}
+	prev := c.dsn
+	c.dsn = dsn
+	c.mu.Unlock()
+
+	c.closeIdle()
+
+	err := c.db.Ping()
+	if err != nil {
+		c.mu.Lock()
+		c.dsn = prev
+		c.mu.Unlock()
+
+		c.closeIdle()
+		return err
+	}
+
+	return nil
+}
+
+// closeIdle closes the idle connections, so the next ones are opened with the current DSN
+func (c *Connector) closeIdle() {
+	c.mu.Lock()
+	maxIdle := c.maxIdle
+	c.mu.Unlock()
+
+	c.db.SetMaxIdleConns(0)
+	c.db.SetMaxIdleConns(maxIdle)
+}
@@ -0,0 +1,24 @@
+package mysql
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestReconnectRestoresTheRejectedDSN(t *testing.T) {
+	c := &Connector{dsn: "movies@tcp(127.0.0.1:1)/movies", maxIdle: defaultMaxIdleConns}
+	c.db = sqlx.NewDb(sql.OpenDB(c), "mysql")
+	defer c.db.Close()
+
+	// Nothing listens on the port 2, the server rejects the new DSN
+	err := c.Reconnect("rotated@tcp(127.0.0.1:2)/movies?timeout=1s")
+	if err == nil {
+		t.Fatal("Reconnect succeeded, want the ping error")
+	}
+
+	if c.dsn != "movies@tcp(127.0.0.1:1)/movies" {
+		t.Errorf("dsn = %s, want the previous one", c.dsn)
+	}
+}
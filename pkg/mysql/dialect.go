@@ -0,0 +1,91 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// MySQL errors of the constraint violations
+const (
+	errDuplicateEntry    = 1062
+	errNoReferencedRow   = 1452
+	errNoReferencedRowV2 = 1216
+)
+
+// Dialect writes the parts of the queries which differ between the database engines.
+// The dialect of a DB follows its driver name, see DialectOf.
+type Dialect interface {
+	// Name is the database/sql driver name of the engine
+	Name() string
+	// ForUpdate ends a select locking its rows until the end of the transaction, empty when
+	// the transactions lock the whole database
+	ForUpdate() string
+	// UpdateIgnore starts an update of the table skipping the rows which would break a unique key
+	UpdateIgnore(table string) string
+	// Upsert ends an insert updating the columns of the row which already holds the unique key
+	Upsert(key []string, columns []string) string
+	// FindInSet is true when value is an item of the comma separated list
+	FindInSet(value string, list string) string
+	// IntDiv divides the non-negative integers rounding down
+	IntDiv(a string, b string) string
+}
+
+// Dialects of the supported engines
+var (
+	MySQL  Dialect = mysqlDialect{}
+	SQLite Dialect = sqliteDialect{}
+)
+
+// DialectOf returns the dialect of the DB driver, MySQL when unknown
+func DialectOf(db *sqlx.DB) Dialect {
+	if db != nil && db.DriverName() == SQLite.Name() {
+		return SQLite
+	}
+
+	return MySQL
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return "mysql" }
+
+func (mysqlDialect) ForUpdate() string { return " for update" }
+
+func (mysqlDialect) UpdateIgnore(table string) string { return "update ignore " + table }
+
+func (mysqlDialect) Upsert(key []string, columns []string) string {
+	updates := make([]string, len(columns))
+	for i, c := range columns {
+		updates[i] = fmt.Sprintf("%s = values(%s)", c, c)
+	}
+
+	return " on duplicate key update " + strings.Join(updates, ", ")
+}
+
+func (mysqlDialect) FindInSet(value string, list string) string {
+	return fmt.Sprintf("find_in_set(%s, %s) > 0", value, list)
+}
+
+func (mysqlDialect) IntDiv(a string, b string) string {
+	return fmt.Sprintf("floor(%s / %s)", a, b)
+}
+
+// IsDuplicate reports whether err is the violation of a unique key
+func IsDuplicate(err error) bool {
+	return isMySQLError(err, errDuplicateEntry) || isSQLiteError(err, sqliteConstraintUnique, sqliteConstraintPrimaryKey)
+}
+
+// IsForeignKeyViolation reports whether err is a row referencing a missing row
+func IsForeignKeyViolation(err error) bool {
+	return isMySQLError(err, errNoReferencedRow) || isMySQLError(err, errNoReferencedRowV2) ||
+		isSQLiteError(err, sqliteConstraintForeignKey)
+}
+
+func isMySQLError(err error, number uint16) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == number
+}
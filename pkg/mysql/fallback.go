@@ -0,0 +1,80 @@
+package mysql
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/opentracing/opentracing-go"
+	logger "github.com/sirupsen/logrus"
+)
+
+// FallbackBreaker guards the reads falling back to Master DB, its metrics count the fallbacks
+const FallbackBreaker = BreakerPrefix + "fallback"
+
+// read runs fn on the DB of the reads, or on Master DB when the replica fails and the fallback allows it.
+// Every attempt scans into a fresh destination copied to resp once it succeeded, so the rows of a replica
+// failing in the middle of the scan are not mixed with the ones of the master.
+func (r *BaseRepository) read(ctx context.Context, resp interface{}, fn func(ctx context.Context, db *sqlx.DB, dest interface{}) error) error {
+	name, slaveDB, err := r.slave(ctx)
+	if err == nil {
+		err = readInto(ctx, name, slaveDB, resp, fn)
+	}
+
+	if err == nil || name == masterBreaker || !r.fallback(ctx, err) {
+		return err
+	}
+
+	if name == "" {
+		name = "the replicas"
+	}
+	logger.Warnf("reading from the master, %s failed: %v", name, err)
+
+	span := opentracing.SpanFromContext(ctx)
+	if span != nil {
+		span.SetTag("db.fallback", true)
+	}
+
+	return readInto(ctx, FallbackBreaker, r.MasterDB, resp, fn)
+}
+
+// readInto runs fn on db in the named breaker with a new value of the type of resp, set to resp on success
+func readInto(ctx context.Context, name string, db *sqlx.DB, resp interface{}, fn func(ctx context.Context, db *sqlx.DB, dest interface{}) error) error {
+	// The scan reports the destinations which are not a pointer
+	target := reflect.ValueOf(resp)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return guard(ctx, name, func(ctx context.Context) error {
+			return queryError(ctx, fn(ctx, db, resp))
+		})
+	}
+
+	dest := reflect.New(target.Type().Elem())
+	err := guard(ctx, name, func(ctx context.Context) error {
+		return queryError(ctx, fn(ctx, db, dest.Interface()))
+	})
+	if err != nil {
+		return err
+	}
+
+	target.Elem().Set(dest.Elem())
+	return nil
+}
+
+// fallback reports whether the read failing on the replica with err can be retried on Master DB
+func (r *BaseRepository) fallback(ctx context.Context, err error) bool {
+	if !r.Settings.Get().Fallback.Enabled || r.MasterDB == nil {
+		return false
+	}
+
+	// The deadline of the read is over, or the failure is not the replica's
+	if ctx.Err() != nil || !isFailure(err) {
+		return false
+	}
+
+	if !r.Settings.allowFallback() {
+		logger.Debugf("the fallback to the master is rate limited: %v", err)
+		return false
+	}
+
+	return true
+}
@@ -4,75 +4,403 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"github.com/go-rest-api/pkg/consistency"
+	"github.com/go-rest-api/pkg/tenant"
 	"github.com/jmoiron/sqlx"
 	"github.com/opentracing/opentracing-go"
+	logger "github.com/sirupsen/logrus"
+	"strings"
+	"time"
 )
 
 // The operation constants
 const (
-	execOperation      = "repository.base.exec"
-	fetchRowOperation  = "repository.base.fetch_row"
-	fetchRowsOperation = "repository.base.fetch_rows"
+	execOperation        = "repository.base.exec"
+	fetchRowOperation    = "repository.base.fetch_row"
+	fetchRowsOperation   = "repository.base.fetch_rows"
+	transactionOperation = "repository.base.transaction"
 )
 
+// TenantPlaceholder marks the value of the tenant condition in the queries of a tenant scoped
+// repository, e.g. "select id from movies where tenant_id = {tenant} and id = ?"
+const TenantPlaceholder = "{tenant}"
+
+// Tenancy errors
+var (
+	ErrTenantRequired = errors.New("the tenant is missing from the context")
+	ErrUnscopedQuery  = errors.New("the query is not scoped to the tenant")
+)
+
+// errQueryInterrupted is the MySQL error of a query exceeding max_execution_time
+const errQueryInterrupted = 3024
+
+// ErrTimeout is returned when a query exceeds its deadline
+var ErrTimeout error = timeoutError{}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "query timeout" }
+
+// Timeout tells the response layer that the database timed out
+func (timeoutError) Timeout() bool { return true }
+
+// withTimeout bounds the context of an operation, the earlier deadline of ctx wins
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// queryError returns ErrTimeout for the queries cut by their deadline, and marks the other failures
+// of the server as unavailable
+func queryError(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, ErrTimeout) {
+		return err
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || isMySQLError(err, errQueryInterrupted) {
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+
+	var unavailable interface{ Unavailable() bool }
+	if isFailure(err) && !errors.As(err, &unavailable) {
+		return failureError{err: err}
+	}
+
+	return err
+}
+
 // BaseRepository type
 type BaseRepository struct {
 	MasterDB *sqlx.DB
+	// SlaveDB serves the reads when there is no Replicas
 	SlaveDB  *sqlx.DB
+	Replicas *ReplicaSet
+	// Settings of the operations, the defaults when nil
+	Settings *Settings
+	// TenantScoped rejects the queries without the TenantPlaceholder and binds it to the tenant of the context
+	TenantScoped bool
+}
+
+// Dialect returns the dialect of Master DB
+func (r *BaseRepository) Dialect() Dialect {
+	return DialectOf(r.MasterDB)
 }
 
+// Exec executes the query with named args on Master DB, or in the transaction of the context
 func (r *BaseRepository) Exec(ctx context.Context, query string, args interface{}) (sql.Result, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, execOperation)
 	defer span.Finish()
 
-	var (
-		res sql.Result
-		err error
-	)
-
 	if r.MasterDB == nil {
-		return res, errors.New("the master DB connection is nil")
+		return nil, errors.New("the master DB connection is nil")
+	}
+
+	ctx, cancel := withTimeout(ctx, r.Settings.Get().Timeouts.Write)
+	defer cancel()
+
+	var db sqlx.ExtContext = r.MasterDB
+	state := r.joinedTx(ctx)
+	if state != nil {
+		db = state.tx
+	}
+
+	q, positional, err := sqlx.Named(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	q, positional, err = r.scope(ctx, q, positional)
+	if err != nil {
+		return nil, err
 	}
 
-	res, err = r.MasterDB.NamedExecContext(ctx, query, args)
+	// The transaction is guarded as a whole
+	if state != nil {
+		res, err := db.ExecContext(ctx, q, positional...)
+		return res, queryError(ctx, err)
+	}
 
+	var res sql.Result
+	err = guard(ctx, masterBreaker, func(ctx context.Context) error {
+		result, execErr := db.ExecContext(ctx, q, positional...)
+		res = result
+		return queryError(ctx, execErr)
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	// The write of a transaction is marked on commit
+	r.markWrite(ctx)
 	return res, nil
 }
 
-// FetchRow the fetch data row on Slave DB
+// FetchRows the fetch data rows on Slave DB, or in the transaction of the context
 func (r *BaseRepository) FetchRows(ctx context.Context, query string, resp interface{}, args ...interface{}) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, fetchRowOperation)
+	span, ctx := opentracing.StartSpanFromContext(ctx, fetchRowsOperation)
 	defer span.Finish()
 
-	if r.SlaveDB == nil {
-		return errors.New("the slave DB connection is nil")
-	}
+	ctx, cancel := withTimeout(ctx, r.Settings.Get().Timeouts.Read)
+	defer cancel()
 
-	err := r.SlaveDB.Select(resp, query, args...)
+	query, args, err := r.scope(ctx, query, args)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if state := r.joinedTx(ctx); state != nil {
+		return queryError(ctx, state.tx.SelectContext(ctx, resp, query, args...))
+	}
+
+	return r.read(ctx, resp, func(ctx context.Context, db *sqlx.DB, dest interface{}) error {
+		return db.SelectContext(ctx, dest, query, args...)
+	})
 }
 
-// FetchRow the fetch data row on Slave DB
+// FetchRow the fetch data row on Slave DB, or in the transaction of the context
 func (r *BaseRepository) FetchRow(ctx context.Context, query string, resp interface{}, args ...interface{}) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, fetchRowOperation)
 	defer span.Finish()
 
-	if r.SlaveDB == nil {
-		return errors.New("the slave DB connection is nil")
+	ctx, cancel := withTimeout(ctx, r.Settings.Get().Timeouts.Read)
+	defer cancel()
+
+	query, args, err := r.scope(ctx, query, args)
+	if err != nil {
+		return err
+	}
+
+	if state := r.joinedTx(ctx); state != nil {
+		return queryError(ctx, state.tx.GetContext(ctx, resp, query, args...))
 	}
 
-	err := r.SlaveDB.Get(resp, query, args...)
+	return r.read(ctx, resp, func(ctx context.Context, db *sqlx.DB, dest interface{}) error {
+		return db.GetContext(ctx, dest, query, args...)
+	})
+}
+
+// slave returns the DB of the reads with the name of its breaker, a replica picked by the Replicas
+// or else SlaveDB. The master serves the reads which must see a recent write of the session.
+func (r *BaseRepository) slave(ctx context.Context) (string, *sqlx.DB, error) {
+	var (
+		name    string
+		slaveDB *sqlx.DB
+	)
+	if r.Replicas != nil {
+		replica, err := r.Replicas.Pick()
+		if err != nil {
+			return "", nil, err
+		}
+		name, slaveDB = BreakerPrefix+replica.Name, replica.DB
+	} else if r.SlaveDB != nil {
+		name, slaveDB = BreakerPrefix+"slave", r.SlaveDB
+	} else {
+		return "", nil, errors.New("the slave DB connection is nil")
+	}
+
+	policy := r.Settings.Get().Consistency
+	if policy.Mode == Eventual || r.MasterDB == nil {
+		return name, slaveDB, nil
+	}
+
+	session, ok := consistency.FromContext(ctx)
+	if !ok {
+		return name, slaveDB, nil
+	}
+
+	lastWrite, gtid := session.LastWrite()
+	if lastWrite.IsZero() || time.Since(lastWrite) > policy.Window {
+		return name, slaveDB, nil
+	}
+
+	if policy.Mode == ReadYourWritesWait && gtid != "" {
+		err := waitForGTID(ctx, slaveDB, gtid, policy.WaitTimeout)
+		if err == nil {
+			return name, slaveDB, nil
+		}
+		logger.Debugf("reading from the master: %v", err)
+	}
+
+	return masterBreaker, r.MasterDB, nil
+}
+
+// markWrite records the write in the session of the context, with the GTID set of the master
+// when the reads wait for it
+func (r *BaseRepository) markWrite(ctx context.Context) {
+	policy := r.Settings.Get().Consistency
+	if policy.Mode == Eventual {
+		return
+	}
+
+	session, ok := consistency.FromContext(ctx)
+	if !ok {
+		return
+	}
+
+	var gtid string
+	if policy.Mode == ReadYourWritesWait {
+		err := r.MasterDB.GetContext(ctx, &gtid, "select @@global.gtid_executed")
+		if err != nil {
+			logger.Debugf("the GTID set of the write is unknown: %v", err)
+		}
+		gtid = strings.ReplaceAll(gtid, "\n", "")
+	}
+
+	session.MarkWrite(time.Now(), gtid)
+}
+
+// waitForGTID waits until the replica applied the GTID set
+func waitForGTID(ctx context.Context, db *sqlx.DB, gtid string, timeout time.Duration) error {
+	var timedOut sql.NullInt64
+	err := db.GetContext(ctx, &timedOut, "select wait_for_executed_gtid_set(?, ?)", gtid, timeout.Seconds())
 	if err != nil {
 		return err
 	}
 
+	if !timedOut.Valid || timedOut.Int64 != 0 {
+		return fmt.Errorf("the replica did not apply %s within %s", gtid, timeout)
+	}
+
 	return nil
 }
+
+// Transaction runs fn in a transaction on Master DB, committed when fn returns nil and rolled back otherwise.
+// It joins the transaction of the context like WithTransaction.
+func (r *BaseRepository) Transaction(ctx context.Context, fn func(tx *Tx) error) error {
+	return r.WithTransaction(ctx, func(ctx context.Context) error {
+		return fn(&Tx{tx: r.joinedTx(ctx).tx, repo: r})
+	})
+}
+
+// Tx is a transaction on Master DB with the tenant scoping of its repository
+type Tx struct {
+	tx   *sqlx.Tx
+	repo *BaseRepository
+}
+
+// Dialect returns the dialect of the transaction
+func (t *Tx) Dialect() Dialect {
+	return t.repo.Dialect()
+}
+
+// Exec executes the query with positional args
+func (t *Tx) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query, args, err := t.repo.scope(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx, t.repo.Settings.Get().Timeouts.Write)
+	defer cancel()
+
+	res, err := t.tx.ExecContext(ctx, query, args...)
+	return res, queryError(ctx, err)
+}
+
+// NamedExec executes the query with named args from a struct or a map
+func (t *Tx) NamedExec(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	query, args, err := sqlx.Named(query, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Exec(ctx, query, args...)
+}
+
+// FetchRows the fetch data rows in the transaction
+func (t *Tx) FetchRows(ctx context.Context, query string, resp interface{}, args ...interface{}) error {
+	query, args, err := t.repo.scope(ctx, query, args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, t.repo.Settings.Get().Timeouts.Read)
+	defer cancel()
+
+	return queryError(ctx, t.tx.SelectContext(ctx, resp, query, args...))
+}
+
+// FetchRow the fetch data row in the transaction
+func (t *Tx) FetchRow(ctx context.Context, query string, resp interface{}, args ...interface{}) error {
+	query, args, err := t.repo.scope(ctx, query, args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, t.repo.Settings.Get().Timeouts.Read)
+	defer cancel()
+
+	return queryError(ctx, t.tx.GetContext(ctx, resp, query, args...))
+}
+
+// scope binds the tenant placeholders of the query when the repository is tenant scoped
+func (r *BaseRepository) scope(ctx context.Context, query string, args []interface{}) (string, []interface{}, error) {
+	if !r.TenantScoped {
+		return query, args, nil
+	}
+
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return "", nil, ErrTenantRequired
+	}
+
+	return bindTenant(query, args, tenantID)
+}
+
+// bindTenant replaces the tenant placeholders by bindvars and inserts the tenant at their position
+// in the args. Quoted strings are skipped.
+func bindTenant(query string, args []interface{}, tenantID string) (string, []interface{}, error) {
+	var (
+		b      strings.Builder
+		quote  byte
+		next   int
+		bound  int
+		scoped = make([]interface{}, 0, len(args)+1)
+	)
+
+	for i := 0; i < len(query); {
+		c := query[i]
+
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			b.WriteByte(c)
+			i++
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			if next < len(args) {
+				scoped = append(scoped, args[next])
+			}
+			next++
+		case strings.HasPrefix(query[i:], TenantPlaceholder):
+			b.WriteByte('?')
+			scoped = append(scoped, tenantID)
+			bound++
+			i += len(TenantPlaceholder)
+			continue
+		}
+
+		b.WriteByte(c)
+		i++
+	}
+
+	if bound == 0 {
+		return "", nil, ErrUnscopedQuery
+	}
+
+	if next != len(args) {
+		return "", nil, fmt.Errorf("the query expects %d args, got %d", next, len(args))
+	}
+
+	return b.String(), scoped, nil
+}
@@ -0,0 +1,252 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-rest-api/pkg/consistency"
+	"github.com/go-rest-api/pkg/tenant"
+	"github.com/jmoiron/sqlx"
+)
+
+func newScopedRepository(t *testing.T) (*BaseRepository, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlxDB := sqlx.NewDb(db, "mysql")
+	return &BaseRepository{MasterDB: sqlxDB, SlaveDB: sqlxDB, TenantScoped: true}, mock
+}
+
+func TestBindTenant(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		args      []interface{}
+		wantQuery string
+		wantArgs  []interface{}
+		wantErr   error
+	}{
+		{
+			name:      "placeholder first",
+			query:     "select id from movies where tenant_id = {tenant} and id = ?",
+			args:      []interface{}{1},
+			wantQuery: "select id from movies where tenant_id = ? and id = ?",
+			wantArgs:  []interface{}{"a", 1},
+		},
+		{
+			name:      "placeholder between args",
+			query:     "select floor(duration / ?) from movies where tenant_id = {tenant} and duration > ?",
+			args:      []interface{}{30, 60},
+			wantQuery: "select floor(duration / ?) from movies where tenant_id = ? and duration > ?",
+			wantArgs:  []interface{}{30, "a", 60},
+		},
+		{
+			name:      "several placeholders",
+			query:     "select 1 from a join b where a.tenant_id = {tenant} and b.tenant_id = {tenant} and a.id = ?",
+			args:      []interface{}{2},
+			wantQuery: "select 1 from a join b where a.tenant_id = ? and b.tenant_id = ? and a.id = ?",
+			wantArgs:  []interface{}{"a", "a", 2},
+		},
+		{
+			name:      "quoted question mark",
+			query:     "select id from movies where name = '?' and tenant_id = {tenant} and id = ?",
+			args:      []interface{}{3},
+			wantQuery: "select id from movies where name = '?' and tenant_id = ? and id = ?",
+			wantArgs:  []interface{}{"a", 3},
+		},
+		{
+			name:    "unscoped query",
+			query:   "select id from movies where id = ?",
+			args:    []interface{}{1},
+			wantErr: ErrUnscopedQuery,
+		},
+		{
+			name:    "quoted placeholder only",
+			query:   "select id from movies where name = '{tenant}'",
+			wantErr: ErrUnscopedQuery,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := bindTenant(tt.query, tt.args, "a")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestScopedRepositoryRequiresTenant(t *testing.T) {
+	r, mock := newScopedRepository(t)
+	defer r.MasterDB.Close()
+	ctx := context.Background()
+
+	var ids []int64
+	if err := r.FetchRows(ctx, "select id from movies where tenant_id = {tenant}", &ids); err != ErrTenantRequired {
+		t.Errorf("FetchRows err = %v, want %v", err, ErrTenantRequired)
+	}
+
+	var id int64
+	if err := r.FetchRow(ctx, "select id from movies where tenant_id = {tenant} and id = ?", &id, 1); err != ErrTenantRequired {
+		t.Errorf("FetchRow err = %v, want %v", err, ErrTenantRequired)
+	}
+
+	if _, err := r.Exec(ctx, "delete from movies where tenant_id = {tenant} and id = :id", map[string]interface{}{"id": 1}); err != ErrTenantRequired {
+		t.Errorf("Exec err = %v, want %v", err, ErrTenantRequired)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScopedRepositoryRejectsUnscopedQueries(t *testing.T) {
+	r, mock := newScopedRepository(t)
+	defer r.MasterDB.Close()
+	ctx := tenant.WithTenant(context.Background(), "a")
+
+	var ids []int64
+	if err := r.FetchRows(ctx, "select id from movies", &ids); err != ErrUnscopedQuery {
+		t.Errorf("FetchRows err = %v, want %v", err, ErrUnscopedQuery)
+	}
+
+	if _, err := r.Exec(ctx, "delete from movies where id = :id", map[string]interface{}{"id": 1}); err != ErrUnscopedQuery {
+		t.Errorf("Exec err = %v, want %v", err, ErrUnscopedQuery)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScopedRepositoryBindsTenant(t *testing.T) {
+	r, mock := newScopedRepository(t)
+	defer r.MasterDB.Close()
+	ctx := tenant.WithTenant(context.Background(), "a")
+
+	mock.ExpectQuery("select id from movies where tenant_id = ? and id = ?").
+		WithArgs("a", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("update movies set name = ? where tenant_id = ? and id = ?").
+		WithArgs("x", "a", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("delete from movies where tenant_id = ? and id = ?").
+		WithArgs("a", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var id int64
+	if err := r.FetchRow(ctx, "select id from movies where tenant_id = {tenant} and id = ?", &id, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Exec(ctx, "update movies set name = :name where tenant_id = {tenant} and id = :id", map[string]interface{}{"name": "x", "id": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := r.Transaction(ctx, func(tx *Tx) error {
+		_, err := tx.Exec(ctx, "delete from movies where tenant_id = {tenant} and id = ?", 2)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadYourWritesRoutesToMaster(t *testing.T) {
+	masterDB, master, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slaveDB, slave, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &BaseRepository{
+		MasterDB: sqlx.NewDb(masterDB, "mysql"),
+		SlaveDB:  sqlx.NewDb(slaveDB, "mysql"),
+		Settings: NewSettings(Options{Consistency: Consistency{Mode: ReadYourWritesMaster, Window: time.Minute}}),
+	}
+	defer r.MasterDB.Close()
+	defer r.SlaveDB.Close()
+
+	// Another client without write reads from the slave
+	slave.ExpectQuery("select id from movies where id = ?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	var id int64
+	if err := r.FetchRow(consistency.WithSession(context.Background(), &consistency.Session{}), "select id from movies where id = ?", &id, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The writer reads its write from the master
+	ctx := consistency.WithSession(context.Background(), &consistency.Session{})
+	master.ExpectExec("insert into movies (name) values (?)").
+		WithArgs("x").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	master.ExpectQuery("select id from movies where id = ?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	if _, err := r.Exec(ctx, "insert into movies (name) values (:name)", map[string]interface{}{"name": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.FetchRow(ctx, "select id from movies where id = ?", &id, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mock := range []sqlmock.Sqlmock{master, slave} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlxDB := sqlx.NewDb(db, "mysql")
+	defer sqlxDB.Close()
+
+	r := &BaseRepository{
+		MasterDB: sqlxDB,
+		SlaveDB:  sqlxDB,
+		Settings: NewSettings(Options{Timeouts: Timeouts{Read: 10 * time.Millisecond}}),
+	}
+
+	mock.ExpectQuery("select sleep(1)").
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"sleep"}).AddRow(0))
+
+	var slept int64
+	err = r.FetchRow(context.Background(), "select sleep(1)", &slept)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+}
@@ -0,0 +1,305 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	logger "github.com/sirupsen/logrus"
+)
+
+// Load balancing policies of a ReplicaSet
+const (
+	RoundRobin       = "round_robin"
+	LeastConnections = "least_connections"
+)
+
+// Replication lag sources of a ReplicaSet
+const (
+	LagSlaveStatus = "slave_status"
+	LagHeartbeat   = "heartbeat"
+)
+
+// ErrNoReplica is returned when every replica is ejected
+var ErrNoReplica error = unavailableError("no healthy replica")
+
+type unavailableError string
+
+func (e unavailableError) Error() string { return string(e) }
+
+// Unavailable tells the response layer that the database is unavailable
+func (e unavailableError) Unavailable() bool { return true }
+
+// failureError is a failure of the server, e.g. a refused connection, the cause stays in the chain
+type failureError struct {
+	err error
+}
+
+func (e failureError) Error() string { return e.err.Error() }
+
+func (e failureError) Unwrap() error { return e.err }
+
+// Unavailable tells the response layer that the database is unavailable
+func (e failureError) Unavailable() bool { return true }
+
+// Replica is a read server of a ReplicaSet
+type Replica struct {
+	Name      string
+	DB        *sqlx.DB
+	Connector *Connector
+}
+
+// ReplicaStatus is the result of the last check of a replica
+type ReplicaStatus struct {
+	Name    string
+	Healthy bool
+	// Lag is the replication lag, zero when it is not checked
+	Lag     time.Duration
+	Err     error
+	Checked time.Time
+}
+
+// ReplicaOptions of the selection and the ejection of the replicas
+type ReplicaOptions struct {
+	// Balancer is RoundRobin or LeastConnections
+	Balancer string
+	// MaxLag ejects the replicas lagging further behind the master, 0 disables the lag checks
+	MaxLag time.Duration
+	// LagSource is LagSlaveStatus or LagHeartbeat
+	LagSource string
+	// HeartbeatTable has a ts column of the UTC time written on the master, e.g. by pt-heartbeat
+	HeartbeatTable string
+}
+
+// ReplicaSet balances the reads between the healthy replicas. The replicas are healthy until
+// a Check ejects them, a later Check brings them back.
+type ReplicaSet struct {
+	mu       sync.RWMutex
+	replicas []*Replica
+	statuses []ReplicaStatus
+	options  ReplicaOptions
+	next     uint64
+}
+
+// NewReplicaSet creates new ReplicaSet of the replicas
+func NewReplicaSet(options ReplicaOptions, replicas ...*Replica) (*ReplicaSet, error) {
+	if len(replicas) == 0 {
+		return nil, errors.New("the replica set is empty")
+	}
+
+	for _, r := range replicas {
+		if r.DB == nil {
+			return nil, fmt.Errorf("the %s DB connection is nil", r.Name)
+		}
+	}
+
+	s := &ReplicaSet{replicas: replicas, statuses: make([]ReplicaStatus, len(replicas))}
+	for i, r := range replicas {
+		s.statuses[i] = ReplicaStatus{Name: r.Name, Healthy: true}
+	}
+	s.SetOptions(options)
+
+	return s, nil
+}
+
+// SetOptions replaces the options, they apply from the next selection and the next check
+func (s *ReplicaSet) SetOptions(options ReplicaOptions) {
+	if options.Balancer == "" {
+		options.Balancer = RoundRobin
+	}
+
+	s.mu.Lock()
+	s.options = options
+	s.mu.Unlock()
+}
+
+// Replicas returns the replicas in their config order
+func (s *ReplicaSet) Replicas() []*Replica {
+	return s.replicas
+}
+
+// Pick selects a healthy replica with the balancer
+func (s *ReplicaSet) Pick() (*Replica, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	healthy := make([]*Replica, 0, len(s.replicas))
+	for i, r := range s.replicas {
+		if s.statuses[i].Healthy {
+			healthy = append(healthy, r)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return nil, ErrNoReplica
+	}
+
+	// The rotation also breaks the ties of the least connections
+	start := int(atomic.AddUint64(&s.next, 1) % uint64(len(healthy)))
+	if s.options.Balancer != LeastConnections {
+		return healthy[start], nil
+	}
+
+	picked := healthy[start]
+	least := picked.DB.Stats().InUse
+	for i := 1; i < len(healthy); i++ {
+		r := healthy[(start+i)%len(healthy)]
+		if inUse := r.DB.Stats().InUse; inUse < least {
+			picked, least = r, inUse
+		}
+	}
+
+	return picked, nil
+}
+
+// Statuses returns the result of the last check of every replica
+func (s *ReplicaSet) Statuses() []ReplicaStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]ReplicaStatus(nil), s.statuses...)
+}
+
+// Check pings the replicas and measures their lag, the failing ones are ejected and the recovered ones
+// are brought back
+func (s *ReplicaSet) Check(ctx context.Context) []ReplicaStatus {
+	s.mu.RLock()
+	options := s.options
+	s.mu.RUnlock()
+
+	statuses := make([]ReplicaStatus, len(s.replicas))
+
+	var wg sync.WaitGroup
+	for i, r := range s.replicas {
+		wg.Add(1)
+		go func(i int, r *Replica) {
+			defer wg.Done()
+			statuses[i] = checkReplica(ctx, r, options)
+		}(i, r)
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	for i, status := range statuses {
+		if status.Healthy != s.statuses[i].Healthy {
+			if status.Healthy {
+				logger.Infof("replica %s is back", status.Name)
+			} else {
+				logger.Warnf("replica %s is ejected: %v", status.Name, status.Err)
+			}
+		}
+	}
+	s.statuses = statuses
+	s.mu.Unlock()
+
+	return append([]ReplicaStatus(nil), statuses...)
+}
+
+// Watch checks the replicas every interval until stop is called
+func (s *ReplicaSet) Watch(interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkCtx, cancelCheck := context.WithTimeout(ctx, interval)
+				s.Check(checkCtx)
+				cancelCheck()
+			}
+		}
+	}()
+
+	return cancel
+}
+
+func checkReplica(ctx context.Context, r *Replica, options ReplicaOptions) ReplicaStatus {
+	status := ReplicaStatus{Name: r.Name, Checked: time.Now()}
+
+	status.Err = r.DB.PingContext(ctx)
+	if status.Err != nil {
+		return status
+	}
+
+	if options.MaxLag <= 0 {
+		status.Healthy = true
+		return status
+	}
+
+	status.Lag, status.Err = replicationLag(ctx, r.DB, options)
+	if status.Err != nil {
+		return status
+	}
+
+	if status.Lag > options.MaxLag {
+		status.Err = fmt.Errorf("the replication lag %s exceeds %s", status.Lag, options.MaxLag)
+		return status
+	}
+
+	status.Healthy = true
+	return status
+}
+
+// replicationLag reads the lag of the replica from its slave status or from the heartbeat table
+func replicationLag(ctx context.Context, db *sqlx.DB, options ReplicaOptions) (time.Duration, error) {
+	if options.LagSource == LagHeartbeat {
+		var seconds sql.NullFloat64
+		q := fmt.Sprintf("select timestampdiff(microsecond, max(ts), utc_timestamp(6)) / 1000000 from %s", options.HeartbeatTable)
+
+		err := db.GetContext(ctx, &seconds, q)
+		if err != nil {
+			return 0, err
+		}
+		if !seconds.Valid {
+			return 0, fmt.Errorf("the heartbeat table %s is empty", options.HeartbeatTable)
+		}
+
+		return time.Duration(seconds.Float64 * float64(time.Second)), nil
+	}
+
+	rows, err := db.QueryxContext(ctx, "show slave status")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	// A server without replication, e.g. the master read as a replica, has no lag
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+
+	status := make(map[string]interface{})
+	err = rows.MapScan(status)
+	if err != nil {
+		return 0, err
+	}
+
+	// Seconds_Behind_Master is NULL while the replication is stopped
+	seconds := status["Seconds_Behind_Master"]
+	if b, ok := seconds.([]byte); ok && b != nil {
+		seconds = string(b)
+	} else if ok {
+		seconds = nil
+	}
+	if seconds == nil {
+		return 0, errors.New("the replication is stopped")
+	}
+
+	var lag int64
+	_, err = fmt.Sscan(fmt.Sprint(seconds), &lag)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(lag) * time.Second, nil
+}
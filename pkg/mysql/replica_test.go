@@ -0,0 +1,115 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+func newMockedReplica(t *testing.T, name string) (*Replica, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Replica{Name: name, DB: sqlx.NewDb(db, "mysql")}, mock
+}
+
+func TestReplicaSetRoundRobin(t *testing.T) {
+	a, _ := newMockedReplica(t, "a")
+	b, _ := newMockedReplica(t, "b")
+
+	set, err := NewReplicaSet(ReplicaOptions{Balancer: RoundRobin}, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	picked := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		r, err := set.Pick()
+		if err != nil {
+			t.Fatal(err)
+		}
+		picked[r.Name]++
+	}
+
+	if picked["a"] != 5 || picked["b"] != 5 {
+		t.Errorf("picked %v, want 5 each", picked)
+	}
+}
+
+func TestReplicaSetEjectsFailingReplicas(t *testing.T) {
+	a, mockA := newMockedReplica(t, "a")
+	b, mockB := newMockedReplica(t, "b")
+
+	set, err := NewReplicaSet(ReplicaOptions{MaxLag: 10 * time.Second, LagSource: LagSlaveStatus}, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a lags behind the threshold, b fails its ping
+	mockA.ExpectPing()
+	mockA.ExpectQuery("show slave status").
+		WillReturnRows(sqlmock.NewRows([]string{"Slave_IO_Running", "Seconds_Behind_Master"}).AddRow("Yes", "30"))
+	mockB.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	statuses := set.Check(context.Background())
+	for _, status := range statuses {
+		if status.Healthy {
+			t.Errorf("replica %s is healthy, want ejected", status.Name)
+		}
+	}
+	if statuses[0].Lag != 30*time.Second {
+		t.Errorf("lag = %s, want 30s", statuses[0].Lag)
+	}
+
+	_, err = set.Pick()
+	if !errors.Is(err, ErrNoReplica) {
+		t.Fatalf("Pick() error = %v, want ErrNoReplica", err)
+	}
+
+	// a caught up, b is still down
+	mockA.ExpectPing()
+	mockA.ExpectQuery("show slave status").
+		WillReturnRows(sqlmock.NewRows([]string{"Slave_IO_Running", "Seconds_Behind_Master"}).AddRow("Yes", "2"))
+	mockB.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	set.Check(context.Background())
+	for i := 0; i < 3; i++ {
+		r, err := set.Pick()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Name != "a" {
+			t.Errorf("picked %s, want a", r.Name)
+		}
+	}
+
+	for _, mock := range []sqlmock.Sqlmock{mockA, mockB} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReplicaSetStoppedReplication(t *testing.T) {
+	a, mock := newMockedReplica(t, "a")
+
+	set, err := NewReplicaSet(ReplicaOptions{MaxLag: 10 * time.Second, LagSource: LagSlaveStatus}, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectPing()
+	mock.ExpectQuery("show slave status").
+		WillReturnRows(sqlmock.NewRows([]string{"Slave_IO_Running", "Seconds_Behind_Master"}).AddRow("No", nil))
+
+	statuses := set.Check(context.Background())
+	if statuses[0].Healthy {
+		t.Error("replica with a stopped replication is healthy, want ejected")
+	}
+}
@@ -0,0 +1,95 @@
+package mysql
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/go-rest-api/pkg/ratelimit"
+)
+
+// Consistency modes of the reads following a write of the same session
+const (
+	// Eventual reads from the replicas, a write may be missing until they catch up
+	Eventual = "eventual"
+	// ReadYourWritesMaster reads from the master during the window following a write
+	ReadYourWritesMaster = "master"
+	// ReadYourWritesWait waits for the replica to apply the GTID set of the write, the master
+	// serves the read when the replica does not catch up in time
+	ReadYourWritesWait = "wait"
+)
+
+// Consistency of the reads following a write of the consistency.Session of the context
+type Consistency struct {
+	Mode string
+	// Window after a write during which the reads of the session are consistent
+	Window time.Duration
+	// WaitTimeout for the replica to catch up in ReadYourWritesWait mode
+	WaitTimeout time.Duration
+}
+
+// Timeouts of the operations, bounded by the deadline of their context. Zero is no timeout.
+type Timeouts struct {
+	// Read of FetchRow and FetchRows
+	Read time.Duration
+	// Write of Exec
+	Write time.Duration
+	// Transaction of WithTransaction, its retries included
+	Transaction time.Duration
+}
+
+// Fallback of the reads to Master DB when the replica fails or its breaker is open
+type Fallback struct {
+	Enabled bool
+	// RequestsPerSecond and Burst of the fallback reads shared by the repositories, 0 is unlimited
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// Options of the BaseRepository operations
+type Options struct {
+	Consistency Consistency
+	Timeouts    Timeouts
+	Fallback    Fallback
+}
+
+// Settings holds the Options shared by the repositories, they can be replaced while serving
+type Settings struct {
+	v        atomic.Value
+	fallback *ratelimit.Limiter
+}
+
+// NewSettings creates new Settings of options
+func NewSettings(options Options) *Settings {
+	s := &Settings{fallback: ratelimit.New(0, 0)}
+	s.Set(options)
+	return s
+}
+
+// Set replaces the options, the operations in progress keep the previous ones
+func (s *Settings) Set(options Options) {
+	if options.Consistency.Mode == "" {
+		options.Consistency.Mode = Eventual
+	}
+
+	s.fallback.SetLimit(options.Fallback.RequestsPerSecond, options.Fallback.Burst)
+	s.v.Store(options)
+}
+
+// allowFallback takes a token of the fallback reads
+func (s *Settings) allowFallback() bool {
+	if s == nil {
+		return false
+	}
+
+	ok, _ := s.fallback.Allow("master")
+	return ok
+}
+
+// Get returns the current options, the defaults on nil Settings
+func (s *Settings) Get() Options {
+	if s == nil {
+		return Options{Consistency: Consistency{Mode: Eventual}}
+	}
+
+	return s.v.Load().(Options)
+}
@@ -0,0 +1,94 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
+)
+
+// SQLite result codes, the extended ones carry the primary code in their low byte
+const (
+	sqliteBusy                 = 5
+	sqliteLocked               = 6
+	sqliteIOErr                = 10
+	sqliteCorrupt              = 11
+	sqliteFull                 = 13
+	sqliteCantOpen             = 14
+	sqliteConstraintForeignKey = 787
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+)
+
+// OpenSQLite opens the database file with the foreign keys enforced. The journal is written ahead,
+// so the reads go on during a write, and the transactions take the write lock when they begin
+// and wait for it up to busyTimeout.
+func OpenSQLite(path string, busyTimeout time.Duration) (*sqlx.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "journal_mode(wal)")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	params.Set("_txlock", "immediate")
+
+	db, err := sqlx.Open(SQLite.Name(), "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping()
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return "sqlite" }
+
+func (sqliteDialect) ForUpdate() string { return "" }
+
+func (sqliteDialect) UpdateIgnore(table string) string { return "update or ignore " + table }
+
+func (sqliteDialect) Upsert(key []string, columns []string) string {
+	updates := make([]string, len(columns))
+	for i, c := range columns {
+		updates[i] = fmt.Sprintf("%s = excluded.%s", c, c)
+	}
+
+	return fmt.Sprintf(" on conflict (%s) do update set %s", strings.Join(key, ", "), strings.Join(updates, ", "))
+}
+
+func (sqliteDialect) FindInSet(value string, list string) string {
+	return fmt.Sprintf("instr(',' || %s || ',', ',' || %s || ',') > 0", list, value)
+}
+
+func (sqliteDialect) IntDiv(a string, b string) string {
+	return fmt.Sprintf("(%s / %s)", a, b)
+}
+
+// isSQLiteError reports whether err is a SQLite error of one of the codes, of any code when none is given
+func isSQLiteError(err error, codes ...int) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	if len(codes) == 0 {
+		return true
+	}
+
+	for _, code := range codes {
+		if sqliteErr.Code() == code || (code < 256 && sqliteErr.Code()&0xff == code) {
+			return true
+		}
+	}
+
+	return false
+}
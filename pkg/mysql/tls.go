@@ -0,0 +1,61 @@
+package mysql
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TLSOptions of the connections to a server with a private CA or a client certificate
+type TLSOptions struct {
+	// CAFile is the PEM bundle verifying the server, the system roots when empty
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key, both or none
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the host name checked against the server certificate
+	ServerName string
+	// InsecureSkipVerify accepts any server certificate, for development only
+	InsecureSkipVerify bool
+}
+
+// RegisterTLS loads the certificates and registers the TLS config under name, the tls parameter of the DSN.
+// Registering the name again replaces the config of the new connections.
+func RegisterTLS(name string, options TLSOptions) error {
+	config := &tls.Config{
+		ServerName:         options.ServerName,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if options.CAFile != "" {
+		pem, err := ioutil.ReadFile(options.CAFile)
+		if err != nil {
+			return err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in %s", options.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if (options.CertFile == "") != (options.KeyFile == "") {
+		return errors.New("the client certificate and key go together")
+	}
+
+	if options.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return mysql.RegisterTLSConfig(name, config)
+}
@@ -0,0 +1,184 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/opentracing/opentracing-go"
+	logger "github.com/sirupsen/logrus"
+)
+
+// MySQL errors of the transactions which succeed when retried
+const (
+	errLockWaitTimeout = 1205
+	errDeadlock        = 1213
+)
+
+// defaultTxRetries of a transaction failing on a deadlock or a lock wait timeout
+const defaultTxRetries = 3
+
+// txRetryBackoff is the wait before the first retry, doubled for every retry
+const txRetryBackoff = 20 * time.Millisecond
+
+// TxOptions of a transaction
+type TxOptions struct {
+	// Isolation level, the server default when zero
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// Retries after a deadlock or a lock wait timeout, 3 when zero and none when negative
+	Retries int
+	// Timeout of the transaction and its retries, the Settings one when zero
+	Timeout time.Duration
+}
+
+// txState is the transaction carried by the context
+type txState struct {
+	db         *sqlx.DB
+	tx         *sqlx.Tx
+	savepoints int
+}
+
+type txContextKey struct{}
+
+// InTransaction reports whether the context carries a transaction
+func InTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(txContextKey{}).(*txState)
+	return ok
+}
+
+// WithTransaction runs fn in a transaction on Master DB with the default options, see WithTransactionOptions
+func (r *BaseRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.WithTransactionOptions(ctx, TxOptions{}, fn)
+}
+
+// WithTransactionOptions runs fn in a transaction on Master DB, committed when fn returns nil and rolled back
+// otherwise. The context of fn carries the transaction, the repositories on the same Master DB called with it
+// join the transaction.
+//
+// Called with a context already in a transaction, fn runs in a savepoint of that transaction and the options
+// are ignored: an error of fn only rolls back the savepoint.
+//
+// The transaction is retried from the start on a deadlock or a lock wait timeout, so fn must have no side
+// effect other than its queries.
+func (r *BaseRepository) WithTransactionOptions(ctx context.Context, options TxOptions, fn func(ctx context.Context) error) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, transactionOperation)
+	defer span.Finish()
+
+	if r.MasterDB == nil {
+		return errors.New("the master DB connection is nil")
+	}
+
+	if state := r.joinedTx(ctx); state != nil {
+		return state.savepoint(ctx, fn)
+	}
+
+	retries := options.Retries
+	if retries == 0 {
+		retries = defaultTxRetries
+	}
+
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = r.Settings.Get().Timeouts.Transaction
+	}
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := txRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := guard(ctx, masterBreaker, func(ctx context.Context) error {
+			return queryError(ctx, r.runTransaction(ctx, options, fn))
+		})
+		if err == nil {
+			r.markWrite(ctx)
+			return nil
+		}
+
+		if !IsRetryable(err) || attempt >= retries {
+			return err
+		}
+
+		logger.Debugf("retrying the transaction after %v", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// runTransaction runs fn in a new transaction
+func (r *BaseRepository) runTransaction(ctx context.Context, options TxOptions, fn func(ctx context.Context) error) (err error) {
+	sqlTx, err := r.MasterDB.BeginTxx(ctx, &sql.TxOptions{Isolation: options.Isolation, ReadOnly: options.ReadOnly})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = sqlTx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			_ = sqlTx.Rollback()
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, txContextKey{}, &txState{db: r.MasterDB, tx: sqlTx}))
+	if err != nil {
+		return err
+	}
+
+	return sqlTx.Commit()
+}
+
+// savepoint runs fn in a savepoint of the transaction, released when fn returns nil and rolled back otherwise
+func (s *txState) savepoint(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	s.savepoints++
+	name := fmt.Sprintf("sp_%d", s.savepoints)
+
+	_, err = s.tx.ExecContext(ctx, "savepoint "+name)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = s.tx.ExecContext(ctx, "rollback to savepoint "+name)
+			panic(p)
+		}
+	}()
+
+	err = fn(ctx)
+	if err != nil {
+		// A deadlock already rolled back the whole transaction, the savepoint is gone
+		if !isMySQLError(err, errDeadlock) {
+			_, _ = s.tx.ExecContext(ctx, "rollback to savepoint "+name)
+		}
+		return err
+	}
+
+	_, err = s.tx.ExecContext(ctx, "release savepoint "+name)
+	return err
+}
+
+// joinedTx returns the transaction of the context when it runs on the Master DB of the repository
+func (r *BaseRepository) joinedTx(ctx context.Context) *txState {
+	state, ok := ctx.Value(txContextKey{}).(*txState)
+	if !ok || state.db != r.MasterDB {
+		return nil
+	}
+
+	return state
+}
+
+// IsRetryable reports whether err is a deadlock or a lock wait timeout, or a busy SQLite database,
+// after which the transaction can be retried
+func IsRetryable(err error) bool {
+	return isMySQLError(err, errDeadlock) || isMySQLError(err, errLockWaitTimeout) || isSQLiteError(err, sqliteBusy, sqliteLocked)
+}
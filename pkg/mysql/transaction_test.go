@@ -0,0 +1,144 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+func newMockedMaster(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sqlx.NewDb(db, "mysql"), mock
+}
+
+func TestWithTransactionJoinedByRepositories(t *testing.T) {
+	masterDB, mock := newMockedMaster(t)
+	defer masterDB.Close()
+
+	movies := &BaseRepository{MasterDB: masterDB}
+	collections := &BaseRepository{MasterDB: masterDB}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("insert into movies (name) values (?)").WithArgs("x").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("select id from movies where id = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("insert into collection_items (movie_id) values (?)").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := movies.WithTransaction(context.Background(), func(ctx context.Context) error {
+		if !InTransaction(ctx) {
+			t.Error("the context carries no transaction")
+		}
+
+		if _, err := movies.Exec(ctx, "insert into movies (name) values (:name)", map[string]interface{}{"name": "x"}); err != nil {
+			return err
+		}
+
+		// The read sees the uncommitted insert
+		var id int64
+		if err := movies.FetchRow(ctx, "select id from movies where id = ?", &id, 1); err != nil {
+			return err
+		}
+
+		_, err := collections.Exec(ctx, "insert into collection_items (movie_id) values (:movie_id)", map[string]interface{}{"movie_id": id})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithTransactionNestedSavepoint(t *testing.T) {
+	masterDB, mock := newMockedMaster(t)
+	defer masterDB.Close()
+
+	r := &BaseRepository{MasterDB: masterDB}
+	errNested := errors.New("nested")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("savepoint sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("delete from movies").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("rollback to savepoint sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("savepoint sp_2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("release savepoint sp_2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := r.WithTransaction(context.Background(), func(ctx context.Context) error {
+		err := r.WithTransaction(ctx, func(ctx context.Context) error {
+			if _, err := r.Exec(ctx, "delete from movies", map[string]interface{}{}); err != nil {
+				return err
+			}
+			return errNested
+		})
+		if !errors.Is(err, errNested) {
+			t.Errorf("nested err = %v, want %v", err, errNested)
+		}
+
+		return r.WithTransaction(ctx, func(ctx context.Context) error {
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithTransactionRetriesDeadlocks(t *testing.T) {
+	masterDB, mock := newMockedMaster(t)
+	defer masterDB.Close()
+
+	r := &BaseRepository{MasterDB: masterDB}
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("update movies set duration = 1").WillReturnError(deadlock)
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("update movies set duration = 1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	attempts := 0
+	err := r.WithTransaction(context.Background(), func(ctx context.Context) error {
+		attempts++
+		_, err := r.Exec(ctx, "update movies set duration = 1", map[string]interface{}{})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+
+	// Other errors are not retried
+	mock.ExpectBegin()
+	mock.ExpectExec("update movies set duration = 1").WillReturnError(&mysql.MySQLError{Number: 1062})
+	mock.ExpectRollback()
+
+	err = r.WithTransactionOptions(context.Background(), TxOptions{Retries: 5}, func(ctx context.Context) error {
+		_, err := r.Exec(ctx, "update movies set duration = 1", map[string]interface{}{})
+		return err
+	})
+	if IsRetryable(err) || err == nil {
+		t.Errorf("err = %v, want the duplicate error", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-rest-api/pkg/response"
+)
+
+// idleTimeout after which the bucket of a silent client is forgotten
+const idleTimeout = 10 * time.Minute
+
+// bucket is the token bucket of a client
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter limits the requests per client address with a token bucket.
+// The limit can change while serving, a zero rate disables it.
+type Limiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// New creates new Limiter of rate requests per second, with bursts of burst requests
+func New(rate float64, burst int) *Limiter {
+	l := &Limiter{buckets: make(map[string]*bucket)}
+	l.SetLimit(rate, burst)
+	return l
+}
+
+// SetLimit replaces the limit, the current buckets keep their tokens up to the new burst
+func (l *Limiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate = rate
+	l.burst = float64(burst)
+}
+
+// Allow takes a token of the client, the duration is the wait before the next token when none is left
+func (l *Limiter) Allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// sweep forgets the idle clients, at most once per idle timeout
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < idleTimeout {
+		return
+	}
+	l.swept = now
+
+	for client, b := range l.buckets {
+		if now.Sub(b.last) > idleTimeout {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// Middleware rejects the requests over the limit with 429 and Retry-After
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, wait := l.Allow(clientAddress(r))
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			response.WriteAPIErrorMessage(w, response.APIErrTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientAddress is the remote host, the forwarded headers can be forged and are ignored
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
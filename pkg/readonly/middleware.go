@@ -0,0 +1,62 @@
+package readonly
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-rest-api/pkg/response"
+)
+
+// Middleware rejects the writes with 503 and Retry-After while the mode is on, the safe methods go through
+func (m *Mode) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		status := m.Status()
+		if !status.ReadOnly {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		m.mu.RLock()
+		retryAfter := m.retryAfter
+		m.mu.RUnlock()
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		response.WriteAPIErrorMessage(w, response.APIErrReadOnlyMode)
+	})
+}
+
+// toggle is the body of the admin switch
+type toggle struct {
+	ReadOnly bool   `json:"read_only"`
+	Reason   string `json:"reason"`
+}
+
+// StatusHandler answers the Status
+func (m *Mode) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	response.WriteAPIOKWithData(w, m.Status())
+}
+
+// ToggleHandler switches the mode of the admin, e.g. {"read_only": true, "reason": "maintenance"}
+func (m *Mode) ToggleHandler(w http.ResponseWriter, r *http.Request) {
+	var payload toggle
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrorBadRequest, err)
+		return
+	}
+
+	if payload.Reason == "" {
+		payload.Reason = "switched by an admin"
+	}
+	m.SetAdmin(payload.ReadOnly, payload.Reason)
+
+	response.WriteAPIOKWithData(w, m.Status())
+}
@@ -0,0 +1,54 @@
+package readonly
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareRejectsWrites(t *testing.T) {
+	m := New(10 * time.Second)
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, "/", nil))
+		return w
+	}
+
+	if w := serve(http.MethodPost); w.Code != http.StatusNoContent {
+		t.Fatalf("write status = %d while read-write, want 204", w.Code)
+	}
+
+	m.SetHealth(true, "the master is unavailable")
+
+	w := serve(http.MethodPost)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "10" {
+		t.Fatalf("write status = %d, Retry-After = %q, want 503 and 10", w.Code, w.Header().Get("Retry-After"))
+	}
+	var body struct{ Code string }
+	_ = json.NewDecoder(w.Body).Decode(&body)
+	if body.Code != "READ_ONLY_MODE" {
+		t.Errorf("code = %q, want READ_ONLY_MODE", body.Code)
+	}
+
+	if w := serve(http.MethodGet); w.Code != http.StatusNoContent {
+		t.Errorf("read status = %d while read-only, want 204", w.Code)
+	}
+
+	// The admin switch holds the mode after the master recovered
+	m.SetAdmin(true, "maintenance")
+	m.SetHealth(false, "")
+	if status := m.Status(); !status.ReadOnly || status.Source != SourceAdmin {
+		t.Errorf("status = %+v, want read-only by the admin", status)
+	}
+
+	m.SetAdmin(false, "")
+	if w := serve(http.MethodDelete); w.Code != http.StatusNoContent {
+		t.Errorf("write status = %d after the switch off, want 204", w.Code)
+	}
+}
@@ -0,0 +1,157 @@
+package readonly
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// Sources of the read-only mode
+const (
+	SourceAdmin  = "admin"
+	SourceHealth = "health"
+)
+
+// defaultRetryAfter of the rejected writes
+const defaultRetryAfter = 30 * time.Second
+
+// Status of the mode
+type Status struct {
+	ReadOnly bool `json:"read_only"`
+	// Source is admin or health, admin when both are on
+	Source string     `json:"source,omitempty"`
+	Reason string     `json:"reason,omitempty"`
+	Since  *time.Time `json:"since,omitempty"`
+}
+
+// Mode rejects the writes while it is on, switched by an admin or by the health of the master.
+// Both switches are independent: the mode is on while either is.
+type Mode struct {
+	mu         sync.RWMutex
+	admin      state
+	health     state
+	retryAfter time.Duration
+}
+
+type state struct {
+	on     bool
+	reason string
+	since  time.Time
+}
+
+// New creates new Mode, off
+func New(retryAfter time.Duration) *Mode {
+	m := &Mode{}
+	m.SetRetryAfter(retryAfter)
+	return m
+}
+
+// SetRetryAfter replaces the wait suggested to the rejected writes, 30 seconds when zero
+func (m *Mode) SetRetryAfter(retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		retryAfter = defaultRetryAfter
+	}
+
+	m.mu.Lock()
+	m.retryAfter = retryAfter
+	m.mu.Unlock()
+}
+
+// SetAdmin switches the mode of the admin
+func (m *Mode) SetAdmin(on bool, reason string) {
+	m.set(&m.admin, SourceAdmin, on, reason)
+}
+
+// SetHealth switches the mode of the health checks
+func (m *Mode) SetHealth(on bool, reason string) {
+	m.set(&m.health, SourceHealth, on, reason)
+}
+
+func (m *Mode) set(s *state, source string, on bool, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s.on == on && (!on || s.reason == reason) {
+		return
+	}
+
+	if on {
+		logger.Warnf("read-only mode on by %s: %s", source, reason)
+		if !s.on {
+			s.since = time.Now()
+		}
+	} else {
+		logger.Infof("read-only mode off by %s", source)
+	}
+
+	s.on, s.reason = on, reason
+}
+
+// Status returns the current status, a nil Mode is off
+func (m *Mode) Status() Status {
+	if m == nil {
+		return Status{}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, s := range []struct {
+		source string
+		state  state
+	}{{SourceAdmin, m.admin}, {SourceHealth, m.health}} {
+		if s.state.on {
+			since := s.state.since
+			return Status{ReadOnly: true, Source: s.source, Reason: s.state.reason, Since: &since}
+		}
+	}
+
+	return Status{}
+}
+
+// ReadOnly reports whether the writes are rejected
+func (m *Mode) ReadOnly() bool {
+	return m.Status().ReadOnly
+}
+
+// Watch checks the master every interval until stop is called. The mode turns on after threshold
+// consecutive failures and off after the first success.
+func (m *Mode) Watch(interval time.Duration, threshold int, check func(ctx context.Context) error) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		failures := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkCtx, cancelCheck := context.WithTimeout(ctx, interval)
+				err := check(checkCtx)
+				cancelCheck()
+
+				if ctx.Err() != nil {
+					return
+				}
+
+				if err == nil {
+					failures = 0
+					m.SetHealth(false, "")
+					continue
+				}
+
+				failures++
+				if failures >= threshold {
+					m.SetHealth(true, "the master is unavailable: "+err.Error())
+				}
+			}
+		}
+	}()
+
+	return cancel
+}
@@ -1,8 +1,11 @@
 package response
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 )
 
@@ -41,6 +44,51 @@ var (
 		HTTPCode: http.StatusBadRequest,
 		Code:     "BAD_REQUEST",
 	}
+
+	APIErrUnauthorized = APIResponse{
+		HTTPCode: http.StatusUnauthorized,
+		Code:     "UNAUTHORIZED",
+		Message:  "Unauthorized",
+	}
+
+	APIErrConflict = APIResponse{
+		HTTPCode: http.StatusConflict,
+		Code:     "CONFLICT",
+	}
+
+	APIErrTenantRequired = APIResponse{
+		HTTPCode: http.StatusBadRequest,
+		Code:     "TENANT_REQUIRED",
+	}
+
+	APIErrTenantMismatch = APIResponse{
+		HTTPCode: http.StatusForbidden,
+		Code:     "TENANT_MISMATCH",
+	}
+
+	APIErrServiceUnavailable = APIResponse{
+		HTTPCode: http.StatusServiceUnavailable,
+		Code:     "SERVICE_UNAVAILABLE",
+		Message:  "The service is unavailable, retry later",
+	}
+
+	APIErrReadOnlyMode = APIResponse{
+		HTTPCode: http.StatusServiceUnavailable,
+		Code:     "READ_ONLY_MODE",
+		Message:  "The service is read-only for now, retry the write later",
+	}
+
+	APIErrGatewayTimeout = APIResponse{
+		HTTPCode: http.StatusGatewayTimeout,
+		Code:     "TIMEOUT",
+		Message:  "The request timed out",
+	}
+
+	APIErrTooManyRequests = APIResponse{
+		HTTPCode: http.StatusTooManyRequests,
+		Code:     "TOO_MANY_REQUESTS",
+		Message:  "Too many requests",
+	}
 )
 
 // WriteAPIOK for write response as HTTP OK result
@@ -90,6 +138,28 @@ func WriteAPIErrorMessage(w http.ResponseWriter, response APIResponse) {
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// WriteAPIServerError for write response of an unexpected error: 504 when a dependency timed out,
+// 503 when it is unavailable and 500 otherwise. The errors tell with a Timeout or an Unavailable method,
+// the bad connections and the network errors are unavailable too. Their text never reaches the client.
+func WriteAPIServerError(w http.ResponseWriter, err error) {
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		WriteAPIErrorMessage(w, APIErrGatewayTimeout)
+		return
+	}
+
+	var (
+		unavailable interface{ Unavailable() bool }
+		netErr      net.Error
+	)
+	if (errors.As(err, &unavailable) && unavailable.Unavailable()) || errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr) {
+		WriteAPIErrorMessage(w, APIErrServiceUnavailable)
+		return
+	}
+
+	WriteAPIErrorMessage(w, APIInternalError)
+}
+
 // WriteAPIErrorWithData for write response as HTTP Error result with data
 func WriteAPIErrorWithData(w http.ResponseWriter, response APIResponse, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
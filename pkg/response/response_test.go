@@ -0,0 +1,55 @@
+package response
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type dependencyError struct {
+	timeout, unavailable bool
+}
+
+func (e dependencyError) Error() string     { return "dial tcp 10.0.0.12:3306: secret-host" }
+func (e dependencyError) Timeout() bool     { return e.timeout }
+func (e dependencyError) Unavailable() bool { return e.unavailable }
+
+func TestWriteAPIServerError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want APIResponse
+	}{
+		{"timeout", dependencyError{timeout: true}, APIErrGatewayTimeout},
+		{"wrapped timeout", fmt.Errorf("query: %w", dependencyError{timeout: true}), APIErrGatewayTimeout},
+		{"unavailable", dependencyError{unavailable: true}, APIErrServiceUnavailable},
+		{"bad connection", fmt.Errorf("query: %w", driver.ErrBadConn), APIErrServiceUnavailable},
+		{"refused connection", &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("%v: connection refused", dependencyError{})}, APIErrServiceUnavailable},
+		{"network timeout", &net.OpError{Op: "read", Net: "tcp", Err: dependencyError{timeout: true}}, APIErrGatewayTimeout},
+		{"unexpected", dependencyError{}, APIInternalError},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		WriteAPIServerError(w, tt.err)
+
+		if w.Code != tt.want.HTTPCode {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want.HTTPCode)
+		}
+		if strings.Contains(w.Body.String(), "secret-host") {
+			t.Errorf("%s: body %s exposes the error", tt.name, w.Body.String())
+		}
+
+		var body APIResponse
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Code != tt.want.Code || body.Message != tt.want.Message {
+			t.Errorf("%s: body = %+v, want %+v", tt.name, body, tt.want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package sse
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default broker settings
+const (
+	defaultLogSize      = 1000
+	defaultClientBuffer = 64
+	defaultHeartbeat    = 15 * time.Second
+)
+
+// ErrBrokerClosed returned when subscribing to a closed broker
+var ErrBrokerClosed = errors.New("the event broker is closed")
+
+// Event is a single message pushed to the stream subscribers
+type Event struct {
+	ID uint64
+	// Scope restricts the event to the clients of the same scope, e.g. a tenant
+	Scope string
+	Type  string
+	Data  interface{}
+}
+
+// Options for the broker
+type Options struct {
+	// LogSize is the number of events kept for Last-Event-ID resume
+	LogSize int
+	// ClientBuffer is the number of pending events per client before it is dropped
+	ClientBuffer int
+	// Heartbeat is the interval of the keep-alive comments
+	Heartbeat time.Duration
+	// Scope returns the scope of a stream request, every client shares the empty scope when nil
+	Scope func(r *http.Request) string
+}
+
+// Client is a single stream subscriber
+type Client struct {
+	scope  string
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Events returns the channel of the published events
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Done is closed when the client is dropped or the broker is closed
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) close() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
+
+// Broker fans out the published events to the connected clients
+type Broker struct {
+	mu      sync.Mutex
+	options Options
+	log     []Event
+	lastID  uint64
+	clients map[*Client]struct{}
+	closed  bool
+}
+
+// NewBroker creates new Broker
+func NewBroker(options Options) *Broker {
+	if options.LogSize <= 0 {
+		options.LogSize = defaultLogSize
+	}
+
+	if options.ClientBuffer <= 0 {
+		options.ClientBuffer = defaultClientBuffer
+	}
+
+	if options.Heartbeat <= 0 {
+		options.Heartbeat = defaultHeartbeat
+	}
+
+	return &Broker{
+		options: options,
+		clients: make(map[*Client]struct{}),
+	}
+}
+
+// Publish assigns the next ID to the event, stores it on the log and sends it to every client.
+// Clients whose buffer is full are dropped, they can resume with Last-Event-ID.
+func (b *Broker) Publish(scope string, eventType string, data interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.lastID++
+	event := Event{
+		ID:    b.lastID,
+		Scope: scope,
+		Type:  eventType,
+		Data:  data,
+	}
+
+	b.log = append(b.log, event)
+	if len(b.log) > b.options.LogSize {
+		b.log = b.log[len(b.log)-b.options.LogSize:]
+	}
+
+	for c := range b.clients {
+		if c.scope != scope {
+			continue
+		}
+
+		select {
+		case c.events <- event:
+		default:
+			delete(b.clients, c)
+			c.close()
+		}
+	}
+}
+
+// Subscribe registers a new client of the scope. When lastEventID is not zero the events of the scope
+// published after it are returned as backlog, resumable is false when some of them have already left the log.
+func (b *Broker) Subscribe(scope string, lastEventID uint64) (client *Client, backlog []Event, resumable bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, nil, false, ErrBrokerClosed
+	}
+
+	resumable = true
+	if lastEventID > 0 {
+		if lastEventID > b.lastID {
+			resumable = false
+		} else if len(b.log) > 0 && lastEventID < b.log[0].ID-1 {
+			resumable = false
+		}
+
+		for _, e := range b.log {
+			if e.ID > lastEventID && e.Scope == scope {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+
+	client = &Client{
+		scope:  scope,
+		events: make(chan Event, b.options.ClientBuffer),
+		done:   make(chan struct{}),
+	}
+	b.clients[client] = struct{}{}
+
+	return client, backlog, resumable, nil
+}
+
+// Unsubscribe removes the client from the broker
+func (b *Broker) Unsubscribe(client *Client) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.clients, client)
+	client.close()
+}
+
+// Close terminates every stream and rejects the new subscriptions
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for c := range b.clients {
+		delete(b.clients, c)
+		c.close()
+	}
+}
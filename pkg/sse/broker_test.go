@@ -0,0 +1,180 @@
+package sse
+
+import "testing"
+
+// ids returns the IDs of the events
+func ids(events []Event) []uint64 {
+	var ids []uint64
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+
+	return ids
+}
+
+// received drains the pending events of the client
+func received(c *Client) []Event {
+	var events []Event
+	for {
+		select {
+		case e := <-c.Events():
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func isDone(c *Client) bool {
+	select {
+	case <-c.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestBrokerFansOutToTheClientsOfTheScope(t *testing.T) {
+	b := NewBroker(Options{})
+
+	first, _, _, err := b.Subscribe("brand-a", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, _, err := b.Subscribe("brand-a", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, _, err := b.Subscribe("brand-b", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Publish("brand-a", "movie.created", map[string]int64{"id": 1})
+	b.Publish("brand-b", "movie.created", map[string]int64{"id": 2})
+	b.Publish("brand-a", "movie.deleted", map[string]int64{"id": 1})
+
+	for name, c := range map[string]*Client{"first": first, "second": second} {
+		events := received(c)
+		if len(events) != 2 || events[0].Type != "movie.created" || events[1].Type != "movie.deleted" {
+			t.Errorf("%s client got %+v, want the 2 events of its scope", name, events)
+		}
+	}
+	if events := received(other); len(events) != 1 || events[0].ID != 2 {
+		t.Errorf("other scope got %+v, want only the event 2", events)
+	}
+}
+
+func TestBrokerUnsubscribe(t *testing.T) {
+	b := NewBroker(Options{})
+
+	c, _, _, err := b.Subscribe("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Unsubscribe(c)
+
+	if !isDone(c) {
+		t.Error("the unsubscribed client is not done")
+	}
+	b.Publish("", "movie.created", nil)
+	if events := received(c); len(events) > 0 {
+		t.Errorf("the unsubscribed client got %+v", events)
+	}
+
+	// A second Unsubscribe, e.g. after a drop, is harmless
+	b.Unsubscribe(c)
+}
+
+func TestBrokerDropsTheSlowClients(t *testing.T) {
+	b := NewBroker(Options{ClientBuffer: 2})
+
+	slow, _, _, err := b.Subscribe("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		b.Publish("", "movie.updated", nil)
+	}
+
+	if !isDone(slow) {
+		t.Fatal("the client with a full buffer is not dropped")
+	}
+	if events := received(slow); len(events) != 2 {
+		t.Errorf("the dropped client got %d events, want its buffer of 2", len(events))
+	}
+
+	// It resumes from its last event
+	_, backlog, resumable, err := b.Subscribe("", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resumable || len(backlog) != 1 || backlog[0].ID != 3 {
+		t.Errorf("backlog %v, resumable %v, want the event 3", ids(backlog), resumable)
+	}
+}
+
+func TestBrokerBacklog(t *testing.T) {
+	b := NewBroker(Options{LogSize: 3})
+	for _, scope := range []string{"brand-a", "brand-b", "brand-a", "brand-a", "brand-a"} {
+		b.Publish(scope, "movie.updated", nil)
+	}
+	// The log holds the events 3 to 5
+
+	tests := []struct {
+		name        string
+		lastEventID uint64
+		backlog     []uint64
+		resumable   bool
+	}{
+		{"new client", 0, nil, true},
+		{"up to date", 5, nil, true},
+		{"events of the scope after the last one", 3, []uint64{4, 5}, true},
+		{"oldest logged event next", 2, []uint64{3, 4, 5}, true},
+		{"events left the log", 1, []uint64{3, 4, 5}, false},
+		{"unknown event", 9, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, backlog, resumable, err := b.Subscribe("brand-a", tt.lastEventID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := ids(backlog)
+			if resumable != tt.resumable || len(got) != len(tt.backlog) {
+				t.Fatalf("backlog %v, resumable %v, want %v, %v", got, resumable, tt.backlog, tt.resumable)
+			}
+			for i := range got {
+				if got[i] != tt.backlog[i] {
+					t.Errorf("backlog %v, want %v", got, tt.backlog)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker(Options{})
+
+	c, _, _, err := b.Subscribe("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	if !isDone(c) {
+		t.Error("the client is not done after Close")
+	}
+	if _, _, _, err := b.Subscribe("", 0); err != ErrBrokerClosed {
+		t.Errorf("Subscribe after Close err = %v, want %v", err, ErrBrokerClosed)
+	}
+
+	// The publishers outliving the broker are ignored
+	b.Publish("", "movie.created", nil)
+	if events := received(c); len(events) > 0 {
+		t.Errorf("the client got %+v after Close", events)
+	}
+}
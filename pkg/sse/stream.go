@@ -0,0 +1,102 @@
+package sse
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-rest-api/pkg/response"
+)
+
+// resetEvent tells the client that the requested events are gone and it has to refetch
+const resetEvent = "reset"
+
+// ServeHTTP streams the broker events to the client as Server-Sent Events
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		response.WriteAPIError(w, response.APIInternalError, "streaming unsupported")
+		return
+	}
+
+	var lastEventID uint64
+	if v := strings.TrimSpace(r.Header.Get("Last-Event-ID")); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			response.WriteAPIError(w, response.APIErrorBadRequest, "invalid Last-Event-ID")
+			return
+		}
+		lastEventID = id
+	}
+
+	var scope string
+	if b.options.Scope != nil {
+		scope = b.options.Scope(r)
+	}
+
+	client, backlog, resumable, err := b.Subscribe(scope, lastEventID)
+	if err != nil {
+		response.WriteAPIError(w, response.APIErrServiceUnavailable, err)
+		return
+	}
+	defer b.Unsubscribe(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !resumable {
+		if err := writeEvent(w, Event{ID: lastEventID, Type: resetEvent}); err != nil {
+			return
+		}
+	}
+
+	for _, e := range backlog {
+		if err := writeEvent(w, e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(b.options.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-client.Done():
+			return
+		case e := <-client.Events():
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes the event on the SSE wire format
+func writeEvent(w io.Writer, e Event) error {
+	data := []byte("{}")
+	if e.Data != nil {
+		var err error
+		data, err = json.Marshal(e.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
@@ -0,0 +1,151 @@
+package sse
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvent reads the lines of the next event, the heartbeats are skipped
+func readEvent(t *testing.T, r *bufio.Reader) []string {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read the stream: %v", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && len(lines) > 0:
+			return lines
+		case line == "", strings.HasPrefix(line, ":"):
+		default:
+			lines = append(lines, line)
+		}
+	}
+}
+
+// waitForClients waits for the streams to subscribe
+func waitForClients(t *testing.T, b *Broker, n int) {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		b.mu.Lock()
+		clients := len(b.clients)
+		b.mu.Unlock()
+		if clients == n {
+			return
+		}
+	}
+	t.Fatalf("no %d clients subscribed", n)
+}
+
+func TestServeHTTPStreamsTheEvents(t *testing.T) {
+	b := NewBroker(Options{Scope: func(r *http.Request) string { return r.Header.Get("X-Tenant-ID") }})
+	server := httptest.NewServer(b)
+	defer server.Close()
+
+	b.Publish("brand-a", "movie.created", map[string]int64{"id": 1})
+	b.Publish("brand-b", "movie.created", map[string]int64{"id": 2})
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Tenant-ID", "brand-a")
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// A new client gets no backlog, only the events published after it connected
+	body := bufio.NewReader(resp.Body)
+	waitForClients(t, b, 1)
+	b.Publish("brand-b", "movie.deleted", map[string]int64{"id": 2})
+	b.Publish("brand-a", "movie.updated", map[string]int64{"id": 1})
+
+	want := []string{"id: 4", "event: movie.updated", `data: {"id":1}`}
+	if got := readEvent(t, body); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("event = %q, want %q", got, want)
+	}
+
+	// Close on shutdown ends the stream
+	b.Close()
+	if _, err := body.ReadString('\n'); err == nil {
+		t.Error("the stream goes on after Close")
+	}
+}
+
+func TestServeHTTPResumes(t *testing.T) {
+	b := NewBroker(Options{LogSize: 2})
+	server := httptest.NewServer(b)
+	defer server.Close()
+
+	for i := 0; i < 4; i++ {
+		b.Publish("", "movie.updated", nil)
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		want        []string
+	}{
+		{"events after the last one", "3", []string{"id: 4", "event: movie.updated", "data: {}"}},
+		{"events left the log", "1", []string{"id: 1", "event: reset", "data: {}"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Last-Event-ID", tt.lastEventID)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := readEvent(t, bufio.NewReader(resp.Body)); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("first event = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeHTTPRejects(t *testing.T) {
+	closed := NewBroker(Options{})
+	closed.Close()
+
+	tests := []struct {
+		name        string
+		broker      *Broker
+		lastEventID string
+		want        int
+	}{
+		{"invalid Last-Event-ID", NewBroker(Options{}), "last", http.StatusBadRequest},
+		{"closed broker", closed, "", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.lastEventID != "" {
+			r.Header.Set("Last-Event-ID", tt.lastEventID)
+		}
+		w := httptest.NewRecorder()
+		tt.broker.ServeHTTP(w, r)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
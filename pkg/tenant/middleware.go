@@ -0,0 +1,246 @@
+package tenant
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rest-api/pkg/response"
+)
+
+// Tenant resolvers
+const (
+	ResolverHeader    = "header"
+	ResolverSubdomain = "subdomain"
+	ResolverToken     = "token"
+)
+
+// Options of the tenant resolution
+type Options struct {
+	// Resolvers are the enabled resolvers, all of them when empty
+	Resolvers []string
+	// Header carrying the tenant, X-Tenant-ID when empty
+	Header string
+	// BaseDomain of the subdomains, e.g. "movies.example.com" for "brand.movies.example.com"
+	BaseDomain string
+	// TokenSecret is the HS256 secret of the bearer JWT carrying the tenant claim
+	TokenSecret string
+	// TokenClaim is the claim of the tenant, "tenant" when empty
+	TokenClaim string
+	// Tenants are the known tenants, any tenant is accepted when empty
+	Tenants []string
+	// Default tenant when none is resolved, the request is rejected when empty
+	Default string
+}
+
+// Resolver resolves the tenant of the requests. The token secret can change while serving.
+type Resolver struct {
+	mu      sync.RWMutex
+	options Options
+	known   map[string]bool
+}
+
+// NewResolver creates new Resolver
+func NewResolver(options Options) *Resolver {
+	if len(options.Resolvers) == 0 {
+		options.Resolvers = []string{ResolverHeader, ResolverSubdomain, ResolverToken}
+	}
+
+	if options.Header == "" {
+		options.Header = "X-Tenant-ID"
+	}
+
+	if options.TokenClaim == "" {
+		options.TokenClaim = "tenant"
+	}
+
+	known := make(map[string]bool, len(options.Tenants))
+	for _, t := range options.Tenants {
+		known[t] = true
+	}
+
+	return &Resolver{
+		options: options,
+		known:   known,
+	}
+}
+
+// Middleware resolves the tenant and carries it in the request context. Every enabled resolver
+// is evaluated and the request is rejected when they disagree, so a header cannot override the
+// tenant of a token.
+func (t *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, err := t.resolve(r)
+		if err != nil {
+			response.WriteAPIError(w, err.response, err.message)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), tenantID)))
+	})
+}
+
+// SetTokenSecret replaces the secret of the bearer tokens, the tokens signed with the previous one
+// are rejected
+func (t *Resolver) SetTokenSecret(secret string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.options.TokenSecret = secret
+}
+
+// Vary returns the request headers the tenant is resolved from, a shared cache must key the responses
+// on them. The subdomain is part of the URL already.
+func (t *Resolver) Vary() []string {
+	var headers []string
+	for _, resolver := range t.options.Resolvers {
+		switch resolver {
+		case ResolverHeader:
+			headers = append(headers, t.options.Header)
+		case ResolverToken:
+			headers = append(headers, "Authorization")
+		}
+	}
+
+	return headers
+}
+
+// resolveError is a tenant resolution failure with its API response
+type resolveError struct {
+	response response.APIResponse
+	message  string
+}
+
+// resolve returns the tenant of the request
+func (t *Resolver) resolve(r *http.Request) (string, *resolveError) {
+	var tenantID string
+	for _, resolver := range t.options.Resolvers {
+		var (
+			candidate string
+			err       *resolveError
+		)
+
+		switch resolver {
+		case ResolverHeader:
+			candidate = strings.TrimSpace(r.Header.Get(t.options.Header))
+		case ResolverSubdomain:
+			candidate = t.fromSubdomain(r.Host)
+		case ResolverToken:
+			candidate, err = t.fromToken(r)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if candidate == "" {
+			continue
+		}
+		if tenantID != "" && tenantID != candidate {
+			return "", &resolveError{response.APIErrTenantMismatch, "the tenant sources disagree"}
+		}
+		tenantID = candidate
+	}
+
+	if tenantID == "" {
+		tenantID = t.options.Default
+	}
+
+	if tenantID == "" {
+		return "", &resolveError{response.APIErrTenantRequired, "the tenant is required"}
+	}
+
+	if len(t.known) > 0 && !t.known[tenantID] {
+		return "", &resolveError{response.APIErrTenantRequired, "unknown tenant"}
+	}
+
+	return tenantID, nil
+}
+
+// fromSubdomain returns the leftmost label of a host under the base domain
+func (t *Resolver) fromSubdomain(host string) string {
+	if t.options.BaseDomain == "" {
+		return ""
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	suffix := "." + strings.ToLower(t.options.BaseDomain)
+	host = strings.ToLower(host)
+	if !strings.HasSuffix(host, suffix) {
+		return ""
+	}
+
+	sub := strings.TrimSuffix(host, suffix)
+	if sub == "" || strings.Contains(sub, ".") {
+		return ""
+	}
+
+	return sub
+}
+
+// fromToken returns the tenant claim of a bearer HS256 JWT
+func (t *Resolver) fromToken(r *http.Request) (string, *resolveError) {
+	t.mu.RLock()
+	secret := t.options.TokenSecret
+	t.mu.RUnlock()
+
+	if secret == "" {
+		return "", nil
+	}
+
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return "", nil
+	}
+
+	parts := strings.Split(strings.TrimSpace(header[7:]), ".")
+	if len(parts) != 3 {
+		// Not a JWT, e.g. an editor token
+		return "", nil
+	}
+
+	invalid := &resolveError{response.APIErrUnauthorized, "invalid token"}
+
+	var jose struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &jose); err != nil || jose.Alg != "HS256" {
+		return "", invalid
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return "", invalid
+	}
+
+	claims := make(map[string]interface{})
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", invalid
+	}
+
+	if exp, ok := claims["exp"].(float64); ok && time.Now().Unix() >= int64(exp) {
+		return "", &resolveError{response.APIErrUnauthorized, "token expired"}
+	}
+
+	tenantID, _ := claims[t.options.TokenClaim].(string)
+	return tenantID, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,163 @@
+package tenant
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func signToken(secret, payload string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	body := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(header + "." + body))
+	return header + "." + body + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestMiddleware(t *testing.T) {
+	resolver := NewResolver(Options{
+		BaseDomain:  "movies.example.com",
+		TokenSecret: "secret",
+		Tenants:     []string{"brand-a", "brand-b"},
+	})
+
+	tests := []struct {
+		name       string
+		host       string
+		headers    map[string]string
+		wantStatus int
+		wantTenant string
+	}{
+		{
+			name:       "header",
+			headers:    map[string]string{"X-Tenant-ID": "brand-a"},
+			wantStatus: http.StatusOK,
+			wantTenant: "brand-a",
+		},
+		{
+			name:       "subdomain",
+			host:       "brand-b.movies.example.com:8080",
+			wantStatus: http.StatusOK,
+			wantTenant: "brand-b",
+		},
+		{
+			name:       "token claim",
+			headers:    map[string]string{"Authorization": "Bearer " + signToken("secret", `{"tenant":"brand-b"}`)},
+			wantStatus: http.StatusOK,
+			wantTenant: "brand-b",
+		},
+		{
+			name: "header cannot override the token",
+			headers: map[string]string{
+				"Authorization": "Bearer " + signToken("secret", `{"tenant":"brand-b"}`),
+				"X-Tenant-ID":   "brand-a",
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "forged token",
+			headers:    map[string]string{"Authorization": "Bearer " + signToken("other", `{"tenant":"brand-a"}`)},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "unknown tenant",
+			headers:    map[string]string{"X-Tenant-ID": "brand-c"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing tenant",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTenant string
+			handler := resolver.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotTenant, _ = FromContext(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+			if tt.host != "" {
+				r.Host = tt.host
+			}
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if gotTenant != tt.wantTenant {
+				t.Errorf("tenant = %q, want %q", gotTenant, tt.wantTenant)
+			}
+		})
+	}
+}
+
+func TestMiddlewareDefaultTenant(t *testing.T) {
+	resolver := NewResolver(Options{Default: DefaultTenant})
+
+	var gotTenant string
+	handler := resolver.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant, _ = FromContext(r.Context())
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/movies", nil))
+
+	if gotTenant != DefaultTenant {
+		t.Errorf("tenant = %q, want %q", gotTenant, DefaultTenant)
+	}
+}
+
+func TestMiddlewareFollowsTheRotatedSecret(t *testing.T) {
+	resolver := NewResolver(Options{Resolvers: []string{ResolverToken}, TokenSecret: "old-secret"})
+	handler := resolver.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	status := func(secret string) int {
+		r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+		r.Header.Set("Authorization", "Bearer "+signToken(secret, `{"tenant":"brand-a"}`))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := status("old-secret"); code != http.StatusOK {
+		t.Errorf("status = %d before the rotation, want %d", code, http.StatusOK)
+	}
+
+	resolver.SetTokenSecret("new-secret")
+	if code := status("old-secret"); code != http.StatusUnauthorized {
+		t.Errorf("status of a token of the old secret = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := status("new-secret"); code != http.StatusOK {
+		t.Errorf("status of a token of the new secret = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestResolverVary(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    []string
+	}{
+		{"every resolver", Options{}, []string{"X-Tenant-ID", "Authorization"}},
+		{"custom header", Options{Resolvers: []string{ResolverHeader}, Header: "X-Brand"}, []string{"X-Brand"}},
+		{"subdomain only", Options{Resolvers: []string{ResolverSubdomain}}, nil},
+		{"token only", Options{Resolvers: []string{ResolverSubdomain, ResolverToken}}, []string{"Authorization"}},
+	}
+
+	for _, tt := range tests {
+		got := NewResolver(tt.options).Vary()
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: Vary = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package tenant
+
+import (
+	"context"
+)
+
+// DefaultTenant is the tenant of single brand deployments
+const DefaultTenant = "default"
+
+type contextKey struct{}
+
+// WithTenant carries the tenant in the context
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenantID)
+}
+
+// FromContext returns the tenant carried by the context
+func FromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(contextKey{}).(string)
+	return tenantID, ok && tenantID != ""
+}